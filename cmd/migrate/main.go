@@ -0,0 +1,75 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+
+	"github.com/brenoproti/go-api/configs"
+	"github.com/brenoproti/go-api/internal/infra/database"
+	"github.com/brenoproti/go-api/internal/infra/database/migrations"
+)
+
+const usage = `usage: migrate [-config dir] <command>
+
+commands:
+  up          apply every pending migration
+  down [n]    roll back the last n migrations (default 1)
+  status      list migrations and whether they are applied
+`
+
+func main() {
+	configDir := flag.String("config", "cmd/server", "directory containing the .env file")
+	flag.Usage = func() { fmt.Fprint(os.Stderr, usage) }
+	flag.Parse()
+	if flag.NArg() == 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	config := configs.LoadConfig(*configDir)
+	db, err := database.Open(config.Database())
+	if err != nil {
+		fail(err)
+	}
+	migrator, err := migrations.New(db, config.DBDriver)
+	if err != nil {
+		fail(err)
+	}
+
+	switch flag.Arg(0) {
+	case "up":
+		err = migrator.Up()
+	case "down":
+		steps := 1
+		if flag.NArg() > 1 {
+			steps, err = strconv.Atoi(flag.Arg(1))
+			if err != nil {
+				fail(fmt.Errorf("invalid number of steps %q", flag.Arg(1)))
+			}
+		}
+		err = migrator.Down(steps)
+	case "status":
+		var status []migrations.Status
+		status, err = migrator.Status()
+		for _, s := range status {
+			applied := "pending"
+			if s.AppliedAt != nil {
+				applied = s.AppliedAt.Format("2006-01-02 15:04:05")
+			}
+			fmt.Printf("%04d  %-40s %s\n", s.Version, s.Name, applied)
+		}
+	default:
+		flag.Usage()
+		os.Exit(2)
+	}
+	if err != nil {
+		fail(err)
+	}
+}
+
+func fail(err error) {
+	fmt.Fprintln(os.Stderr, "migrate:", err)
+	os.Exit(1)
+}
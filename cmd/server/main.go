@@ -2,19 +2,21 @@ package main
 
 import (
 	"fmt"
+	"log"
 	"net/http"
+	"time"
 
 	"github.com/brenoproti/go-api/configs"
 	_ "github.com/brenoproti/go-api/docs"
 	"github.com/brenoproti/go-api/internal/entity"
 	"github.com/brenoproti/go-api/internal/infra/database"
+	"github.com/brenoproti/go-api/internal/infra/database/migrations"
+	"github.com/brenoproti/go-api/internal/infra/mail"
 	"github.com/brenoproti/go-api/internal/infra/webserver/handlers"
+	"github.com/brenoproti/go-api/pkg/cursor"
+	"github.com/brenoproti/go-api/pkg/jwtkeys"
 	"github.com/go-chi/chi"
 	"github.com/go-chi/chi/middleware"
-	"github.com/go-chi/jwtauth"
-
-	"gorm.io/driver/sqlite"
-	"gorm.io/gorm"
 
 	httpSwagger "github.com/swaggo/http-swagger"
 )
@@ -37,37 +39,259 @@ import (
 // @name Authorization
 func main() {
 	config := configs.LoadConfig("cmd/server")
-	fmt.Printf("%v", config)
-	db, err := gorm.Open(sqlite.Open("test.db"), &gorm.Config{})
+	entity.DefaultPasswordPolicy = config.PasswordPolicy()
+	db, err := database.Open(config.Database())
+	if err != nil {
+		panic(err)
+	}
+	migrator, err := migrations.New(db, config.DBDriver)
 	if err != nil {
 		panic(err)
 	}
-	db.AutoMigrate(&entity.User{}, &entity.Product{})
+	if err := migrator.Check(); err != nil {
+		panic(fmt.Errorf("%w, run `go run ./cmd/migrate up` first", err))
+	}
 
+	realIP, err := handlers.RealIP(config.Proxies())
+	if err != nil {
+		panic(err)
+	}
 	r := chi.NewRouter()
+	r.Use(realIP)
 	r.Use(middleware.Logger)
 	r.Use(middleware.Recoverer)
+	r.NotFound(handlers.NotFound)
+	r.MethodNotAllowed(handlers.MethodNotAllowed)
+
+	jwtKeys, err := jwtkeys.New(config.JWTKeys())
+	if err != nil {
+		panic(err)
+	}
+	go rotateJWTKeys(jwtKeys, time.Minute)
+	r.Get("/.well-known/jwks.json", handlers.NewJWKSHandler(jwtKeys, config.JWKSMaxAge).GetJWKS)
+
+	userDb := database.NewUser(db)
+	revokedTokenDb := database.NewRevokedTokenDB(db)
+	go deleteExpiredRevokedTokens(revokedTokenDb, time.Minute)
 
 	productDb := database.NewProductDB(db)
-	productHandler := handlers.NewProductHandler(productDb)
+	productHandler := handlers.NewProductHandler(productDb, cursor.NewSigner([]byte(config.CursorSecret)))
+	// without a retention, every deleted product would be purged at once
+	if config.TrashRetention <= 0 {
+		panic("TRASH_RETENTION must be a positive number of seconds")
+	}
+	go purgeDeletedProducts(productDb, time.Second*time.Duration(config.TrashRetention), time.Hour)
+	if config.ReservationMaxTTL < config.ReservationTTL {
+		panic("RESERVATION_MAX_TTL must be at least RESERVATION_TTL")
+	}
+	inventoryDb := database.NewInventoryDB(db)
+	inventoryHandler := handlers.NewInventoryHandler(inventoryDb, productDb, config.ReservationTTL, config.ReservationMaxTTL)
+	go deleteExpiredReservations(inventoryDb, time.Minute)
 
 	r.Route("/products", func(r chi.Router) {
-		r.Use(jwtauth.Verifier(config.TokenAuth))
-		r.Use(jwtauth.Authenticator)
+		r.Use(handlers.Verifier(jwtKeys))
+		r.Use(handlers.Authenticator)
+		r.Use(handlers.RejectRevoked(revokedTokenDb, userDb))
+		r.Use(handlers.RequireRole(entity.RoleViewer))
 
-		r.Post("/", productHandler.Create)
-		r.Put("/{id}", productHandler.Update)
 		r.Get("/{id}", productHandler.FindById)
 		r.Get("/", productHandler.GetProducts)
-		r.Delete("/{id}", productHandler.Delete)
+		r.Get("/{id}/categories", productHandler.GetCategories)
+		r.Get("/{id}/stock", inventoryHandler.GetStock)
+
+		r.Group(func(r chi.Router) {
+			r.Use(handlers.RequireRole(entity.RoleEditor))
+
+			r.Post("/", productHandler.Create)
+			r.Put("/{id}", productHandler.Update)
+			r.Patch("/{id}", productHandler.Patch)
+			r.Put("/{id}/categories", productHandler.SetCategories)
+			r.Post("/{id}/stock/increment", inventoryHandler.Increment)
+			r.Post("/{id}/stock/decrement", inventoryHandler.Decrement)
+			// committing a reservation decrements the stock too
+			r.Post("/{id}/reservations", inventoryHandler.Reserve)
+			// editors can only delete their own products, see ProductHandler.Delete
+			r.Delete("/{id}", productHandler.Delete)
+		})
+
+		r.Group(func(r chi.Router) {
+			r.Use(handlers.RequireRole(entity.RoleAdmin))
+
+			r.Get("/trash", productHandler.GetTrash)
+			r.Post("/{id}/restore", productHandler.Restore)
+		})
 	})
 
-	userDb := database.NewUser(db)
-	userHandler := handlers.NewUserHandler(userDb, config.TokenAuth, config.JWTExpiresIn)
+	r.Route("/reservations", func(r chi.Router) {
+		r.Use(handlers.Verifier(jwtKeys))
+		r.Use(handlers.Authenticator)
+		r.Use(handlers.RejectRevoked(revokedTokenDb, userDb))
+		r.Use(handlers.RequireRole(entity.RoleViewer))
+
+		r.Get("/{id}", inventoryHandler.FindReservation)
+
+		r.Group(func(r chi.Router) {
+			r.Use(handlers.RequireRole(entity.RoleEditor))
+
+			r.Delete("/{id}", inventoryHandler.Release)
+			r.Post("/{id}/commit", inventoryHandler.Commit)
+		})
+	})
+
+	categoryDb := database.NewCategoryDB(db)
+	categoryHandler := handlers.NewCategoryHandler(categoryDb)
+
+	r.Route("/categories", func(r chi.Router) {
+		r.Use(handlers.Verifier(jwtKeys))
+		r.Use(handlers.Authenticator)
+		r.Use(handlers.RejectRevoked(revokedTokenDb, userDb))
+		r.Use(handlers.RequireRole(entity.RoleViewer))
+
+		r.Get("/{id}", categoryHandler.FindById)
+		r.Get("/", categoryHandler.GetCategories)
+
+		r.Group(func(r chi.Router) {
+			r.Use(handlers.RequireRole(entity.RoleEditor))
+
+			r.Post("/", categoryHandler.Create)
+			r.Put("/{id}", categoryHandler.Update)
+			r.Delete("/{id}", categoryHandler.Delete)
+		})
+	})
 
-	r.Post("/users", userHandler.Create)
-	r.Post("/users/generate_token", userHandler.GetJWT)
+	mailer, err := mail.New(config.Mail())
+	if err != nil {
+		panic(err)
+	}
+	loginAttemptDb := database.NewLoginAttemptDB(db)
+	go purgeLoginAttempts(loginAttemptDb, time.Second*time.Duration(config.LoginAttemptsRetention), time.Hour)
+	emailVerificationDb := database.NewEmailVerificationDB(db)
+	emailVerificationHandler := handlers.NewEmailVerificationHandler(userDb, emailVerificationDb, loginAttemptDb, config.LoginThrottle(),
+		mailer, config.AppURL, config.EmailVerificationTTL)
+	go deleteExpiredEmailVerifications(emailVerificationDb, time.Hour)
+	refreshTokenDb := database.NewRefreshTokenDB(db)
+	twoFactorHandler := handlers.NewTwoFactorHandler(userDb, database.NewTOTPDB(db), config.TOTPIssuer, config.MFAChallengeTTL, config.RequireAdmin2FA)
+	userHandler := handlers.NewUserHandler(userDb, refreshTokenDb, revokedTokenDb, loginAttemptDb, config.LoginThrottle(),
+		emailVerificationHandler, twoFactorHandler, jwtKeys, config.JWTExpiresIn, config.RefreshExpiresIn, config.RequireEmailVerification)
+	go deleteExpiredRefreshTokens(refreshTokenDb, time.Hour)
+	passwordResetDb := database.NewPasswordResetDB(db)
+	passwordHandler := handlers.NewPasswordHandler(userDb, passwordResetDb, loginAttemptDb, config.LoginThrottle(), mailer, config.PasswordResetTTL)
+	go deleteExpiredPasswordResets(passwordResetDb, time.Hour)
+
+	r.Route("/users", func(r chi.Router) {
+		r.Post("/", userHandler.Create)
+		r.Post("/generate_token", userHandler.GetJWT)
+		r.Post("/generate_token/mfa", userHandler.LoginWithMFA)
+		r.Post("/refresh_token", userHandler.RefreshToken)
+		r.Post("/password/forgot", passwordHandler.Forgot)
+		r.Post("/password/reset", passwordHandler.Reset)
+		r.Get("/verify", emailVerificationHandler.Verify)
+		r.Post("/verify/resend", emailVerificationHandler.Resend)
+
+		r.Group(func(r chi.Router) {
+			r.Use(handlers.Verifier(jwtKeys))
+			r.Use(handlers.Authenticator)
+			r.Use(handlers.RejectRevoked(revokedTokenDb, userDb))
+
+			r.Get("/me", userHandler.Me)
+			r.Put("/me/password", userHandler.ChangePassword)
+			r.Get("/me/2fa", twoFactorHandler.Status)
+			r.Post("/me/2fa/totp", twoFactorHandler.Enroll)
+			r.Get("/me/2fa/totp/qr", twoFactorHandler.QRCode)
+			r.Post("/me/2fa/totp/confirm", twoFactorHandler.Confirm)
+			r.Post("/me/2fa/recovery_codes", twoFactorHandler.RegenerateRecoveryCodes)
+			r.Post("/me/2fa/disable", twoFactorHandler.Disable)
+			r.Post("/logout", userHandler.Logout)
+			// non-admins can only find themselves, see UserHandler.FindById
+			r.Get("/{id}", userHandler.FindById)
+			r.Put("/{id}", userHandler.Update)
+			r.Delete("/{id}", userHandler.Delete)
+			r.With(handlers.RequireRole(entity.RoleAdmin)).Get("/", userHandler.GetUsers)
+			r.With(handlers.RequireRole(entity.RoleAdmin)).Get("/login_attempts", userHandler.GetLoginAttempts)
+			r.With(handlers.RequireRole(entity.RoleAdmin)).Put("/{id}/role", userHandler.SetRole)
+		})
+	})
 
 	r.Get("/docs/*", httpSwagger.Handler(httpSwagger.URL("http://localhost:8000/docs/doc.json")))
 	http.ListenAndServe(":8000", r)
 }
+
+// rotateJWTKeys periodically reloads the keys tokens are signed with, and
+// generates or deletes keys when they are due to.
+func rotateJWTKeys(keys *jwtkeys.KeySet, interval time.Duration) {
+	for range time.Tick(interval) {
+		if err := keys.Rotate(time.Now()); err != nil {
+			log.Printf("rotating JWT keys: %v", err)
+		}
+	}
+}
+
+// deleteExpiredReservations periodically removes reservations that no longer
+// hold any stock.
+func deleteExpiredReservations(db database.InventoryInterface, interval time.Duration) {
+	for range time.Tick(interval) {
+		if _, err := db.DeleteExpiredReservations(time.Now()); err != nil {
+			log.Printf("deleting expired reservations: %v", err)
+		}
+	}
+}
+
+// purgeDeletedProducts periodically removes for good the products that have
+// been in the trash for longer than the retention period.
+func purgeDeletedProducts(db database.ProductInterface, retention, interval time.Duration) {
+	for range time.Tick(interval) {
+		if _, err := db.Purge(time.Now().Add(-retention)); err != nil {
+			log.Printf("purging deleted products: %v", err)
+		}
+	}
+}
+
+// purgeLoginAttempts periodically removes the login attempts that are older
+// than the retention period.
+func purgeLoginAttempts(db database.LoginAttemptInterface, retention, interval time.Duration) {
+	for range time.Tick(interval) {
+		if _, err := db.Purge(time.Now().Add(-retention)); err != nil {
+			log.Printf("purging login attempts: %v", err)
+		}
+	}
+}
+
+// deleteExpiredRefreshTokens periodically removes refresh tokens that can no
+// longer be exchanged.
+func deleteExpiredRefreshTokens(db database.RefreshTokenInterface, interval time.Duration) {
+	for range time.Tick(interval) {
+		if _, err := db.DeleteExpired(time.Now()); err != nil {
+			log.Printf("deleting expired refresh tokens: %v", err)
+		}
+	}
+}
+
+// deleteExpiredRevokedTokens periodically forgets the revoked access tokens
+// that have expired since.
+func deleteExpiredRevokedTokens(db database.RevokedTokenInterface, interval time.Duration) {
+	for range time.Tick(interval) {
+		if _, err := db.DeleteExpired(time.Now()); err != nil {
+			log.Printf("deleting expired revoked tokens: %v", err)
+		}
+	}
+}
+
+// deleteExpiredPasswordResets periodically removes the password reset tokens
+// that can no longer be used.
+func deleteExpiredPasswordResets(db database.PasswordResetInterface, interval time.Duration) {
+	for range time.Tick(interval) {
+		if _, err := db.DeleteExpired(time.Now()); err != nil {
+			log.Printf("deleting expired password resets: %v", err)
+		}
+	}
+}
+
+// deleteExpiredEmailVerifications periodically removes the email
+// verification tokens that can no longer be used.
+func deleteExpiredEmailVerifications(db database.EmailVerificationInterface, interval time.Duration) {
+	for range time.Tick(interval) {
+		if _, err := db.DeleteExpired(time.Now()); err != nil {
+			log.Printf("deleting expired email verifications: %v", err)
+		}
+	}
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/brenoproti/go-api/configs"
+	"github.com/brenoproti/go-api/internal/entity"
+	"github.com/brenoproti/go-api/internal/infra/database"
+)
+
+const usage = `usage: users [-config dir] <command>
+
+commands:
+  set-role <email> <role>   grant a user the viewer, editor or admin role
+`
+
+// users manages accounts outside of the API, starting with granting the
+// first admin, who can then set the roles of everyone else.
+func main() {
+	configDir := flag.String("config", "cmd/server", "directory containing the .env file")
+	flag.Usage = func() { fmt.Fprint(os.Stderr, usage) }
+	flag.Parse()
+	if flag.NArg() == 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	config := configs.LoadConfig(*configDir)
+	db, err := database.Open(config.Database())
+	if err != nil {
+		fail(err)
+	}
+	userDb := database.NewUser(db)
+
+	switch flag.Arg(0) {
+	case "set-role":
+		if flag.NArg() != 3 {
+			flag.Usage()
+			os.Exit(2)
+		}
+		err = setRole(userDb, flag.Arg(1), flag.Arg(2))
+	default:
+		flag.Usage()
+		os.Exit(2)
+	}
+	if err != nil {
+		fail(err)
+	}
+}
+
+func setRole(db database.UserInterface, email, role string) error {
+	r, err := entity.ParseRole(role)
+	if err != nil {
+		return fmt.Errorf("%w %q", err, role)
+	}
+	user, err := db.FindByEmail(email)
+	if err != nil {
+		return fmt.Errorf("finding user %s: %w", email, err)
+	}
+	if _, err := db.SetRole(user.ID.String(), r); err != nil {
+		return err
+	}
+	fmt.Printf("%s is now %s\n", user.Email, r)
+	return nil
+}
+
+func fail(err error) {
+	fmt.Fprintln(os.Stderr, "users:", err)
+	os.Exit(1)
+}
@@ -1,23 +1,66 @@
 package configs
 
 import (
-	"github.com/go-chi/jwtauth"
+	"strings"
+	"time"
+
+	"github.com/brenoproti/go-api/internal/entity"
+	"github.com/brenoproti/go-api/internal/infra/database"
+	"github.com/brenoproti/go-api/internal/infra/mail"
+	"github.com/brenoproti/go-api/pkg/jwtkeys"
 	"github.com/spf13/viper"
 )
 
 var cfg *conf
 
 type conf struct {
-	DBDriver      string `mapstructure:"DB_DRIVER"`
-	DBHost        string `mapstructure:"DB_HOST"`
-	DBPort        string `mapstructure:"DB_PORT"`
-	DBUser        string `mapstructure:"DB_USER"`
-	DBPassword    string `mapstructure:"DB_PASSWORD"`
-	DBName        string `mapstructure:"DB_NAME"`
-	WebServerPort string `mapstructure:"WEB_SERVER_PORT"`
-	JWTSecret     string `mapstructure:"JWT_SECRET"`
-	JWTExpiresIn  int    `mapstructure:"JWT_EXPIRES_IN"`
-	TokenAuth     *jwtauth.JWTAuth
+	DBDriver                 string `mapstructure:"DB_DRIVER"`
+	DBHost                   string `mapstructure:"DB_HOST"`
+	DBPort                   string `mapstructure:"DB_PORT"`
+	DBUser                   string `mapstructure:"DB_USER"`
+	DBPassword               string `mapstructure:"DB_PASSWORD"`
+	DBName                   string `mapstructure:"DB_NAME"`
+	DBMaxOpenConns           int    `mapstructure:"DB_MAX_OPEN_CONNS"`
+	DBMaxIdleConns           int    `mapstructure:"DB_MAX_IDLE_CONNS"`
+	DBConnMaxLifetime        int    `mapstructure:"DB_CONN_MAX_LIFETIME"`
+	WebServerPort            string `mapstructure:"WEB_SERVER_PORT"`
+	TrustedProxies           string `mapstructure:"TRUSTED_PROXIES"`
+	JWTAlgorithm             string `mapstructure:"JWT_ALGORITHM"`
+	JWTSecret                string `mapstructure:"JWT_SECRET"`
+	JWTKeysDir               string `mapstructure:"JWT_KEYS_DIR"`
+	JWTKeyRotation           int    `mapstructure:"JWT_KEY_ROTATION"`
+	JWKSMaxAge               int    `mapstructure:"JWKS_MAX_AGE"`
+	JWTExpiresIn             int    `mapstructure:"JWT_EXPIRES_IN"`
+	RefreshExpiresIn         int    `mapstructure:"REFRESH_TOKEN_EXPIRES_IN"`
+	ReservationTTL           int    `mapstructure:"RESERVATION_TTL"`
+	ReservationMaxTTL        int    `mapstructure:"RESERVATION_MAX_TTL"`
+	CursorSecret             string `mapstructure:"CURSOR_SECRET"`
+	TrashRetention           int    `mapstructure:"TRASH_RETENTION"`
+	PasswordResetTTL         int    `mapstructure:"PASSWORD_RESET_TTL"`
+	PasswordMinLength        int    `mapstructure:"PASSWORD_MIN_LENGTH"`
+	PasswordRequireUpper     bool   `mapstructure:"PASSWORD_REQUIRE_UPPER"`
+	PasswordRequireLower     bool   `mapstructure:"PASSWORD_REQUIRE_LOWER"`
+	PasswordRequireDigit     bool   `mapstructure:"PASSWORD_REQUIRE_DIGIT"`
+	PasswordRequireSymbol    bool   `mapstructure:"PASSWORD_REQUIRE_SYMBOL"`
+	AppURL                   string `mapstructure:"APP_URL"`
+	EmailVerificationTTL     int    `mapstructure:"EMAIL_VERIFICATION_TTL"`
+	LoginMaxEmailFailures    int    `mapstructure:"LOGIN_MAX_EMAIL_FAILURES"`
+	LoginMaxIPFailures       int    `mapstructure:"LOGIN_MAX_IP_FAILURES"`
+	LoginBackoffBase         int    `mapstructure:"LOGIN_BACKOFF_BASE"`
+	LoginBackoffMax          int    `mapstructure:"LOGIN_BACKOFF_MAX"`
+	LoginLockout             int    `mapstructure:"LOGIN_LOCKOUT"`
+	LoginAttemptsRetention   int    `mapstructure:"LOGIN_ATTEMPTS_RETENTION"`
+	TOTPIssuer               string `mapstructure:"TOTP_ISSUER"`
+	MFAChallengeTTL          int    `mapstructure:"MFA_CHALLENGE_TTL"`
+	RequireAdmin2FA          bool   `mapstructure:"REQUIRE_ADMIN_2FA"`
+	RequireEmailVerification bool   `mapstructure:"REQUIRE_EMAIL_VERIFICATION"`
+	MailDriver               string `mapstructure:"MAIL_DRIVER"`
+	MailFrom                 string `mapstructure:"MAIL_FROM"`
+	MailOutboxDir            string `mapstructure:"MAIL_OUTBOX_DIR"`
+	SMTPHost                 string `mapstructure:"SMTP_HOST"`
+	SMTPPort                 string `mapstructure:"SMTP_PORT"`
+	SMTPUser                 string `mapstructure:"SMTP_USER"`
+	SMTPPassword             string `mapstructure:"SMTP_PASSWORD"`
 }
 
 func LoadConfig(path string) *conf {
@@ -33,6 +76,78 @@ func LoadConfig(path string) *conf {
 	if err != nil {
 		panic(err)
 	}
-	cfg.TokenAuth = jwtauth.New("HS256", []byte(cfg.JWTSecret), nil)
 	return cfg
 }
+
+func (c *conf) Database() database.Config {
+	return database.Config{
+		Driver:          c.DBDriver,
+		Host:            c.DBHost,
+		Port:            c.DBPort,
+		User:            c.DBUser,
+		Password:        c.DBPassword,
+		Name:            c.DBName,
+		MaxOpenConns:    c.DBMaxOpenConns,
+		MaxIdleConns:    c.DBMaxIdleConns,
+		ConnMaxLifetime: time.Second * time.Duration(c.DBConnMaxLifetime),
+	}
+}
+
+// JWTKeys publishes the generated keys for as long as the JWK set is
+// cached, and keeps the replaced keys for as long as the tokens they signed
+// may still be valid.
+func (c *conf) JWTKeys() jwtkeys.Config {
+	retention := c.JWTExpiresIn
+	if c.MFAChallengeTTL > retention {
+		retention = c.MFAChallengeTTL
+	}
+	return jwtkeys.Config{
+		Algorithm: c.JWTAlgorithm,
+		Secret:    c.JWTSecret,
+		Dir:       c.JWTKeysDir,
+		Rotation:  time.Second * time.Duration(c.JWTKeyRotation),
+		Lead:      time.Second * time.Duration(c.JWKSMaxAge),
+		Retention: time.Second * time.Duration(retention),
+	}
+}
+
+func (c *conf) PasswordPolicy() entity.PasswordPolicy {
+	return entity.PasswordPolicy{
+		MinLength:     c.PasswordMinLength,
+		RequireUpper:  c.PasswordRequireUpper,
+		RequireLower:  c.PasswordRequireLower,
+		RequireDigit:  c.PasswordRequireDigit,
+		RequireSymbol: c.PasswordRequireSymbol,
+	}
+}
+
+func (c *conf) LoginThrottle() entity.LoginThrottle {
+	return entity.LoginThrottle{
+		MaxEmailFailures: c.LoginMaxEmailFailures,
+		MaxIPFailures:    c.LoginMaxIPFailures,
+		BaseDelay:        time.Second * time.Duration(c.LoginBackoffBase),
+		MaxDelay:         time.Second * time.Duration(c.LoginBackoffMax),
+		Lockout:          time.Second * time.Duration(c.LoginLockout),
+	}
+}
+
+// Proxies lists the comma-separated addresses or CIDR ranges of the proxies
+// whose X-Forwarded-For headers are believed, see handlers.RealIP.
+func (c *conf) Proxies() []string {
+	if strings.TrimSpace(c.TrustedProxies) == "" {
+		return nil
+	}
+	return strings.Split(c.TrustedProxies, ",")
+}
+
+func (c *conf) Mail() mail.Config {
+	return mail.Config{
+		Driver:    c.MailDriver,
+		Host:      c.SMTPHost,
+		Port:      c.SMTPPort,
+		User:      c.SMTPUser,
+		Password:  c.SMTPPassword,
+		From:      c.MailFrom,
+		OutboxDir: c.MailOutboxDir,
+	}
+}
@@ -1,17 +1,156 @@
 package dto
 
+import (
+	"time"
+
+	"github.com/brenoproti/go-api/pkg/entity"
+)
+
 type ProductDTO struct {
-	Name  string  `json:"name"`
-	Price float64 `json:"price"`
+	Name  string       `json:"name" validate:"required,max=255"`
+	Price entity.Money `json:"price" validate:"required,positive"`
 }
 
 type UserDTO struct {
-	Name     string `json:"name" required:"true"`
-	Email    string `json:"email"`
-	Password string `json:"password"`
+	Name     string `json:"name" validate:"required,max=100"`
+	Email    string `json:"email" validate:"required,email,max=255"`
+	Password string `json:"password" validate:"required,max=72"`
+}
+
+type UpdateUserDTO struct {
+	Name  string `json:"name" validate:"required,max=100"`
+	Email string `json:"email" validate:"required,email,max=255"`
+}
+
+type RoleDTO struct {
+	Role string `json:"role" validate:"required" enums:"viewer,editor,admin"`
+}
+
+type ForgotPasswordDTO struct {
+	Email string `json:"email" validate:"required,email"`
+}
+
+type ResetPasswordDTO struct {
+	Token    string `json:"token" validate:"required"`
+	Password string `json:"password" validate:"required,max=72"`
+}
+
+type ChangePasswordDTO struct {
+	CurrentPassword string `json:"current_password" validate:"required"`
+	NewPassword     string `json:"new_password" validate:"required,max=72"`
+}
+
+type ResendVerificationDTO struct {
+	Email string `json:"email" validate:"required,email"`
 }
 
 type LoginDTO struct {
-	Email    string `json:"email"`
-	Password string `json:"password"`
+	Email    string `json:"email" validate:"required"`
+	Password string `json:"password" validate:"required"`
+}
+
+type TokenDTO struct {
+	AccessToken  string `json:"access_token"`
+	RefreshToken string `json:"refresh_token"`
+	TokenType    string `json:"token_type"`
+	ExpiresIn    int    `json:"expires_in"`
+}
+
+type MFAChallengeDTO struct {
+	MFAToken  string `json:"mfa_token"`
+	ExpiresIn int    `json:"expires_in"`
+}
+
+type MFALoginDTO struct {
+	MFAToken string `json:"mfa_token" validate:"required"`
+	Code     string `json:"code" validate:"required,max=20"`
+}
+
+type TwoFactorStatusDTO struct {
+	Enabled           bool       `json:"enabled"`
+	ConfirmedAt       *time.Time `json:"confirmed_at"`
+	RecoveryCodesLeft int64      `json:"recovery_codes_left"`
+}
+
+type TOTPEnrollmentDTO struct {
+	Secret     string `json:"secret"`
+	OTPAuthURL string `json:"otpauth_url"`
+}
+
+type TwoFactorPasswordDTO struct {
+	Password string `json:"password" validate:"required"`
+	Code     string `json:"code" validate:"required,max=20"`
+}
+
+type RecoveryCodesDTO struct {
+	RecoveryCodes []string `json:"recovery_codes"`
+}
+
+// JWKSDTO is an RFC 7517 JSON Web Key Set. Besides kid, alg and use, each key
+// holds the fields of its type, such as n and e for RSA keys.
+type JWKSDTO struct {
+	Keys []map[string]interface{} `json:"keys"`
+}
+
+type RefreshTokenDTO struct {
+	RefreshToken string `json:"refresh_token" validate:"required"`
+}
+
+type LogoutDTO struct {
+	RefreshToken string `json:"refresh_token"`
+}
+
+type CategoryDTO struct {
+	Name     string  `json:"name" validate:"required,max=255"`
+	ParentID *string `json:"parent_id" validate:"uuid"`
+}
+
+type ProductCategoriesDTO struct {
+	CategoryIDs []string `json:"category_ids"`
+}
+
+type StockDTO struct {
+	Quantity int `json:"quantity" validate:"min=1"`
+}
+
+type ReservationDTO struct {
+	Quantity int `json:"quantity" validate:"min=1"`
+	TTL      int `json:"ttl" validate:"min=0"`
+}
+
+// PageDTO wraps one page of a listing along with what is needed to navigate
+// the rest of it.
+type PageDTO struct {
+	Data       interface{} `json:"data"`
+	Total      int64       `json:"total"`
+	Page       int         `json:"page"`
+	Limit      int         `json:"limit"`
+	TotalPages int         `json:"total_pages"`
+	Next       *string     `json:"next"`
+	Prev       *string     `json:"prev"`
+}
+
+// CursorPageDTO is a page of a keyset listing. NextCursor is null on the last
+// page.
+type CursorPageDTO struct {
+	Data       interface{} `json:"data"`
+	Limit      int         `json:"limit"`
+	NextCursor *string     `json:"next_cursor"`
+}
+
+// ProblemDTO is an RFC 7807 problem details body, returned with the
+// application/problem+json content type whenever a request fails.
+type ProblemDTO struct {
+	Type     string          `json:"type" example:"/problems/validation-error"`
+	Title    string          `json:"title" example:"Validation failed"`
+	Status   int             `json:"status" example:"400"`
+	Detail   string          `json:"detail,omitempty" example:"name is required"`
+	Instance string          `json:"instance,omitempty" example:"/products"`
+	Errors   []FieldErrorDTO `json:"errors,omitempty"`
+}
+
+// FieldErrorDTO tells which field of the request was rejected and why.
+type FieldErrorDTO struct {
+	Field   string `json:"field" example:"name"`
+	Message string `json:"message" example:"name is required"`
 }
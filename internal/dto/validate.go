@@ -0,0 +1,181 @@
+package dto
+
+import (
+	"fmt"
+	"net/mail"
+	"reflect"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/brenoproti/go-api/pkg/entity"
+)
+
+// Rule checks a field against the parameter of its validate tag, e.g. "8" in
+// "min=8", and returns what is wrong with it, or an empty string when the
+// value is valid.
+type Rule func(value reflect.Value, param string) string
+
+var rules = map[string]Rule{
+	"email": email,
+	"min":   minimum,
+	"max":   maximum,
+	"uuid":  uuid,
+}
+
+// RegisterRule adds a rule that validate tags can refer to by name. It is
+// meant to be called from init functions, before any validation runs.
+func RegisterRule(name string, rule Rule) {
+	rules[name] = rule
+}
+
+func init() {
+	RegisterRule("positive", positive)
+}
+
+// Validate checks a struct, or a pointer to one, against the comma separated
+// rules in the validate tags of its fields, such as `validate:"required,max=255"`.
+// Fields are named after their JSON key and nested structs are validated
+// too. It returns one error for every invalid field, in field order.
+func Validate(v interface{}) []FieldErrorDTO {
+	value := reflect.ValueOf(v)
+	for value.Kind() == reflect.Pointer {
+		if value.IsNil() {
+			return nil
+		}
+		value = value.Elem()
+	}
+	if value.Kind() != reflect.Struct {
+		return nil
+	}
+	return validateStruct(value, "")
+}
+
+func validateStruct(value reflect.Value, prefix string) []FieldErrorDTO {
+	var errs []FieldErrorDTO
+	for i := 0; i < value.NumField(); i++ {
+		field := value.Type().Field(i)
+		if !field.IsExported() {
+			continue
+		}
+		name := prefix + fieldName(field)
+		if message := validateField(value.Field(i), field.Tag.Get("validate")); message != "" {
+			errs = append(errs, FieldErrorDTO{Field: name, Message: name + " " + message})
+			continue
+		}
+		nested := reflect.Indirect(value.Field(i))
+		if nested.Kind() == reflect.Struct {
+			errs = append(errs, validateStruct(nested, name+".")...)
+		}
+	}
+	return errs
+}
+
+// validateField returns the complaint of the first rule the value breaks.
+// Apart from required, rules skip nil pointers and empty strings, so that
+// optional fields are only checked when they are given.
+func validateField(value reflect.Value, tag string) string {
+	if tag == "" || tag == "-" {
+		return ""
+	}
+	for _, rule := range strings.Split(tag, ",") {
+		name, param, _ := strings.Cut(strings.TrimSpace(rule), "=")
+		if name == "required" {
+			if value.IsZero() {
+				return "is required"
+			}
+			continue
+		}
+		if value.Kind() == reflect.Pointer {
+			if value.IsNil() {
+				return ""
+			}
+			value = value.Elem()
+		}
+		if value.Kind() == reflect.String && value.Len() == 0 {
+			return ""
+		}
+		check, ok := rules[name]
+		if !ok {
+			panic(fmt.Sprintf("dto: unknown validation rule %q", name))
+		}
+		if message := check(value, param); message != "" {
+			return message
+		}
+	}
+	return ""
+}
+
+func fieldName(field reflect.StructField) string {
+	name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+	if name == "" || name == "-" {
+		return field.Name
+	}
+	return name
+}
+
+func email(value reflect.Value, _ string) string {
+	address, err := mail.ParseAddress(value.String())
+	if err != nil || address.Address != value.String() {
+		return "must be a valid email address"
+	}
+	return ""
+}
+
+func minimum(value reflect.Value, param string) string {
+	return compare(value, param, func(size, limit float64) bool { return size >= limit }, "at least")
+}
+
+func maximum(value reflect.Value, param string) string {
+	return compare(value, param, func(size, limit float64) bool { return size <= limit }, "at most")
+}
+
+// compare checks the length of strings, slices and maps, or the value of
+// numbers, against the limit given as parameter.
+func compare(value reflect.Value, param string, ok func(size, limit float64) bool, bound string) string {
+	limit, err := strconv.ParseFloat(param, 64)
+	if err != nil {
+		panic(fmt.Sprintf("dto: invalid limit %q", param))
+	}
+	var size float64
+	unit := ""
+	switch value.Kind() {
+	case reflect.String:
+		size, unit = float64(utf8.RuneCountInString(value.String())), " characters"
+	case reflect.Slice, reflect.Map, reflect.Array:
+		size, unit = float64(value.Len()), " items"
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		size = float64(value.Int())
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		size = float64(value.Uint())
+	case reflect.Float32, reflect.Float64:
+		size = value.Float()
+	default:
+		panic(fmt.Sprintf("dto: cannot compare %s", value.Type()))
+	}
+	if ok(size, limit) {
+		return ""
+	}
+	return "must be " + bound + " " + param + unit
+}
+
+func uuid(value reflect.Value, _ string) string {
+	if _, err := entity.ParseID(value.String()); err != nil {
+		return "must be a valid UUID"
+	}
+	return ""
+}
+
+// positive accepts numbers and amounts of money greater than zero.
+func positive(value reflect.Value, _ string) string {
+	if money, ok := value.Interface().(entity.Money); ok {
+		if money.IsNegative() || money.IsZero() {
+			return "must be positive"
+		}
+		return ""
+	}
+	if compare(value, "0", func(size, limit float64) bool { return size > limit }, "") != "" {
+		return "must be positive"
+	}
+	return ""
+}
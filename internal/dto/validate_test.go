@@ -0,0 +1,68 @@
+package dto
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/brenoproti/go-api/pkg/entity"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidateReturnsEveryInvalidField(t *testing.T) {
+	errs := Validate(&UserDTO{Email: "not an email", Password: string(make([]byte, 73))})
+	assert.Equal(t, []FieldErrorDTO{
+		{Field: "name", Message: "name is required"},
+		{Field: "email", Message: "email must be a valid email address"},
+		{Field: "password", Message: "password must be at most 72 characters"},
+	}, errs)
+
+	assert.Empty(t, Validate(UserDTO{Name: "John", Email: "j@j.com", Password: "123456"}))
+}
+
+func TestValidateNumbersAndMoney(t *testing.T) {
+	assert.Equal(t, []FieldErrorDTO{
+		{Field: "quantity", Message: "quantity must be at least 1"},
+		{Field: "ttl", Message: "ttl must be at least 0"},
+	}, Validate(ReservationDTO{Quantity: 0, TTL: -1}))
+
+	assert.Equal(t, []FieldErrorDTO{
+		{Field: "name", Message: "name is required"},
+		{Field: "price", Message: "price is required"},
+	}, Validate(ProductDTO{}))
+	assert.Equal(t, []FieldErrorDTO{
+		{Field: "price", Message: "price must be positive"},
+	}, Validate(ProductDTO{Name: "Product", Price: entity.NewMoney(-100, "USD")}))
+	assert.Empty(t, Validate(ProductDTO{Name: "Product", Price: entity.NewMoney(100, "USD")}))
+}
+
+func TestValidateSkipsOptionalFields(t *testing.T) {
+	assert.Empty(t, Validate(CategoryDTO{Name: "Category"}))
+	empty := ""
+	assert.Empty(t, Validate(CategoryDTO{Name: "Category", ParentID: &empty}))
+	invalid := "parent"
+	assert.Equal(t, []FieldErrorDTO{
+		{Field: "parent_id", Message: "parent_id must be a valid UUID"},
+	}, Validate(CategoryDTO{Name: "Category", ParentID: &invalid}))
+}
+
+func TestValidateWithCustomRule(t *testing.T) {
+	RegisterRule("even", func(value reflect.Value, _ string) string {
+		if value.Int()%2 != 0 {
+			return "must be even"
+		}
+		return ""
+	})
+	type request struct {
+		Count  int `json:"count" validate:"even"`
+		Nested struct {
+			Size int `json:"size" validate:"min=1,even"`
+		} `json:"nested"`
+	}
+	var v request
+	v.Count = 3
+	v.Nested.Size = 3
+	assert.Equal(t, []FieldErrorDTO{
+		{Field: "count", Message: "count must be even"},
+		{Field: "nested.size", Message: "nested.size must be even"},
+	}, Validate(v))
+}
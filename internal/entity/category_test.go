@@ -0,0 +1,50 @@
+package entity
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewCategory(t *testing.T) {
+	c, err := NewCategory("Electronics", nil)
+	assert.Nil(t, err)
+	assert.NotNil(t, c)
+	assert.NotEmpty(t, c.ID)
+	assert.Equal(t, "Electronics", c.Name)
+	assert.Nil(t, c.ParentID)
+
+	child, err := NewCategory("Phones", &c.ID)
+	assert.Nil(t, err)
+	assert.Equal(t, c.ID, *child.ParentID)
+}
+
+func TestCategoryWhenNameIsRequired(t *testing.T) {
+	c, err := NewCategory("", nil)
+	assert.Nil(t, c)
+	assert.Equal(t, ErrNameIsRequired, err)
+}
+
+func TestCategoryWhenParentIsItself(t *testing.T) {
+	c, err := NewCategory("Electronics", nil)
+	assert.Nil(t, err)
+	c.ParentID = &c.ID
+	assert.Equal(t, ErrInvalidParent, c.Validate())
+}
+
+func TestCategoryTree(t *testing.T) {
+	electronics, _ := NewCategory("Electronics", nil)
+	phones, _ := NewCategory("Phones", &electronics.ID)
+	android, _ := NewCategory("Android", &phones.ID)
+	books, _ := NewCategory("Books", nil)
+
+	tree := CategoryTree([]Category{*android, *electronics, *books, *phones})
+	assert.Len(t, tree, 2)
+	assert.Equal(t, "Electronics", tree[0].Name)
+	assert.Equal(t, "Books", tree[1].Name)
+	assert.Len(t, tree[0].Children, 1)
+	assert.Equal(t, "Phones", tree[0].Children[0].Name)
+	assert.Len(t, tree[0].Children[0].Children, 1)
+	assert.Equal(t, "Android", tree[0].Children[0].Children[0].Name)
+	assert.Empty(t, tree[1].Children)
+}
@@ -0,0 +1,66 @@
+package entity
+
+import (
+	"errors"
+	"time"
+
+	"github.com/brenoproti/go-api/pkg/entity"
+)
+
+var ErrInvalidVerificationToken = errors.New("invalid or expired email verification token")
+
+// EmailVerification proves the user owns Email once they send back the
+// token emailed to that address. Only the hash of the token is stored, and
+// the token can only be used once.
+type EmailVerification struct {
+	ID        entity.ID  `json:"id"`
+	UserID    entity.ID  `json:"user_id"`
+	Email     string     `json:"email"`
+	TokenHash string     `json:"-"`
+	ExpiresAt time.Time  `json:"expires_at"`
+	CreatedAt time.Time  `json:"created_at"`
+	UsedAt    *time.Time `json:"used_at"`
+}
+
+// NewEmailVerification returns the token to email along with the entity to
+// store.
+func NewEmailVerification(user *User, ttl time.Duration) (*EmailVerification, string, error) {
+	token, err := newSecretToken()
+	if err != nil {
+		return nil, "", err
+	}
+	now := time.Now()
+	v := &EmailVerification{
+		ID:        entity.NewID(),
+		UserID:    user.ID,
+		Email:     user.Email,
+		TokenHash: HashToken(token),
+		ExpiresAt: now.Add(ttl),
+		CreatedAt: now,
+	}
+	if err := v.Validate(); err != nil {
+		return nil, "", err
+	}
+	return v, token, nil
+}
+
+func (v *EmailVerification) Validate() error {
+	if v.ID == (entity.ID{}) {
+		return ErrIdIsRequired
+	}
+	if v.UserID == (entity.ID{}) {
+		return ErrUserIdIsRequired
+	}
+	if v.Email == "" {
+		return ErrEmailIsRequired
+	}
+	if !v.ExpiresAt.After(v.CreatedAt) {
+		return ErrInvalidExpiration
+	}
+	return nil
+}
+
+// IsUsable tells whether the token can still verify the email.
+func (v *EmailVerification) IsUsable(now time.Time) bool {
+	return v.UsedAt == nil && now.Before(v.ExpiresAt)
+}
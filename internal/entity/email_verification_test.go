@@ -0,0 +1,29 @@
+package entity
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewEmailVerification(t *testing.T) {
+	user, _ := NewUser("John Doe", "email@email.com", "Str0ng-Passw0rd")
+	verification, token, err := NewEmailVerification(user, time.Hour)
+	assert.Nil(t, err)
+	assert.NotEmpty(t, token)
+	assert.Equal(t, user.ID, verification.UserID)
+	assert.Equal(t, "email@email.com", verification.Email)
+	assert.Equal(t, HashToken(token), verification.TokenHash)
+	assert.True(t, verification.IsUsable(time.Now()))
+	assert.False(t, verification.IsUsable(time.Now().Add(time.Hour)))
+}
+
+func TestEmailVerificationWhenInvalid(t *testing.T) {
+	user, _ := NewUser("John Doe", "email@email.com", "Str0ng-Passw0rd")
+	_, _, err := NewEmailVerification(user, 0)
+	assert.Equal(t, ErrInvalidExpiration, err)
+	user.Email = ""
+	_, _, err = NewEmailVerification(user, time.Hour)
+	assert.Equal(t, ErrEmailIsRequired, err)
+}
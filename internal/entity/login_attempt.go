@@ -0,0 +1,163 @@
+package entity
+
+import (
+	"errors"
+	"time"
+
+	"github.com/brenoproti/go-api/pkg/entity"
+)
+
+var ErrInvalidLoginResult = errors.New("invalid login result")
+
+type LoginResult string
+
+const (
+	// LoginSucceeded means the password was right.
+	LoginSucceeded LoginResult = "succeeded"
+	LoginFailed    LoginResult = "failed"
+	// LoginThrottled means the password was not even checked, because of
+	// too many failures.
+	LoginThrottled LoginResult = "throttled"
+	// LoginMFARequired means the password was right but a two-factor code
+	// is still needed. It does not start the failures over.
+	LoginMFARequired LoginResult = "mfa_required"
+	// LoginPending means the password is being checked. Pending attempts
+	// count as failures, so that concurrent guesses cannot all be checked
+	// before the first failure is recorded.
+	LoginPending LoginResult = "pending"
+	// LoginErrored means the attempt was cut short by an error of the server
+	// before the password or code turned out wrong. It is not a failure.
+	LoginErrored LoginResult = "errored"
+	// LoginResetRequested and LoginVerifyRequested are not logins but asking
+	// for a password reset or verification email. They are throttled like
+	// failed logins, whether the email belongs to a user or not.
+	LoginResetRequested  LoginResult = "reset_requested"
+	LoginVerifyRequested LoginResult = "verify_requested"
+)
+
+func (r LoginResult) IsValid() bool {
+	switch r {
+	case LoginSucceeded, LoginFailed, LoginThrottled, LoginMFARequired, LoginPending, LoginErrored,
+		LoginResetRequested, LoginVerifyRequested:
+		return true
+	}
+	return false
+}
+
+// ThrottledOn is the results of the earlier attempts that count against an
+// attempt with this result: the requests of the same kind for emails, the
+// failed and pending logins otherwise.
+func (r LoginResult) ThrottledOn() []LoginResult {
+	switch r {
+	case LoginResetRequested, LoginVerifyRequested:
+		return []LoginResult{r}
+	}
+	return []LoginResult{LoginFailed, LoginPending}
+}
+
+// maxUserAgentLength is the size of the user_agent column.
+const maxUserAgentLength = 255
+
+// LoginAttempt records who tried to log in, or asked to be emailed, from where,
+// and how it went. The failures are what logins get throttled on, see
+// LoginThrottle. UserID is only set when the email belongs to a user.
+type LoginAttempt struct {
+	ID        entity.ID   `json:"id"`
+	Email     string      `json:"email"`
+	UserID    *entity.ID  `json:"user_id" swaggertype:"string"`
+	IP        string      `json:"ip"`
+	UserAgent string      `json:"user_agent"`
+	Result    LoginResult `json:"result" enums:"succeeded,failed,throttled,mfa_required,pending,errored,reset_requested,verify_requested"`
+	CreatedAt time.Time   `json:"created_at"`
+}
+
+func NewLoginAttempt(email, ip, userAgent string, userID *entity.ID, result LoginResult) (*LoginAttempt, error) {
+	if len(userAgent) > maxUserAgentLength {
+		userAgent = userAgent[:maxUserAgentLength]
+	}
+	a := &LoginAttempt{
+		ID:        entity.NewID(),
+		Email:     NormalizeEmail(email),
+		UserID:    userID,
+		IP:        ip,
+		UserAgent: userAgent,
+		Result:    result,
+		CreatedAt: time.Now(),
+	}
+	if err := a.Validate(); err != nil {
+		return nil, err
+	}
+	return a, nil
+}
+
+func (a *LoginAttempt) Validate() error {
+	if a.ID == (entity.ID{}) {
+		return ErrIdIsRequired
+	}
+	if a.Email == "" {
+		return ErrEmailIsRequired
+	}
+	if !a.Result.IsValid() {
+		return ErrInvalidLoginResult
+	}
+	return nil
+}
+
+// LoginFailures sums up the recent failed logins with an email or from an IP
+// address.
+type LoginFailures struct {
+	Count int64
+	Last  time.Time
+}
+
+// LoginThrottle slows down password guessing. Each failure doubles the time
+// to wait before the next attempt, from BaseDelay up to MaxDelay, and once
+// there are as many failures as allowed, logins are locked out for Lockout.
+// Failures older than Lockout are forgotten.
+type LoginThrottle struct {
+	MaxEmailFailures int
+	MaxIPFailures    int
+	BaseDelay        time.Duration
+	MaxDelay         time.Duration
+	Lockout          time.Duration
+}
+
+// Since is when the failures that still count started.
+func (t LoginThrottle) Since(now time.Time) time.Time {
+	return now.Add(-t.Lockout)
+}
+
+// RetryAfter is how long to wait before trying to log in again, zero when
+// it can be done right away.
+func (t LoginThrottle) RetryAfter(byEmail, byIP LoginFailures, now time.Time) time.Duration {
+	wait := t.retryAfter(byEmail, t.MaxEmailFailures, now)
+	if ipWait := t.retryAfter(byIP, t.MaxIPFailures, now); ipWait > wait {
+		wait = ipWait
+	}
+	return wait
+}
+
+func (t LoginThrottle) retryAfter(failures LoginFailures, max int, now time.Time) time.Duration {
+	if failures.Count == 0 {
+		return 0
+	}
+	delay := t.Lockout
+	if max <= 0 || failures.Count < int64(max) {
+		delay = t.backoff(failures.Count)
+	}
+	if wait := failures.Last.Add(delay).Sub(now); wait > 0 {
+		return wait
+	}
+	return 0
+}
+
+func (t LoginThrottle) backoff(failures int64) time.Duration {
+	delay := t.BaseDelay
+	for i := int64(1); i < failures && delay < t.MaxDelay; i++ {
+		delay *= 2
+	}
+	if delay > t.MaxDelay {
+		delay = t.MaxDelay
+	}
+	return delay
+}
@@ -0,0 +1,54 @@
+package entity
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/brenoproti/go-api/pkg/entity"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewLoginAttempt(t *testing.T) {
+	userID := entity.NewID()
+	attempt, err := NewLoginAttempt(" J@J.com", "10.0.0.1", strings.Repeat("a", 300), &userID, LoginFailed)
+	assert.Nil(t, err)
+	assert.NotEmpty(t, attempt.ID)
+	assert.Equal(t, "j@j.com", attempt.Email)
+	assert.Equal(t, &userID, attempt.UserID)
+	assert.Equal(t, "10.0.0.1", attempt.IP)
+	assert.Len(t, attempt.UserAgent, 255)
+	assert.Equal(t, LoginFailed, attempt.Result)
+
+	_, err = NewLoginAttempt("", "10.0.0.1", "", nil, LoginFailed)
+	assert.Equal(t, ErrEmailIsRequired, err)
+	_, err = NewLoginAttempt("j@j.com", "10.0.0.1", "", nil, "blocked")
+	assert.Equal(t, ErrInvalidLoginResult, err)
+}
+
+func TestLoginThrottle(t *testing.T) {
+	throttle := LoginThrottle{
+		MaxEmailFailures: 3,
+		MaxIPFailures:    10,
+		BaseDelay:        time.Second,
+		MaxDelay:         5 * time.Second,
+		Lockout:          time.Minute,
+	}
+	now := time.Now()
+	none := LoginFailures{}
+	assert.Zero(t, throttle.RetryAfter(none, none, now))
+	assert.Equal(t, now.Add(-time.Minute), throttle.Since(now))
+
+	// backoff doubles with each failure
+	assert.Equal(t, time.Second, throttle.RetryAfter(LoginFailures{Count: 1, Last: now}, none, now))
+	assert.Equal(t, 2*time.Second, throttle.RetryAfter(LoginFailures{Count: 2, Last: now}, none, now))
+	assert.Equal(t, time.Second, throttle.RetryAfter(LoginFailures{Count: 2, Last: now.Add(-time.Second)}, none, now))
+	assert.Zero(t, throttle.RetryAfter(LoginFailures{Count: 2, Last: now.Add(-3 * time.Second)}, none, now))
+	// up to the maximum delay
+	assert.Equal(t, 5*time.Second, throttle.RetryAfter(none, LoginFailures{Count: 9, Last: now}, now))
+
+	// then it locks out
+	assert.Equal(t, time.Minute, throttle.RetryAfter(LoginFailures{Count: 3, Last: now}, none, now))
+	assert.Equal(t, time.Minute, throttle.RetryAfter(none, LoginFailures{Count: 10, Last: now}, now))
+	assert.Equal(t, time.Minute, throttle.RetryAfter(LoginFailures{Count: 1, Last: now}, LoginFailures{Count: 10, Last: now}, now))
+}
@@ -0,0 +1,99 @@
+package entity
+
+import (
+	"bufio"
+	_ "embed"
+	"errors"
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+var (
+	ErrWeakPassword    = errors.New("password is too weak")
+	ErrCommonPassword  = errors.New("password is too common")
+	ErrPasswordTooLong = errors.New("password cannot be longer than 72 bytes")
+)
+
+// maxPasswordBytes is as much as bcrypt hashes.
+const maxPasswordBytes = 72
+
+//go:embed common_passwords.txt
+var commonPasswordsFile string
+
+var commonPasswords = parseCommonPasswords(commonPasswordsFile)
+
+func parseCommonPasswords(file string) map[string]bool {
+	passwords := map[string]bool{}
+	scanner := bufio.NewScanner(strings.NewReader(file))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		passwords[strings.ToLower(line)] = true
+	}
+	return passwords
+}
+
+// PasswordPolicy is what passwords have to be made of. Common passwords are
+// always rejected.
+type PasswordPolicy struct {
+	MinLength     int
+	RequireUpper  bool
+	RequireLower  bool
+	RequireDigit  bool
+	RequireSymbol bool
+}
+
+// DefaultPasswordPolicy is checked by NewUser and User.ChangePassword. It is
+// meant to be set once at startup.
+var DefaultPasswordPolicy = PasswordPolicy{
+	MinLength:    8,
+	RequireUpper: true,
+	RequireLower: true,
+	RequireDigit: true,
+}
+
+// Check returns why the password does not meet the policy, if it does not.
+func (p PasswordPolicy) Check(password string) error {
+	if len(password) > maxPasswordBytes {
+		return ErrPasswordTooLong
+	}
+	if n := len([]rune(password)); n < p.MinLength {
+		return fmt.Errorf("%w: it needs at least %d characters", ErrWeakPassword, p.MinLength)
+	}
+	var upper, lower, digit, symbol bool
+	for _, r := range password {
+		switch {
+		case unicode.IsUpper(r):
+			upper = true
+		case unicode.IsLower(r):
+			lower = true
+		case unicode.IsDigit(r):
+			digit = true
+		default:
+			symbol = true
+		}
+	}
+	var missing []string
+	if p.RequireUpper && !upper {
+		missing = append(missing, "an uppercase letter")
+	}
+	if p.RequireLower && !lower {
+		missing = append(missing, "a lowercase letter")
+	}
+	if p.RequireDigit && !digit {
+		missing = append(missing, "a digit")
+	}
+	if p.RequireSymbol && !symbol {
+		missing = append(missing, "a symbol")
+	}
+	if len(missing) > 0 {
+		return fmt.Errorf("%w: it needs %s", ErrWeakPassword, strings.Join(missing, ", "))
+	}
+	if commonPasswords[strings.ToLower(password)] {
+		return ErrCommonPassword
+	}
+	return nil
+}
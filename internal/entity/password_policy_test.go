@@ -0,0 +1,36 @@
+package entity
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPasswordPolicy(t *testing.T) {
+	policy := PasswordPolicy{MinLength: 8, RequireUpper: true, RequireLower: true, RequireDigit: true, RequireSymbol: true}
+	assert.Nil(t, policy.Check("Str0ng-Passw0rd"))
+
+	err := policy.Check("Sh0rt-")
+	assert.ErrorIs(t, err, ErrWeakPassword)
+	assert.Contains(t, err.Error(), "at least 8 characters")
+
+	err = policy.Check("lowercase only")
+	assert.ErrorIs(t, err, ErrWeakPassword)
+	assert.Contains(t, err.Error(), "an uppercase letter, a digit")
+
+	err = policy.Check("N0SymbolsHere")
+	assert.ErrorIs(t, err, ErrWeakPassword)
+	assert.Contains(t, err.Error(), "a symbol")
+
+	assert.ErrorIs(t, policy.Check(strings.Repeat("Aa1-", 19)), ErrPasswordTooLong)
+}
+
+func TestPasswordPolicyRejectsCommonPasswords(t *testing.T) {
+	policy := PasswordPolicy{MinLength: 6}
+	assert.ErrorIs(t, policy.Check("123456"), ErrCommonPassword)
+	assert.ErrorIs(t, policy.Check("PASSWORD"), ErrCommonPassword)
+	assert.ErrorIs(t, DefaultPasswordPolicy.Check("Password1"), ErrCommonPassword)
+	assert.Nil(t, policy.Check("correct horse"))
+	assert.False(t, commonPasswords["# common passwords rejected whatever the password policy, compared without"])
+}
@@ -0,0 +1,60 @@
+package entity
+
+import (
+	"errors"
+	"time"
+
+	"github.com/brenoproti/go-api/pkg/entity"
+)
+
+var ErrInvalidResetToken = errors.New("invalid or expired password reset token")
+
+// PasswordReset lets a user who forgot their password choose a new one with
+// the token emailed to them. Only the hash of the token is stored, and the
+// token can only be used once.
+type PasswordReset struct {
+	ID        entity.ID  `json:"id"`
+	UserID    entity.ID  `json:"user_id"`
+	TokenHash string     `json:"-"`
+	ExpiresAt time.Time  `json:"expires_at"`
+	CreatedAt time.Time  `json:"created_at"`
+	UsedAt    *time.Time `json:"used_at"`
+}
+
+// NewPasswordReset returns the token to email along with the entity to store.
+func NewPasswordReset(userID entity.ID, ttl time.Duration) (*PasswordReset, string, error) {
+	token, err := newSecretToken()
+	if err != nil {
+		return nil, "", err
+	}
+	now := time.Now()
+	r := &PasswordReset{
+		ID:        entity.NewID(),
+		UserID:    userID,
+		TokenHash: HashToken(token),
+		ExpiresAt: now.Add(ttl),
+		CreatedAt: now,
+	}
+	if err := r.Validate(); err != nil {
+		return nil, "", err
+	}
+	return r, token, nil
+}
+
+func (r *PasswordReset) Validate() error {
+	if r.ID == (entity.ID{}) {
+		return ErrIdIsRequired
+	}
+	if r.UserID == (entity.ID{}) {
+		return ErrUserIdIsRequired
+	}
+	if !r.ExpiresAt.After(r.CreatedAt) {
+		return ErrInvalidExpiration
+	}
+	return nil
+}
+
+// IsUsable tells whether the token can still reset the password.
+func (r *PasswordReset) IsUsable(now time.Time) bool {
+	return r.UsedAt == nil && now.Before(r.ExpiresAt)
+}
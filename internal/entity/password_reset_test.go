@@ -0,0 +1,29 @@
+package entity
+
+import (
+	"testing"
+	"time"
+
+	"github.com/brenoproti/go-api/pkg/entity"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewPasswordReset(t *testing.T) {
+	reset, token, err := NewPasswordReset(entity.NewID(), time.Hour)
+	assert.Nil(t, err)
+	assert.NotEmpty(t, token)
+	assert.Equal(t, HashToken(token), reset.TokenHash)
+	assert.True(t, reset.IsUsable(time.Now()))
+	assert.False(t, reset.IsUsable(time.Now().Add(time.Hour)))
+
+	now := time.Now()
+	reset.UsedAt = &now
+	assert.False(t, reset.IsUsable(now))
+}
+
+func TestPasswordResetWhenInvalid(t *testing.T) {
+	_, _, err := NewPasswordReset(entity.ID{}, time.Hour)
+	assert.Equal(t, ErrUserIdIsRequired, err)
+	_, _, err = NewPasswordReset(entity.NewID(), 0)
+	assert.Equal(t, ErrInvalidExpiration, err)
+}
@@ -5,6 +5,7 @@ import (
 	"time"
 
 	"github.com/brenoproti/go-api/pkg/entity"
+	"gorm.io/gorm"
 )
 
 var (
@@ -16,18 +17,30 @@ var (
 )
 
 type Product struct {
-	ID        entity.ID `json:"id"`
-	Name      string    `json:"name"`
-	Price     float64   `json:"price"`
-	CreatedAt time.Time `json:"created_at"`
+	ID        entity.ID    `json:"id"`
+	Name      string       `json:"name"`
+	Price     entity.Money `json:"price" gorm:"embedded;embeddedPrefix:price_"`
+	Version   int64        `json:"version"`
+	CreatedAt time.Time    `json:"created_at"`
+	UpdatedAt time.Time    `json:"updated_at"`
+	// CreatedBy is the user who owns the product and UpdatedBy the last one
+	// to change it. Products created before they were recorded have neither.
+	CreatedBy *entity.ID `json:"created_by" swaggertype:"string" gorm:"index:idx_products_created_by"`
+	UpdatedBy *entity.ID `json:"updated_by" swaggertype:"string"`
+	// DeletedAt is set when the product is moved to the trash. Products in
+	// the trash are left out of every query unless explicitly asked for.
+	DeletedAt gorm.DeletedAt `json:"deleted_at" swaggertype:"string" format:"date-time"`
 }
 
-func NewProduct(name string, price float64) (*Product, error) {
+func NewProduct(name string, price entity.Money) (*Product, error) {
+	now := time.Now()
 	p := &Product{
 		ID:        entity.NewID(),
 		Name:      name,
 		Price:     price,
-		CreatedAt: time.Now(),
+		Version:   1,
+		CreatedAt: now,
+		UpdatedAt: now,
 	}
 	if err := p.Validate(); err != nil {
 		return nil, err
@@ -45,11 +58,16 @@ func (p *Product) Validate() error {
 	if p.Name == "" {
 		return ErrNameIsRequired
 	}
-	if p.Price == 0 {
+	if p.Price.IsZero() {
 		return ErrPriceIsRequired
 	}
-	if p.Price < 0 {
+	if p.Price.IsNegative() {
 		return ErrInvalidPrice
 	}
-	return nil
+	return p.Price.Validate()
+}
+
+// IsOwnedBy tells whether the user created the product.
+func (p *Product) IsOwnedBy(userID entity.ID) bool {
+	return p.CreatedBy != nil && *p.CreatedBy == userID
 }
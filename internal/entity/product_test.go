@@ -3,42 +3,60 @@ package entity
 import (
 	"testing"
 
+	"github.com/brenoproti/go-api/pkg/entity"
 	"github.com/stretchr/testify/assert"
 )
 
 func TestNewProduct(t *testing.T) {
-	p, err := NewProduct("Product 1", 10.5)
+	p, err := NewProduct("Product 1", entity.NewMoney(1050, "USD"))
 	assert.Nil(t, err)
 	assert.NotNil(t, p)
 	assert.NotEmpty(t, p.ID)
 	assert.Equal(t, "Product 1", p.Name)
-	assert.Equal(t, 10.5, p.Price)
+	assert.Equal(t, entity.NewMoney(1050, "USD"), p.Price)
 }
 
 func TestProductWhenNameIsRequired(t *testing.T) {
-	p, err := NewProduct("", 10.5)
+	p, err := NewProduct("", entity.NewMoney(1050, "USD"))
 	assert.NotNil(t, err)
 	assert.Nil(t, p)
 	assert.Equal(t, ErrNameIsRequired, err)
 }
 
 func TestProductWhenPriceIsRequired(t *testing.T) {
-	p, err := NewProduct("Product 1", 0)
+	p, err := NewProduct("Product 1", entity.NewMoney(0, "USD"))
 	assert.NotNil(t, err)
 	assert.Nil(t, p)
 	assert.Equal(t, ErrPriceIsRequired, err)
 }
 
 func TestProductWhenPriceIsInvalid(t *testing.T) {
-	p, err := NewProduct("Product 1", -10.5)
+	p, err := NewProduct("Product 1", entity.NewMoney(-1050, "USD"))
 	assert.NotNil(t, err)
 	assert.Nil(t, p)
 	assert.Equal(t, ErrInvalidPrice, err)
 }
 
+func TestProductWhenCurrencyIsInvalid(t *testing.T) {
+	p, err := NewProduct("Product 1", entity.NewMoney(1050, "XXX"))
+	assert.NotNil(t, err)
+	assert.Nil(t, p)
+	assert.Equal(t, entity.ErrInvalidCurrency, err)
+}
+
 func TestValidateProduct(t *testing.T) {
-	p, err := NewProduct("Product 1", 10.5)
+	p, err := NewProduct("Product 1", entity.NewMoney(1050, "USD"))
 	assert.Nil(t, err)
 	assert.NotNil(t, p)
 	assert.Nil(t, p.Validate())
 }
+
+func TestProductIsOwnedBy(t *testing.T) {
+	p, err := NewProduct("Product 1", entity.NewMoney(1000, "USD"))
+	assert.Nil(t, err)
+	owner := entity.NewID()
+	assert.False(t, p.IsOwnedBy(owner))
+	p.CreatedBy = &owner
+	assert.True(t, p.IsOwnedBy(owner))
+	assert.False(t, p.IsOwnedBy(entity.NewID()))
+}
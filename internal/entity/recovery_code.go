@@ -0,0 +1,66 @@
+package entity
+
+import (
+	"crypto/rand"
+	"strings"
+	"time"
+
+	"github.com/brenoproti/go-api/pkg/entity"
+)
+
+// RecoveryCodeCount is how many recovery codes a user gets at once.
+const RecoveryCodeCount = 10
+
+// RecoveryCode lets a user who lost their authenticator app log in once in
+// its place. Only the hash of the code is stored.
+type RecoveryCode struct {
+	ID        entity.ID  `json:"id"`
+	UserID    entity.ID  `json:"user_id"`
+	CodeHash  string     `json:"-"`
+	UsedAt    *time.Time `json:"used_at"`
+	CreatedAt time.Time  `json:"created_at"`
+}
+
+// NewRecoveryCodes returns the codes to show the user, once, along with the
+// entities to store.
+func NewRecoveryCodes(userID entity.ID) ([]RecoveryCode, []string, error) {
+	if userID == (entity.ID{}) {
+		return nil, nil, ErrUserIdIsRequired
+	}
+	now := time.Now()
+	codes := make([]RecoveryCode, RecoveryCodeCount)
+	plain := make([]string, RecoveryCodeCount)
+	for i := range codes {
+		code, err := newRecoveryCode()
+		if err != nil {
+			return nil, nil, err
+		}
+		plain[i] = code
+		codes[i] = RecoveryCode{
+			ID:        entity.NewID(),
+			UserID:    userID,
+			CodeHash:  HashRecoveryCode(code),
+			CreatedAt: now,
+		}
+	}
+	return codes, plain, nil
+}
+
+// newRecoveryCode generates 50 random bits, written as two groups of five
+// lowercase letters and digits.
+func newRecoveryCode() (string, error) {
+	random := make([]byte, 7)
+	if _, err := rand.Read(random); err != nil {
+		return "", err
+	}
+	code := strings.ToLower(totpEncoding.EncodeToString(random))[:10]
+	return code[:5] + "-" + code[5:], nil
+}
+
+// HashRecoveryCode hashes a recovery code the way it is stored, whether it is
+// typed in with the dash, without it, or in uppercase.
+func HashRecoveryCode(code string) string {
+	code = strings.ToLower(strings.TrimSpace(code))
+	code = strings.ReplaceAll(code, "-", "")
+	return HashToken(code)
+}
@@ -0,0 +1,82 @@
+package entity
+
+import (
+	"errors"
+	"time"
+
+	"github.com/brenoproti/go-api/pkg/entity"
+)
+
+var (
+	ErrUserIdIsRequired    = errors.New("user id is required")
+	ErrInvalidRefreshToken = errors.New("invalid refresh token")
+	ErrRefreshTokenExpired = errors.New("refresh token expired")
+	ErrRefreshTokenRevoked = errors.New("refresh token revoked")
+	ErrRefreshTokenReused  = errors.New("refresh token reused")
+)
+
+// RefreshToken lets a client get a new access token without sending the
+// password again. Only the SHA-256 hash of the opaque token is stored. Every
+// refresh replaces the token with a new one of the same family, so that a
+// replaced token being used again reveals it was stolen.
+type RefreshToken struct {
+	ID         entity.ID  `json:"id"`
+	UserID     entity.ID  `json:"user_id"`
+	FamilyID   entity.ID  `json:"family_id"`
+	TokenHash  string     `json:"-"`
+	ExpiresAt  time.Time  `json:"expires_at"`
+	CreatedAt  time.Time  `json:"created_at"`
+	RevokedAt  *time.Time `json:"revoked_at"`
+	ReplacedBy *entity.ID `json:"replaced_by"`
+}
+
+// NewRefreshToken starts a new family when familyID is nil. It returns the
+// token to hand to the client along with the entity to store.
+func NewRefreshToken(userID entity.ID, familyID *entity.ID, ttl time.Duration) (*RefreshToken, string, error) {
+	token, err := newSecretToken()
+	if err != nil {
+		return nil, "", err
+	}
+	now := time.Now()
+	t := &RefreshToken{
+		ID:        entity.NewID(),
+		UserID:    userID,
+		TokenHash: HashToken(token),
+		ExpiresAt: now.Add(ttl),
+		CreatedAt: now,
+	}
+	t.FamilyID = t.ID
+	if familyID != nil {
+		t.FamilyID = *familyID
+	}
+	if err := t.Validate(); err != nil {
+		return nil, "", err
+	}
+	return t, token, nil
+}
+
+func (t *RefreshToken) Validate() error {
+	if t.ID.String() == "" {
+		return ErrIdIsRequired
+	}
+	if t.UserID == (entity.ID{}) {
+		return ErrUserIdIsRequired
+	}
+	if !t.ExpiresAt.After(t.CreatedAt) {
+		return ErrInvalidExpiration
+	}
+	return nil
+}
+
+func (t *RefreshToken) IsExpired(now time.Time) bool {
+	return !now.Before(t.ExpiresAt)
+}
+
+func (t *RefreshToken) IsRevoked() bool {
+	return t.RevokedAt != nil
+}
+
+// IsReplaced tells whether the token was already exchanged for a new one.
+func (t *RefreshToken) IsReplaced() bool {
+	return t.ReplacedBy != nil
+}
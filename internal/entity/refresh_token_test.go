@@ -0,0 +1,34 @@
+package entity
+
+import (
+	"testing"
+	"time"
+
+	"github.com/brenoproti/go-api/pkg/entity"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewRefreshToken(t *testing.T) {
+	userID := entity.NewID()
+	first, token, err := NewRefreshToken(userID, nil, time.Hour)
+	assert.Nil(t, err)
+	assert.NotEmpty(t, token)
+	assert.Equal(t, first.ID, first.FamilyID)
+	assert.Equal(t, HashToken(token), first.TokenHash)
+	assert.NotEqual(t, token, first.TokenHash)
+	assert.False(t, first.IsExpired(time.Now()))
+	assert.True(t, first.IsExpired(time.Now().Add(time.Hour)))
+	assert.False(t, first.IsRevoked())
+
+	second, other, err := NewRefreshToken(userID, &first.FamilyID, time.Hour)
+	assert.Nil(t, err)
+	assert.NotEqual(t, token, other)
+	assert.Equal(t, first.FamilyID, second.FamilyID)
+}
+
+func TestRefreshTokenWhenInvalid(t *testing.T) {
+	_, _, err := NewRefreshToken(entity.ID{}, nil, time.Hour)
+	assert.Equal(t, ErrUserIdIsRequired, err)
+	_, _, err = NewRefreshToken(entity.NewID(), nil, 0)
+	assert.Equal(t, ErrInvalidExpiration, err)
+}
@@ -0,0 +1,46 @@
+package entity
+
+import (
+	"time"
+
+	"github.com/brenoproti/go-api/pkg/entity"
+)
+
+// RevokedToken keeps an access token from being accepted before it expires,
+// identified by its jti claim. It is only needed until then.
+type RevokedToken struct {
+	ID        entity.ID `json:"id"`
+	UserID    entity.ID `json:"user_id"`
+	ExpiresAt time.Time `json:"expires_at"`
+	RevokedAt time.Time `json:"revoked_at"`
+}
+
+func NewRevokedToken(id, userID entity.ID, expiresAt time.Time) (*RevokedToken, error) {
+	t := &RevokedToken{
+		ID:        id,
+		UserID:    userID,
+		ExpiresAt: expiresAt,
+		RevokedAt: time.Now(),
+	}
+	if err := t.Validate(); err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+func (t *RevokedToken) Validate() error {
+	if t.ID == (entity.ID{}) {
+		return ErrIdIsRequired
+	}
+	if t.UserID == (entity.ID{}) {
+		return ErrUserIdIsRequired
+	}
+	if t.ExpiresAt.IsZero() {
+		return ErrInvalidExpiration
+	}
+	return nil
+}
+
+func (t *RevokedToken) IsExpired(now time.Time) bool {
+	return !now.Before(t.ExpiresAt)
+}
@@ -0,0 +1,28 @@
+package entity
+
+import (
+	"testing"
+	"time"
+
+	"github.com/brenoproti/go-api/pkg/entity"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewRevokedToken(t *testing.T) {
+	id, userID := entity.NewID(), entity.NewID()
+	token, err := NewRevokedToken(id, userID, time.Now().Add(time.Minute))
+	assert.Nil(t, err)
+	assert.Equal(t, id, token.ID)
+	assert.Equal(t, userID, token.UserID)
+	assert.False(t, token.IsExpired(time.Now()))
+	assert.True(t, token.IsExpired(time.Now().Add(time.Minute)))
+}
+
+func TestRevokedTokenWhenInvalid(t *testing.T) {
+	_, err := NewRevokedToken(entity.ID{}, entity.NewID(), time.Now())
+	assert.Equal(t, ErrIdIsRequired, err)
+	_, err = NewRevokedToken(entity.NewID(), entity.ID{}, time.Now())
+	assert.Equal(t, ErrUserIdIsRequired, err)
+	_, err = NewRevokedToken(entity.NewID(), entity.NewID(), time.Time{})
+	assert.Equal(t, ErrInvalidExpiration, err)
+}
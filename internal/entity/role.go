@@ -0,0 +1,40 @@
+package entity
+
+import "errors"
+
+var ErrInvalidRole = errors.New("invalid role")
+
+// Role grants a user permissions over products. Every role includes the
+// permissions of the roles below it: viewers can read, editors can also
+// create and change, admins can also delete and manage users' roles.
+type Role string
+
+const (
+	RoleViewer Role = "viewer"
+	RoleEditor Role = "editor"
+	RoleAdmin  Role = "admin"
+)
+
+var roleLevels = map[Role]int{
+	RoleViewer: 1,
+	RoleEditor: 2,
+	RoleAdmin:  3,
+}
+
+func ParseRole(role string) (Role, error) {
+	r := Role(role)
+	if !r.IsValid() {
+		return "", ErrInvalidRole
+	}
+	return r, nil
+}
+
+func (r Role) IsValid() bool {
+	_, ok := roleLevels[r]
+	return ok
+}
+
+// Includes tells whether the role has at least the permissions of other.
+func (r Role) Includes(other Role) bool {
+	return r.IsValid() && roleLevels[r] >= roleLevels[other]
+}
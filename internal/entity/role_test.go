@@ -0,0 +1,26 @@
+package entity
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseRole(t *testing.T) {
+	role, err := ParseRole("editor")
+	assert.Nil(t, err)
+	assert.Equal(t, RoleEditor, role)
+	_, err = ParseRole("owner")
+	assert.Equal(t, ErrInvalidRole, err)
+	_, err = ParseRole("")
+	assert.Equal(t, ErrInvalidRole, err)
+}
+
+func TestRoleIncludes(t *testing.T) {
+	assert.True(t, RoleAdmin.Includes(RoleEditor))
+	assert.True(t, RoleAdmin.Includes(RoleAdmin))
+	assert.True(t, RoleEditor.Includes(RoleViewer))
+	assert.False(t, RoleEditor.Includes(RoleAdmin))
+	assert.False(t, RoleViewer.Includes(RoleEditor))
+	assert.False(t, Role("owner").Includes(RoleViewer))
+}
@@ -0,0 +1,70 @@
+package entity
+
+import (
+	"errors"
+	"time"
+
+	"github.com/brenoproti/go-api/pkg/entity"
+)
+
+var (
+	ErrInvalidQuantity     = errors.New("invalid quantity")
+	ErrInvalidExpiration   = errors.New("invalid expiration")
+	ErrInsufficientStock   = errors.New("insufficient stock")
+	ErrReservationExpired  = errors.New("reservation expired")
+	ErrProductIdIsRequired = errors.New("product id is required")
+)
+
+// Stock is the quantity on hand of a product. Reserved and Available are
+// derived from the reservations that have not expired yet.
+type Stock struct {
+	ProductID entity.ID `json:"product_id" gorm:"primaryKey"`
+	Quantity  int       `json:"quantity"`
+	Reserved  int       `json:"reserved" gorm:"-"`
+	Available int       `json:"available" gorm:"-"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// Reservation holds part of a product stock for a cart until it expires.
+type Reservation struct {
+	ID        entity.ID `json:"id"`
+	ProductID entity.ID `json:"product_id"`
+	Quantity  int       `json:"quantity"`
+	ExpiresAt time.Time `json:"expires_at"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+func NewReservation(productID entity.ID, quantity int, ttl time.Duration) (*Reservation, error) {
+	now := time.Now()
+	r := &Reservation{
+		ID:        entity.NewID(),
+		ProductID: productID,
+		Quantity:  quantity,
+		ExpiresAt: now.Add(ttl),
+		CreatedAt: now,
+	}
+	if err := r.Validate(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+func (r *Reservation) Validate() error {
+	if r.ID.String() == "" {
+		return ErrIdIsRequired
+	}
+	if r.ProductID == (entity.ID{}) {
+		return ErrProductIdIsRequired
+	}
+	if r.Quantity <= 0 {
+		return ErrInvalidQuantity
+	}
+	if !r.ExpiresAt.After(r.CreatedAt) {
+		return ErrInvalidExpiration
+	}
+	return nil
+}
+
+func (r *Reservation) IsExpired(now time.Time) bool {
+	return !now.Before(r.ExpiresAt)
+}
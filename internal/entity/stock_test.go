@@ -0,0 +1,39 @@
+package entity
+
+import (
+	"testing"
+	"time"
+
+	"github.com/brenoproti/go-api/pkg/entity"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewReservation(t *testing.T) {
+	productID := entity.NewID()
+	r, err := NewReservation(productID, 2, time.Minute)
+	assert.Nil(t, err)
+	assert.NotNil(t, r)
+	assert.NotEmpty(t, r.ID)
+	assert.Equal(t, productID, r.ProductID)
+	assert.Equal(t, 2, r.Quantity)
+	assert.False(t, r.IsExpired(time.Now()))
+	assert.True(t, r.IsExpired(time.Now().Add(time.Minute)))
+}
+
+func TestReservationWhenQuantityIsInvalid(t *testing.T) {
+	r, err := NewReservation(entity.NewID(), 0, time.Minute)
+	assert.Nil(t, r)
+	assert.Equal(t, ErrInvalidQuantity, err)
+}
+
+func TestReservationWhenExpirationIsInvalid(t *testing.T) {
+	r, err := NewReservation(entity.NewID(), 1, 0)
+	assert.Nil(t, r)
+	assert.Equal(t, ErrInvalidExpiration, err)
+}
+
+func TestReservationWhenProductIdIsRequired(t *testing.T) {
+	r, err := NewReservation(entity.ID{}, 1, time.Minute)
+	assert.Nil(t, r)
+	assert.Equal(t, ErrProductIdIsRequired, err)
+}
@@ -0,0 +1,115 @@
+package entity
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base32"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/brenoproti/go-api/pkg/entity"
+	"github.com/pquerna/otp"
+	"github.com/pquerna/otp/totp"
+)
+
+var (
+	ErrInvalidTOTPCode   = errors.New("invalid two-factor code")
+	ErrInvalidTOTPSecret = errors.New("invalid two-factor secret")
+)
+
+const (
+	totpPeriod = 30
+	// totpSkew is how many periods a code can be early or late, to make up
+	// for clocks that are off and codes typed in slowly.
+	totpSkew       = 1
+	totpDigits     = 6
+	totpSecretSize = 20
+)
+
+var totpEncoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// TOTPSecret is the secret a user shares with their authenticator app. It
+// only protects logins once confirmed with a code from the app. Unlike
+// tokens it cannot be stored hashed, since the codes are computed from it.
+type TOTPSecret struct {
+	UserID entity.ID `json:"user_id" gorm:"primaryKey"`
+	Secret string    `json:"-"`
+	// LastUsedStep is the time step of the last code accepted, so that no
+	// code is accepted twice.
+	LastUsedStep int64      `json:"-"`
+	ConfirmedAt  *time.Time `json:"confirmed_at"`
+	CreatedAt    time.Time  `json:"created_at"`
+}
+
+func NewTOTPSecret(userID entity.ID) (*TOTPSecret, error) {
+	secret := make([]byte, totpSecretSize)
+	if _, err := rand.Read(secret); err != nil {
+		return nil, err
+	}
+	s := &TOTPSecret{
+		UserID:    userID,
+		Secret:    totpEncoding.EncodeToString(secret),
+		CreatedAt: time.Now(),
+	}
+	if err := s.Validate(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+func (s *TOTPSecret) Validate() error {
+	if s.UserID == (entity.ID{}) {
+		return ErrUserIdIsRequired
+	}
+	if _, err := totpEncoding.DecodeString(s.Secret); err != nil || s.Secret == "" {
+		return ErrInvalidTOTPSecret
+	}
+	return nil
+}
+
+func (s *TOTPSecret) IsConfirmed() bool {
+	return s.ConfirmedAt != nil
+}
+
+// Key is what authenticator apps are set up with, as an otpauth:// URL or a
+// QR code of it.
+func (s *TOTPSecret) Key(issuer, account string) (*otp.Key, error) {
+	secret, err := totpEncoding.DecodeString(s.Secret)
+	if err != nil {
+		return nil, ErrInvalidTOTPSecret
+	}
+	return totp.Generate(totp.GenerateOpts{
+		Issuer:      issuer,
+		AccountName: account,
+		Period:      totpPeriod,
+		Digits:      otp.DigitsSix,
+		Secret:      secret,
+	})
+}
+
+// Check returns the time step of the code if it is right at the given time
+// and newer than the last one used.
+func (s *TOTPSecret) Check(code string, now time.Time) (int64, bool) {
+	code = strings.TrimSpace(code)
+	if len(code) != totpDigits {
+		return 0, false
+	}
+	step := now.Unix() / totpPeriod
+	for candidate := step - totpSkew; candidate <= step+totpSkew; candidate++ {
+		if candidate <= s.LastUsedStep {
+			continue
+		}
+		expected, err := totp.GenerateCodeCustom(s.Secret, time.Unix(candidate*totpPeriod, 0), totp.ValidateOpts{
+			Period: totpPeriod,
+			Digits: otp.DigitsSix,
+		})
+		if err != nil {
+			return 0, false
+		}
+		if subtle.ConstantTimeCompare([]byte(expected), []byte(code)) == 1 {
+			return candidate, true
+		}
+	}
+	return 0, false
+}
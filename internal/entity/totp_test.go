@@ -0,0 +1,83 @@
+package entity
+
+import (
+	"testing"
+	"time"
+
+	"github.com/brenoproti/go-api/pkg/entity"
+	"github.com/pquerna/otp/totp"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewTOTPSecret(t *testing.T) {
+	userID := entity.NewID()
+	secret, err := NewTOTPSecret(userID)
+	assert.Nil(t, err)
+	assert.Equal(t, userID, secret.UserID)
+	assert.Len(t, secret.Secret, 32)
+	assert.False(t, secret.IsConfirmed())
+
+	_, err = NewTOTPSecret(entity.ID{})
+	assert.Equal(t, ErrUserIdIsRequired, err)
+	secret.Secret = "not base32!"
+	assert.Equal(t, ErrInvalidTOTPSecret, secret.Validate())
+}
+
+func TestTOTPSecretKey(t *testing.T) {
+	secret, _ := NewTOTPSecret(entity.NewID())
+	key, err := secret.Key("Go API", "j@j.com")
+	assert.Nil(t, err)
+	assert.Equal(t, secret.Secret, key.Secret())
+	assert.Contains(t, key.URL(), "otpauth://totp/Go%20API:j@j.com?")
+	assert.Equal(t, "Go API", key.Issuer())
+	image, err := key.Image(200, 200)
+	assert.Nil(t, err)
+	assert.Equal(t, 200, image.Bounds().Dx())
+}
+
+func TestTOTPSecretCheck(t *testing.T) {
+	secret, _ := NewTOTPSecret(entity.NewID())
+	now := time.Now()
+	code, err := totp.GenerateCode(secret.Secret, now)
+	assert.Nil(t, err)
+
+	step, ok := secret.Check(code, now)
+	assert.True(t, ok)
+	assert.Equal(t, now.Unix()/30, step)
+	// codes from the periods before and after are accepted too
+	_, ok = secret.Check(code, now.Add(30*time.Second))
+	assert.True(t, ok)
+	_, ok = secret.Check(code, now.Add(-30*time.Second))
+	assert.True(t, ok)
+	_, ok = secret.Check(code, now.Add(-90*time.Second))
+	assert.False(t, ok)
+
+	// but not twice
+	secret.LastUsedStep = step
+	_, ok = secret.Check(code, now)
+	assert.False(t, ok)
+
+	_, ok = secret.Check("12345", now)
+	assert.False(t, ok)
+}
+
+func TestNewRecoveryCodes(t *testing.T) {
+	userID := entity.NewID()
+	codes, plain, err := NewRecoveryCodes(userID)
+	assert.Nil(t, err)
+	assert.Len(t, codes, RecoveryCodeCount)
+	assert.Len(t, plain, RecoveryCodeCount)
+	seen := map[string]bool{}
+	for i, code := range codes {
+		assert.Equal(t, userID, code.UserID)
+		assert.Regexp(t, "^[a-z2-7]{5}-[a-z2-7]{5}$", plain[i])
+		assert.Equal(t, HashRecoveryCode(plain[i]), code.CodeHash)
+		assert.False(t, seen[plain[i]])
+		seen[plain[i]] = true
+	}
+	withoutDash := plain[0][:5] + plain[0][6:]
+	assert.Equal(t, codes[0].CodeHash, HashRecoveryCode(" "+withoutDash+" "))
+
+	_, _, err = NewRecoveryCodes(entity.ID{})
+	assert.Equal(t, ErrUserIdIsRequired, err)
+}
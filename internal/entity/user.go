@@ -1,28 +1,115 @@
 package entity
 
 import (
+	"errors"
+	"net/mail"
+	"strings"
+	"time"
+
 	"github.com/brenoproti/go-api/pkg/entity"
 	"golang.org/x/crypto/bcrypt"
 )
 
+var (
+	ErrEmailIsRequired = errors.New("email is required")
+	ErrInvalidEmail    = errors.New("invalid email")
+)
+
 type User struct {
 	ID       entity.ID `json:"id"`
 	Name     string    `json:"name"`
-	Email    string    `json:"email"`
+	Email    string    `json:"email" gorm:"uniqueIndex:idx_users_email"`
 	Password string    `json:"-"`
+	Role     Role      `json:"role" gorm:"default:viewer"`
+	// EmailVerifiedAt is set once the user proves they own Email, and reset
+	// whenever it changes.
+	EmailVerifiedAt *time.Time `json:"email_verified_at"`
+	// TokensValidAfter is when the password or role last changed. Access
+	// tokens issued before then are no longer accepted.
+	TokensValidAfter *time.Time `json:"-"`
 }
 
 func NewUser(name, email, password string) (*User, error) {
+	if err := DefaultPasswordPolicy.Check(password); err != nil {
+		return nil, err
+	}
 	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
 	if err != nil {
 		return nil, err
 	}
-	return &User{
+	u := &User{
 		ID:       entity.NewID(),
 		Name:     name,
-		Email:    email,
+		Email:    NormalizeEmail(email),
 		Password: string(hash),
-	}, nil
+		Role:     RoleViewer,
+	}
+	if err := u.Validate(); err != nil {
+		return nil, err
+	}
+	return u, nil
+}
+
+// NormalizeEmail trims and lowercases an email address, so that the same
+// address is always stored and looked up the same way.
+func NormalizeEmail(email string) string {
+	return strings.ToLower(strings.TrimSpace(email))
+}
+
+func (u *User) Validate() error {
+	if u.ID.String() == "" {
+		return ErrIdIsRequired
+	}
+	if _, err := entity.ParseID(u.ID.String()); err != nil {
+		return ErrInvalidId
+	}
+	if u.Name == "" {
+		return ErrNameIsRequired
+	}
+	if u.Email == "" {
+		return ErrEmailIsRequired
+	}
+	if address, err := mail.ParseAddress(u.Email); err != nil || address.Address != u.Email {
+		return ErrInvalidEmail
+	}
+	if !u.Role.IsValid() {
+		return ErrInvalidRole
+	}
+	return nil
+}
+
+func (u *User) IsEmailVerified() bool {
+	return u.EmailVerifiedAt != nil
+}
+
+// ChangePassword replaces the password hash of the user, provided the new
+// password meets DefaultPasswordPolicy.
+func (u *User) ChangePassword(password string) error {
+	if err := DefaultPasswordPolicy.Check(password); err != nil {
+		return err
+	}
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return err
+	}
+	u.Password = string(hash)
+	u.RevokeTokens()
+	return nil
+}
+
+// RevokeTokens stops accepting the access tokens issued to the user so far.
+func (u *User) RevokeTokens() {
+	// token iat claims only have seconds, so tokens issued later in the same
+	// second still have to be accepted
+	now := time.Now().Truncate(time.Second)
+	u.TokensValidAfter = &now
+}
+
+// AcceptsTokenIssuedAt tells whether an access token issued to the user at
+// the given time is still valid, that is neither the password nor the role
+// changed since.
+func (u *User) AcceptsTokenIssuedAt(iat time.Time) bool {
+	return u.TokensValidAfter == nil || !iat.Before(*u.TokensValidAfter)
 }
 
 func (u *User) ValidatePassword(password string) bool {
@@ -2,24 +2,76 @@ package entity
 
 import (
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 )
 
 func TestNewUser(t *testing.T) {
-	user, err := NewUser("John Doe", "email@email.com", "123456")
+	user, err := NewUser("John Doe", "email@email.com", "Str0ng-Passw0rd")
 	assert.Nil(t, err)
 	assert.NotNil(t, user)
 	assert.NotEmpty(t, user.ID)
 	assert.NotEmpty(t, user.Password)
 	assert.Equal(t, "John Doe", user.Name)
 	assert.Equal(t, "email@email.com", user.Email)
+	assert.Equal(t, RoleViewer, user.Role)
+}
+
+func TestNewUserChecksPasswordPolicy(t *testing.T) {
+	_, err := NewUser("John Doe", "email@email.com", "short")
+	assert.ErrorIs(t, err, ErrWeakPassword)
+
+	user, err := NewUser("John Doe", "email@email.com", "Str0ng-Passw0rd")
+	assert.Nil(t, err)
+	assert.ErrorIs(t, user.ChangePassword("Password1"), ErrCommonPassword)
+	assert.True(t, user.ValidatePassword("Str0ng-Passw0rd"))
 }
 
 func TestUser_ValidatePassword(t *testing.T) {
-	user, err := NewUser("John Doe", "email@email.com", "123456")
+	user, err := NewUser("John Doe", "email@email.com", "Str0ng-Passw0rd")
 	assert.Nil(t, err)
-	assert.True(t, user.ValidatePassword("123456"))
+	assert.True(t, user.ValidatePassword("Str0ng-Passw0rd"))
 	assert.False(t, user.ValidatePassword("123"))
-	assert.NotEqual(t, "123456", user.Password)
+	assert.NotEqual(t, "Str0ng-Passw0rd", user.Password)
+}
+
+func TestUserChangePassword(t *testing.T) {
+	user, err := NewUser("John Doe", "email@email.com", "Str0ng-Passw0rd")
+	assert.Nil(t, err)
+	issued := time.Now().Add(-time.Minute)
+	assert.True(t, user.AcceptsTokenIssuedAt(issued))
+	assert.Nil(t, user.ChangePassword("N3w-Passw0rd"))
+	assert.True(t, user.ValidatePassword("N3w-Passw0rd"))
+	assert.False(t, user.ValidatePassword("Str0ng-Passw0rd"))
+	// tokens issued before the change are no longer accepted
+	assert.False(t, user.AcceptsTokenIssuedAt(issued))
+	assert.False(t, user.AcceptsTokenIssuedAt(time.Time{}))
+	assert.True(t, user.AcceptsTokenIssuedAt(time.Unix(time.Now().Unix(), 0)))
+}
+
+func TestUserValidate(t *testing.T) {
+	user, err := NewUser("John Doe", "email@email.com", "Str0ng-Passw0rd")
+	assert.Nil(t, err)
+	assert.Nil(t, user.Validate())
+
+	user.Role = "owner"
+	assert.Equal(t, ErrInvalidRole, user.Validate())
+	user.Role = RoleAdmin
+	assert.Nil(t, user.Validate())
+	user.Email = ""
+	assert.Equal(t, ErrEmailIsRequired, user.Validate())
+	user.Name = ""
+	assert.Equal(t, ErrNameIsRequired, user.Validate())
+}
+
+func TestNewUserNormalizesEmail(t *testing.T) {
+	user, err := NewUser("John Doe", "  Email@Email.COM ", "Str0ng-Passw0rd")
+	assert.Nil(t, err)
+	assert.Equal(t, "email@email.com", user.Email)
+
+	_, err = NewUser("John Doe", "not an email", "Str0ng-Passw0rd")
+	assert.Equal(t, ErrInvalidEmail, err)
+	_, err = NewUser("John Doe", "John <john@email.com>", "Str0ng-Passw0rd")
+	assert.Equal(t, ErrInvalidEmail, err)
 }
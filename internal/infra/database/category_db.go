@@ -0,0 +1,138 @@
+package database
+
+import (
+	"errors"
+
+	"github.com/brenoproti/go-api/internal/entity"
+	"gorm.io/gorm"
+)
+
+var (
+	ErrCategoryHasChildren = errors.New("category has subcategories")
+	ErrUnknownCategory     = errors.New("unknown category")
+)
+
+type productCategory struct {
+	ProductID  string `gorm:"primaryKey"`
+	CategoryID string `gorm:"primaryKey"`
+}
+
+func (productCategory) TableName() string {
+	return "product_categories"
+}
+
+type CategoryDB struct {
+	DB *gorm.DB
+}
+
+func NewCategoryDB(db *gorm.DB) *CategoryDB {
+	return &CategoryDB{
+		DB: db,
+	}
+}
+
+func (c *CategoryDB) Create(category *entity.Category) error {
+	if category.ParentID != nil {
+		if _, err := c.FindById(category.ParentID.String()); err != nil {
+			return parentError(err)
+		}
+	}
+	return c.DB.Create(category).Error
+}
+
+func (c *CategoryDB) FindById(id string) (*entity.Category, error) {
+	var category entity.Category
+	err := c.DB.First(&category, "id = ?", id).Error
+	if err != nil {
+		return nil, err
+	}
+	return &category, nil
+}
+
+func (c *CategoryDB) FindAll() ([]entity.Category, error) {
+	var categories []entity.Category
+	err := c.DB.Order("name asc").Find(&categories).Error
+	if err != nil {
+		return nil, err
+	}
+	return categories, nil
+}
+
+func (c *CategoryDB) FindDescendantIDs(id string) ([]string, error) {
+	return descendantIDs(c.DB, id)
+}
+
+func (c *CategoryDB) Update(category *entity.Category) error {
+	c2, err := c.FindById(category.ID.String())
+	if err != nil {
+		return err
+	}
+	if category.ParentID != nil {
+		if _, err := c.FindById(category.ParentID.String()); err != nil {
+			return parentError(err)
+		}
+		descendants, err := c.FindDescendantIDs(category.ID.String())
+		if err != nil {
+			return err
+		}
+		for _, d := range descendants {
+			if d == category.ParentID.String() {
+				return entity.ErrInvalidParent
+			}
+		}
+	}
+	category.CreatedAt = c2.CreatedAt
+	return c.DB.Save(category).Error
+}
+
+func (c *CategoryDB) Delete(id string) error {
+	category, err := c.FindById(id)
+	if err != nil {
+		return err
+	}
+	var children int64
+	if err := c.DB.Model(&entity.Category{}).Where("parent_id = ?", id).Count(&children).Error; err != nil {
+		return err
+	}
+	if children > 0 {
+		return ErrCategoryHasChildren
+	}
+	return c.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("category_id = ?", id).Delete(&productCategory{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(category).Error
+	})
+}
+
+// descendantIDs walks the category tree one level at a time and returns the
+// ids of every category nested under id.
+func descendantIDs(db *gorm.DB, id string) ([]string, error) {
+	var descendants []string
+	level := []string{id}
+	seen := map[string]bool{id: true}
+	for len(level) > 0 {
+		var children []string
+		err := db.Model(&entity.Category{}).Where("parent_id IN ?", level).Pluck("id", &children).Error
+		if err != nil {
+			return nil, err
+		}
+		level = level[:0]
+		for _, child := range children {
+			if seen[child] {
+				continue
+			}
+			seen[child] = true
+			descendants = append(descendants, child)
+			level = append(level, child)
+		}
+	}
+	return descendants, nil
+}
+
+func parentError(err error) error {
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return entity.ErrInvalidParent
+	}
+	return err
+}
@@ -0,0 +1,129 @@
+package database
+
+import (
+	"testing"
+
+	"github.com/brenoproti/go-api/internal/entity"
+	pkg "github.com/brenoproti/go-api/pkg/entity"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+func newCategoryTestDB(t *testing.T) *gorm.DB {
+	db, err := gorm.Open(sqlite.Open("file::memory:"), &gorm.Config{})
+	if err != nil {
+		t.Error(err)
+	}
+	db.AutoMigrate(&entity.Product{}, &entity.Category{}, &productCategory{})
+	return db
+}
+
+func TestCreateCategory(t *testing.T) {
+	db := newCategoryTestDB(t)
+	categoryDb := NewCategoryDB(db)
+	parent, _ := entity.NewCategory("Electronics", nil)
+	assert.NoError(t, categoryDb.Create(parent))
+	child, _ := entity.NewCategory("Phones", &parent.ID)
+	assert.NoError(t, categoryDb.Create(child))
+
+	found, err := categoryDb.FindById(child.ID.String())
+	assert.NoError(t, err)
+	assert.Equal(t, "Phones", found.Name)
+	assert.Equal(t, parent.ID, *found.ParentID)
+
+	orphanParent, _ := entity.NewCategory("Missing", nil)
+	orphan, _ := entity.NewCategory("Orphan", &orphanParent.ID)
+	assert.ErrorIs(t, categoryDb.Create(orphan), entity.ErrInvalidParent)
+}
+
+func TestFindDescendantIDs(t *testing.T) {
+	db := newCategoryTestDB(t)
+	categoryDb := NewCategoryDB(db)
+	electronics, _ := entity.NewCategory("Electronics", nil)
+	phones, _ := entity.NewCategory("Phones", &electronics.ID)
+	android, _ := entity.NewCategory("Android", &phones.ID)
+	books, _ := entity.NewCategory("Books", nil)
+	for _, c := range []*entity.Category{electronics, phones, android, books} {
+		assert.NoError(t, categoryDb.Create(c))
+	}
+	ids, err := categoryDb.FindDescendantIDs(electronics.ID.String())
+	assert.NoError(t, err)
+	assert.ElementsMatch(t, []string{phones.ID.String(), android.ID.String()}, ids)
+
+	ids, err = categoryDb.FindDescendantIDs(books.ID.String())
+	assert.NoError(t, err)
+	assert.Empty(t, ids)
+}
+
+func TestUpdateCategoryRejectsCycles(t *testing.T) {
+	db := newCategoryTestDB(t)
+	categoryDb := NewCategoryDB(db)
+	electronics, _ := entity.NewCategory("Electronics", nil)
+	phones, _ := entity.NewCategory("Phones", &electronics.ID)
+	assert.NoError(t, categoryDb.Create(electronics))
+	assert.NoError(t, categoryDb.Create(phones))
+
+	electronics.ParentID = &phones.ID
+	assert.ErrorIs(t, categoryDb.Update(electronics), entity.ErrInvalidParent)
+
+	phones.Name = "Mobile phones"
+	assert.NoError(t, categoryDb.Update(phones))
+	found, err := categoryDb.FindById(phones.ID.String())
+	assert.NoError(t, err)
+	assert.Equal(t, "Mobile phones", found.Name)
+}
+
+func TestDeleteCategory(t *testing.T) {
+	db := newCategoryTestDB(t)
+	categoryDb := NewCategoryDB(db)
+	electronics, _ := entity.NewCategory("Electronics", nil)
+	phones, _ := entity.NewCategory("Phones", &electronics.ID)
+	assert.NoError(t, categoryDb.Create(electronics))
+	assert.NoError(t, categoryDb.Create(phones))
+
+	assert.ErrorIs(t, categoryDb.Delete(electronics.ID.String()), ErrCategoryHasChildren)
+	assert.NoError(t, categoryDb.Delete(phones.ID.String()))
+	assert.NoError(t, categoryDb.Delete(electronics.ID.String()))
+	_, err := categoryDb.FindById(electronics.ID.String())
+	assert.Error(t, err)
+}
+
+func TestFindAllProductsByCategory(t *testing.T) {
+	db := newCategoryTestDB(t)
+	categoryDb := NewCategoryDB(db)
+	productDb := NewProductDB(db)
+	electronics, _ := entity.NewCategory("Electronics", nil)
+	phones, _ := entity.NewCategory("Phones", &electronics.ID)
+	assert.NoError(t, categoryDb.Create(electronics))
+	assert.NoError(t, categoryDb.Create(phones))
+
+	tv, _ := entity.NewProduct("TV", pkg.NewMoney(100000, "USD"))
+	phone, _ := entity.NewProduct("Phone", pkg.NewMoney(50000, "USD"))
+	book, _ := entity.NewProduct("Book", pkg.NewMoney(2000, "USD"))
+	for _, p := range []*entity.Product{tv, phone, book} {
+		assert.NoError(t, productDb.Create(p))
+	}
+	assert.NoError(t, productDb.SetCategories(tv.ID.String(), []string{electronics.ID.String()}))
+	assert.NoError(t, productDb.SetCategories(phone.ID.String(), []string{phones.ID.String(), phones.ID.String()}))
+	assert.ErrorIs(t, productDb.SetCategories(book.ID.String(), []string{"unknown"}), ErrUnknownCategory)
+
+	products, err := productDb.FindAll(0, 0, "asc", ProductFilter{CategoryID: electronics.ID.String()})
+	assert.NoError(t, err)
+	assert.Len(t, products, 1)
+	assert.Equal(t, "TV", products[0].Name)
+
+	products, err = productDb.FindAll(0, 0, "asc", ProductFilter{CategoryID: electronics.ID.String(), IncludeDescendants: true})
+	assert.NoError(t, err)
+	assert.Len(t, products, 2)
+
+	categories, err := productDb.FindCategories(phone.ID.String())
+	assert.NoError(t, err)
+	assert.Len(t, categories, 1)
+	assert.Equal(t, "Phones", categories[0].Name)
+
+	assert.NoError(t, productDb.SetCategories(phone.ID.String(), nil))
+	categories, err = productDb.FindCategories(phone.ID.String())
+	assert.NoError(t, err)
+	assert.Empty(t, categories)
+}
@@ -0,0 +1,123 @@
+package database
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"time"
+
+	mysqldriver "github.com/go-sql-driver/mysql"
+	"gorm.io/driver/mysql"
+	"gorm.io/driver/postgres"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+var (
+	ErrDriverIsRequired  = errors.New("database driver is required")
+	ErrUnsupportedDriver = errors.New("unsupported database driver")
+)
+
+// Config holds everything needed to open a connection to one of the
+// supported backends. For sqlite, Name is the path of the database file.
+type Config struct {
+	Driver          string
+	Host            string
+	Port            string
+	User            string
+	Password        string
+	Name            string
+	MaxOpenConns    int
+	MaxIdleConns    int
+	ConnMaxLifetime time.Duration
+}
+
+// DSN builds the driver specific data source name for the config.
+func (c Config) DSN() (string, error) {
+	switch c.Driver {
+	case "sqlite":
+		return c.Name, nil
+	case "mysql":
+		// the driver formats a DSN it parses back to the same values, whatever
+		// the password holds, and brackets IPv6 hosts
+		cfg := mysqldriver.NewConfig()
+		cfg.User = c.User
+		cfg.Passwd = c.Password
+		cfg.Net = "tcp"
+		cfg.Addr = net.JoinHostPort(c.Host, c.Port)
+		cfg.DBName = c.Name
+		cfg.ParseTime = true
+		cfg.Loc = time.UTC
+		cfg.Params = map[string]string{"charset": "utf8mb4"}
+		return cfg.FormatDSN(), nil
+	case "postgres":
+		u := url.URL{
+			Scheme:   "postgres",
+			User:     url.UserPassword(c.User, c.Password),
+			Host:     c.Host + ":" + c.Port,
+			Path:     c.Name,
+			RawQuery: "sslmode=disable&TimeZone=UTC",
+		}
+		return u.String(), nil
+	case "":
+		return "", ErrDriverIsRequired
+	default:
+		return "", fmt.Errorf("%w: %q", ErrUnsupportedDriver, c.Driver)
+	}
+}
+
+// Dialector returns the GORM dialector matching the configured driver.
+func (c Config) Dialector() (gorm.Dialector, error) {
+	dsn, err := c.DSN()
+	if err != nil {
+		return nil, err
+	}
+	switch c.Driver {
+	case "mysql":
+		return mysql.Open(dsn), nil
+	case "postgres":
+		return postgres.Open(dsn), nil
+	default:
+		return sqlite.Open(dsn), nil
+	}
+}
+
+// Open connects to the configured database, applies the pool settings and
+// pings it so that a misconfigured backend fails at startup.
+func Open(c Config) (*gorm.DB, error) {
+	dialector, err := c.Dialector()
+	if err != nil {
+		return nil, fmt.Errorf("database: %w", err)
+	}
+	// translated errors let repositories tell unique violations apart
+	db, err := gorm.Open(dialector, &gorm.Config{TranslateError: true})
+	if err != nil {
+		return nil, fmt.Errorf("database: connecting to %s: %w", c.target(), err)
+	}
+	sqlDB, err := db.DB()
+	if err != nil {
+		return nil, fmt.Errorf("database: %w", err)
+	}
+	if c.MaxOpenConns > 0 {
+		sqlDB.SetMaxOpenConns(c.MaxOpenConns)
+	}
+	if c.MaxIdleConns > 0 {
+		sqlDB.SetMaxIdleConns(c.MaxIdleConns)
+	}
+	if c.ConnMaxLifetime > 0 {
+		sqlDB.SetConnMaxLifetime(c.ConnMaxLifetime)
+	}
+	if err := sqlDB.Ping(); err != nil {
+		return nil, fmt.Errorf("database: pinging %s: %w", c.target(), err)
+	}
+	return db, nil
+}
+
+// target describes the connection without leaking the password.
+func (c Config) target() string {
+	if c.Driver == "sqlite" {
+		return fmt.Sprintf("sqlite file %q", c.Name)
+	}
+	return fmt.Sprintf("%s database %q at %s:%s as %q", c.Driver, c.Name, c.Host, c.Port, c.User)
+}
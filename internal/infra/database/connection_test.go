@@ -0,0 +1,75 @@
+package database
+
+import (
+	"testing"
+	"time"
+
+	mysqldriver "github.com/go-sql-driver/mysql"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestConfigDSN(t *testing.T) {
+	dsn, err := Config{Driver: "sqlite", Name: "test.db"}.DSN()
+	assert.NoError(t, err)
+	assert.Equal(t, "test.db", dsn)
+
+	dsn, err = Config{Driver: "mysql", Host: "localhost", Port: "3306", User: "root", Password: "root", Name: "goapi"}.DSN()
+	assert.NoError(t, err)
+	assert.Equal(t, "root:root@tcp(localhost:3306)/goapi?parseTime=true&charset=utf8mb4", dsn)
+
+	dsn, err = Config{Driver: "postgres", Host: "db", Port: "5432", User: "app", Password: "p@ss", Name: "goapi"}.DSN()
+	assert.NoError(t, err)
+	assert.Equal(t, "postgres://app:p%40ss@db:5432/goapi?sslmode=disable&TimeZone=UTC", dsn)
+}
+
+func TestConfigMySQLDSN(t *testing.T) {
+	tests := []struct {
+		name     string
+		host     string
+		password string
+		addr     string
+	}{
+		{"plain password", "db", "secret", "db:3306"},
+		{"password with separators", "db", "p@ss/w:rd?", "db:3306"},
+		{"password with every special character", "db", "@/:?&=#%()\\'\" ", "db:3306"},
+		{"password ending with @", "db", "secret@", "db:3306"},
+		{"ipv6 host", "::1", "p@ss", "[::1]:3306"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dsn, err := Config{Driver: "mysql", Host: tt.host, Port: "3306", User: "app", Password: tt.password, Name: "goapi"}.DSN()
+			assert.NoError(t, err)
+
+			cfg, err := mysqldriver.ParseDSN(dsn)
+			assert.NoError(t, err)
+			assert.Equal(t, "app", cfg.User)
+			assert.Equal(t, tt.password, cfg.Passwd)
+			assert.Equal(t, "tcp", cfg.Net)
+			assert.Equal(t, tt.addr, cfg.Addr)
+			assert.Equal(t, "goapi", cfg.DBName)
+			assert.True(t, cfg.ParseTime)
+			assert.Equal(t, time.UTC, cfg.Loc)
+			assert.Equal(t, map[string]string{"charset": "utf8mb4"}, cfg.Params)
+		})
+	}
+}
+
+func TestConfigDSNWhenDriverIsInvalid(t *testing.T) {
+	_, err := Config{}.DSN()
+	assert.ErrorIs(t, err, ErrDriverIsRequired)
+
+	_, err = Config{Driver: "oracle"}.DSN()
+	assert.ErrorIs(t, err, ErrUnsupportedDriver)
+}
+
+func TestOpenSqlite(t *testing.T) {
+	db, err := Open(Config{Driver: "sqlite", Name: "file::memory:", MaxOpenConns: 1})
+	assert.NoError(t, err)
+	assert.NotNil(t, db)
+}
+
+func TestOpenWhenDriverIsUnsupported(t *testing.T) {
+	db, err := Open(Config{Driver: "oracle"})
+	assert.ErrorIs(t, err, ErrUnsupportedDriver)
+	assert.Nil(t, db)
+}
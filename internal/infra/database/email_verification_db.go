@@ -0,0 +1,80 @@
+package database
+
+import (
+	"errors"
+	"time"
+
+	"github.com/brenoproti/go-api/internal/entity"
+	"gorm.io/gorm"
+)
+
+type EmailVerificationDB struct {
+	DB *gorm.DB
+}
+
+func NewEmailVerificationDB(db *gorm.DB) *EmailVerificationDB {
+	return &EmailVerificationDB{
+		DB: db,
+	}
+}
+
+func (v *EmailVerificationDB) Create(verification *entity.EmailVerification) error {
+	return v.DB.Create(verification).Error
+}
+
+// Verify marks the email of the user the token was issued to as verified and
+// uses up every pending verification token of theirs. The token is rejected
+// if the user changed their email since it was issued.
+func (v *EmailVerificationDB) Verify(token string) (*entity.User, error) {
+	var user entity.User
+	err := v.DB.Transaction(func(tx *gorm.DB) error {
+		var verification entity.EmailVerification
+		err := tx.First(&verification, "token_hash = ?", entity.HashToken(token)).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return entity.ErrInvalidVerificationToken
+		}
+		if err != nil {
+			return err
+		}
+		now := time.Now()
+		if !verification.IsUsable(now) {
+			return entity.ErrInvalidVerificationToken
+		}
+		err = tx.First(&user, "id = ?", verification.UserID).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return entity.ErrInvalidVerificationToken
+		}
+		if err != nil {
+			return err
+		}
+		if user.Email != verification.Email {
+			return entity.ErrInvalidVerificationToken
+		}
+		// only one of concurrent verifications with the same token can use it
+		result := tx.Model(&entity.EmailVerification{}).
+			Where("user_id = ? AND used_at IS NULL", user.ID).
+			Update("used_at", now)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return entity.ErrInvalidVerificationToken
+		}
+		if user.IsEmailVerified() {
+			return nil
+		}
+		user.EmailVerifiedAt = &now
+		return tx.Model(&user).Update("email_verified_at", now).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+// DeleteExpired removes the verification tokens that expired before the given
+// time, used or not.
+func (v *EmailVerificationDB) DeleteExpired(before time.Time) (int64, error) {
+	result := v.DB.Where("expires_at < ?", before).Delete(&entity.EmailVerification{})
+	return result.RowsAffected, result.Error
+}
@@ -0,0 +1,77 @@
+package database
+
+import (
+	"testing"
+	"time"
+
+	"github.com/brenoproti/go-api/internal/entity"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+func newEmailVerificationTestDB(t *testing.T) (*gorm.DB, *entity.User) {
+	db, err := gorm.Open(sqlite.Open("file::memory:"), &gorm.Config{})
+	if err != nil {
+		t.Error(err)
+	}
+	db.AutoMigrate(&entity.User{}, &entity.EmailVerification{})
+	user, _ := entity.NewUser("John", "j@j.com", "Str0ng-Passw0rd")
+	assert.NoError(t, NewUser(db).Create(user))
+	return db, user
+}
+
+func TestVerifyEmail(t *testing.T) {
+	db, user := newEmailVerificationTestDB(t)
+	verificationDb := NewEmailVerificationDB(db)
+	verification, token, _ := entity.NewEmailVerification(user, time.Hour)
+	assert.NoError(t, verificationDb.Create(verification))
+
+	verified, err := verificationDb.Verify(token)
+	assert.NoError(t, err)
+	assert.True(t, verified.IsEmailVerified())
+	found, err := NewUser(db).FindById(user.ID.String())
+	assert.NoError(t, err)
+	assert.True(t, found.IsEmailVerified())
+
+	_, err = verificationDb.Verify(token)
+	assert.ErrorIs(t, err, entity.ErrInvalidVerificationToken)
+	_, err = verificationDb.Verify("unknown")
+	assert.ErrorIs(t, err, entity.ErrInvalidVerificationToken)
+
+	// only the name changes, the email stays verified
+	assert.NoError(t, NewUser(db).Update(&entity.User{ID: user.ID, Name: "Johnny", Email: "j@j.com"}))
+	found, _ = NewUser(db).FindById(user.ID.String())
+	assert.True(t, found.IsEmailVerified())
+	assert.NoError(t, NewUser(db).Update(&entity.User{ID: user.ID, Name: "Johnny", Email: "johnny@j.com"}))
+	found, _ = NewUser(db).FindById(user.ID.String())
+	assert.False(t, found.IsEmailVerified())
+}
+
+func TestVerifyEmailAfterItChanged(t *testing.T) {
+	db, user := newEmailVerificationTestDB(t)
+	verificationDb := NewEmailVerificationDB(db)
+	verification, token, _ := entity.NewEmailVerification(user, time.Hour)
+	assert.NoError(t, verificationDb.Create(verification))
+	assert.NoError(t, NewUser(db).Update(&entity.User{ID: user.ID, Name: "John", Email: "johnny@j.com"}))
+
+	_, err := verificationDb.Verify(token)
+	assert.ErrorIs(t, err, entity.ErrInvalidVerificationToken)
+	found, _ := NewUser(db).FindById(user.ID.String())
+	assert.False(t, found.IsEmailVerified())
+}
+
+func TestExpiredEmailVerifications(t *testing.T) {
+	db, user := newEmailVerificationTestDB(t)
+	verificationDb := NewEmailVerificationDB(db)
+	expired, token, _ := entity.NewEmailVerification(user, time.Hour)
+	expired.CreatedAt = time.Now().Add(-2 * time.Hour)
+	expired.ExpiresAt = time.Now().Add(-time.Hour)
+	assert.NoError(t, verificationDb.Create(expired))
+
+	_, err := verificationDb.Verify(token)
+	assert.ErrorIs(t, err, entity.ErrInvalidVerificationToken)
+	deleted, err := verificationDb.DeleteExpired(time.Now())
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), deleted)
+}
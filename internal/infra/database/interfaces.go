@@ -1,16 +1,104 @@
 package database
 
-import "github.com/brenoproti/go-api/internal/entity"
+import (
+	"time"
+
+	"github.com/brenoproti/go-api/internal/entity"
+)
 
 type UserInterface interface {
 	Create(user *entity.User) error
 	FindByEmail(email string) (*entity.User, error)
+	FindById(id string) (*entity.User, error)
+	FindAll(page, limit int) ([]entity.User, error)
+	Count() (int64, error)
+	Update(user *entity.User) error
+	SetRole(id string, role entity.Role) (*entity.User, error)
+	UpdatePassword(user *entity.User) error
+	Delete(id string) error
+}
+
+type RefreshTokenInterface interface {
+	Create(token *entity.RefreshToken) error
+	Rotate(token string, ttl time.Duration) (*entity.RefreshToken, string, error)
+	Revoke(token, userID string) error
+	RevokeFamily(familyID string) error
+	RevokeUser(userID string) error
+	DeleteExpired(before time.Time) (int64, error)
+}
+
+type PasswordResetInterface interface {
+	Create(reset *entity.PasswordReset) error
+	Reset(token, password string) (*entity.User, error)
+	DeleteExpired(before time.Time) (int64, error)
+}
+
+type EmailVerificationInterface interface {
+	Create(verification *entity.EmailVerification) error
+	Verify(token string) (*entity.User, error)
+	DeleteExpired(before time.Time) (int64, error)
+}
+
+type RevokedTokenInterface interface {
+	Revoke(token *entity.RevokedToken) error
+	IsRevoked(id string) (bool, error)
+	DeleteExpired(before time.Time) (int64, error)
+}
+
+type LoginAttemptInterface interface {
+	Create(attempt *entity.LoginAttempt) error
+	Reserve(attempt *entity.LoginAttempt, since time.Time) (byEmail, byIP entity.LoginFailures, err error)
+	Resolve(attempt *entity.LoginAttempt) error
+	EmailFailures(email string, since time.Time) (entity.LoginFailures, error)
+	IPFailures(ip string, since time.Time) (entity.LoginFailures, error)
+	FindAll(page, limit int, filter LoginAttemptFilter) ([]entity.LoginAttempt, error)
+	Count(filter LoginAttemptFilter) (int64, error)
+	Purge(before time.Time) (int64, error)
+}
+
+type TOTPInterface interface {
+	Enroll(secret *entity.TOTPSecret) error
+	FindByUser(userID string) (*entity.TOTPSecret, error)
+	Confirm(userID string, step int64, codes []entity.RecoveryCode) error
+	UseCode(userID string, step int64) error
+	UseRecoveryCode(userID, code string) error
+	ReplaceRecoveryCodes(userID string, codes []entity.RecoveryCode) error
+	CountRecoveryCodes(userID string) (int64, error)
+	Disable(userID string) error
 }
 
 type ProductInterface interface {
 	Create(product *entity.Product) error
-	FindAll(page, limit int, sort string) ([]entity.Product, error)
+	FindAll(page, limit int, sort string, filter ProductFilter) ([]entity.Product, error)
+	FindAfter(cursor *ProductCursor, limit int, filter ProductFilter) ([]entity.Product, *ProductCursor, error)
+	Count(filter ProductFilter) (int64, error)
 	FindById(id string) (*entity.Product, error)
+	FindCategories(id string) ([]entity.Category, error)
+	SetCategories(id string, categoryIDs []string) error
 	Update(product *entity.Product) error
+	Delete(id string, version int64) error
+	FindDeleted(page, limit int) ([]entity.Product, error)
+	CountDeleted() (int64, error)
+	Restore(id string) (*entity.Product, error)
+	Purge(before time.Time) (int64, error)
+}
+
+type CategoryInterface interface {
+	Create(category *entity.Category) error
+	FindAll() ([]entity.Category, error)
+	FindById(id string) (*entity.Category, error)
+	FindDescendantIDs(id string) ([]string, error)
+	Update(category *entity.Category) error
 	Delete(id string) error
 }
+
+type InventoryInterface interface {
+	FindStock(productID string) (*entity.Stock, error)
+	Increment(productID string, quantity int) (*entity.Stock, error)
+	Decrement(productID string, quantity int) (*entity.Stock, error)
+	Reserve(productID string, quantity int, ttl time.Duration) (*entity.Reservation, error)
+	FindReservation(id string) (*entity.Reservation, error)
+	Release(id string) error
+	Commit(id string) (*entity.Stock, error)
+	DeleteExpiredReservations(before time.Time) (int64, error)
+}
@@ -0,0 +1,204 @@
+package database
+
+import (
+	"errors"
+	"time"
+
+	"github.com/brenoproti/go-api/internal/entity"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// InventoryDB keeps product stock levels and reservations. Every change locks
+// the stock row of the product first, so concurrent requests are serialized
+// and the quantity can never go below what is on hand.
+type InventoryDB struct {
+	DB *gorm.DB
+}
+
+func NewInventoryDB(db *gorm.DB) *InventoryDB {
+	return &InventoryDB{
+		DB: db,
+	}
+}
+
+// FindStock reads the stock of a product without locking it, a product
+// whose stock never changed having none.
+func (i *InventoryDB) FindStock(productID string) (*entity.Stock, error) {
+	var product entity.Product
+	if err := i.DB.Select("id").First(&product, "id = ?", productID).Error; err != nil {
+		return nil, err
+	}
+	stock := entity.Stock{ProductID: product.ID}
+	err := i.DB.First(&stock, "product_id = ?", productID).Error
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+	if err := withReserved(i.DB, &stock); err != nil {
+		return nil, err
+	}
+	return &stock, nil
+}
+
+func (i *InventoryDB) Increment(productID string, quantity int) (*entity.Stock, error) {
+	if quantity <= 0 {
+		return nil, entity.ErrInvalidQuantity
+	}
+	return i.update(productID, func(tx *gorm.DB, stock *entity.Stock) error {
+		stock.Quantity += quantity
+		stock.Available += quantity
+		return saveStock(tx, stock)
+	})
+}
+
+func (i *InventoryDB) Decrement(productID string, quantity int) (*entity.Stock, error) {
+	if quantity <= 0 {
+		return nil, entity.ErrInvalidQuantity
+	}
+	return i.update(productID, func(tx *gorm.DB, stock *entity.Stock) error {
+		if stock.Available < quantity {
+			return entity.ErrInsufficientStock
+		}
+		stock.Quantity -= quantity
+		stock.Available -= quantity
+		return saveStock(tx, stock)
+	})
+}
+
+func (i *InventoryDB) Reserve(productID string, quantity int, ttl time.Duration) (*entity.Reservation, error) {
+	var reservation *entity.Reservation
+	_, err := i.update(productID, func(tx *gorm.DB, stock *entity.Stock) error {
+		var err error
+		reservation, err = entity.NewReservation(stock.ProductID, quantity, ttl)
+		if err != nil {
+			return err
+		}
+		if stock.Available < quantity {
+			return entity.ErrInsufficientStock
+		}
+		return tx.Create(reservation).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return reservation, nil
+}
+
+func (i *InventoryDB) FindReservation(id string) (*entity.Reservation, error) {
+	var reservation entity.Reservation
+	err := i.DB.First(&reservation, "id = ?", id).Error
+	if err != nil {
+		return nil, err
+	}
+	return &reservation, nil
+}
+
+// Release gives the reserved quantity back to the available stock.
+func (i *InventoryDB) Release(id string) error {
+	reservation, err := i.FindReservation(id)
+	if err != nil {
+		return err
+	}
+	return i.DB.Delete(reservation).Error
+}
+
+// Commit turns a reservation into a definitive decrement of the stock.
+func (i *InventoryDB) Commit(id string) (*entity.Stock, error) {
+	reservation, err := i.FindReservation(id)
+	if err != nil {
+		return nil, err
+	}
+	return i.update(reservation.ProductID.String(), func(tx *gorm.DB, stock *entity.Stock) error {
+		// the reservation may have been released or committed while waiting
+		// for the lock
+		var current entity.Reservation
+		if err := tx.First(&current, "id = ?", id).Error; err != nil {
+			return err
+		}
+		if current.IsExpired(time.Now()) {
+			return entity.ErrReservationExpired
+		}
+		if err := tx.Delete(&current).Error; err != nil {
+			return err
+		}
+		stock.Quantity -= current.Quantity
+		stock.Reserved -= current.Quantity
+		return saveStock(tx, stock)
+	})
+}
+
+// DeleteExpiredReservations removes reservations that expired before the
+// given time and returns how many were removed.
+func (i *InventoryDB) DeleteExpiredReservations(before time.Time) (int64, error) {
+	result := i.DB.Where("expires_at <= ?", before).Delete(&entity.Reservation{})
+	return result.RowsAffected, result.Error
+}
+
+func (i *InventoryDB) update(productID string, fn func(tx *gorm.DB, stock *entity.Stock) error) (*entity.Stock, error) {
+	var stock *entity.Stock
+	err := i.DB.Transaction(func(tx *gorm.DB) error {
+		var err error
+		stock, err = lockStock(tx, productID)
+		if err != nil {
+			return err
+		}
+		return fn(tx, stock)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return stock, nil
+}
+
+// lockStock creates the stock row of an existing product when missing, locks
+// it for the rest of the transaction and fills in the reserved quantity.
+func lockStock(tx *gorm.DB, productID string) (*entity.Stock, error) {
+	var product entity.Product
+	if err := tx.Select("id").First(&product, "id = ?", productID).Error; err != nil {
+		return nil, err
+	}
+	err := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&entity.Stock{
+		ProductID: product.ID,
+		UpdatedAt: time.Now(),
+	}).Error
+	if err != nil {
+		return nil, err
+	}
+	var stock entity.Stock
+	err = tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&stock, "product_id = ?", productID).Error
+	if err != nil {
+		return nil, err
+	}
+	if err := withReserved(tx, &stock); err != nil {
+		return nil, err
+	}
+	return &stock, nil
+}
+
+// withReserved fills in the quantity held by the reservations that have not
+// expired yet, and what remains available.
+func withReserved(tx *gorm.DB, stock *entity.Stock) error {
+	var reserved int64
+	err := tx.Model(&entity.Reservation{}).
+		Where("product_id = ? AND expires_at > ?", stock.ProductID, time.Now()).
+		Select("COALESCE(SUM(quantity), 0)").
+		Scan(&reserved).Error
+	if err != nil {
+		return err
+	}
+	stock.Reserved = int(reserved)
+	stock.Available = stock.Quantity - stock.Reserved
+	return nil
+}
+
+// saveStock writes the new quantity, refusing to go below zero even if the
+// row was changed outside of a locking transaction.
+func saveStock(tx *gorm.DB, stock *entity.Stock) error {
+	if stock.Quantity < 0 {
+		return entity.ErrInsufficientStock
+	}
+	stock.UpdatedAt = time.Now()
+	return tx.Model(&entity.Stock{}).
+		Where("product_id = ?", stock.ProductID).
+		Updates(map[string]interface{}{"quantity": stock.Quantity, "updated_at": stock.UpdatedAt}).Error
+}
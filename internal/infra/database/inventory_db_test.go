@@ -0,0 +1,184 @@
+package database
+
+import (
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/brenoproti/go-api/internal/entity"
+	pkg "github.com/brenoproti/go-api/pkg/entity"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+func newInventoryTestDB(t *testing.T) (*gorm.DB, *entity.Product) {
+	db, err := gorm.Open(sqlite.Open("file::memory:"), &gorm.Config{})
+	if err != nil {
+		t.Error(err)
+	}
+	// every connection would open a database of its own
+	sqlDB, err := db.DB()
+	if err != nil {
+		t.Error(err)
+	}
+	sqlDB.SetMaxOpenConns(1)
+	return migrateInventoryTestDB(t, db)
+}
+
+// newConcurrentInventoryTestDB opens a database file that several
+// connections share, so that transactions really run concurrently. SQLite
+// has no row locks, so they take the write lock as soon as they begin.
+func newConcurrentInventoryTestDB(t *testing.T) (*gorm.DB, *entity.Product) {
+	dsn := filepath.Join(t.TempDir(), "inventory.db") + "?_busy_timeout=10000&_txlock=immediate&_journal_mode=WAL"
+	db, err := gorm.Open(sqlite.Open(dsn), &gorm.Config{})
+	if err != nil {
+		t.Error(err)
+	}
+	sqlDB, err := db.DB()
+	if err != nil {
+		t.Error(err)
+	}
+	sqlDB.SetMaxOpenConns(10)
+	t.Cleanup(func() { sqlDB.Close() })
+	return migrateInventoryTestDB(t, db)
+}
+
+func migrateInventoryTestDB(t *testing.T, db *gorm.DB) (*gorm.DB, *entity.Product) {
+	db.AutoMigrate(&entity.Product{}, &entity.Stock{}, &entity.Reservation{})
+	product, _ := entity.NewProduct("Product 1", pkg.NewMoney(1050, "USD"))
+	assert.NoError(t, NewProductDB(db).Create(product))
+	return db, product
+}
+
+func TestFindStock(t *testing.T) {
+	db, product := newInventoryTestDB(t)
+	inventoryDb := NewInventoryDB(db)
+	stock, err := inventoryDb.FindStock(product.ID.String())
+	assert.NoError(t, err)
+	assert.Equal(t, product.ID, stock.ProductID)
+	assert.Equal(t, 0, stock.Quantity)
+	// reading does not create the stock row
+	var count int64
+	db.Model(&entity.Stock{}).Count(&count)
+	assert.Equal(t, int64(0), count)
+
+	_, err = inventoryDb.FindStock("unknown")
+	assert.ErrorIs(t, err, gorm.ErrRecordNotFound)
+}
+
+func TestIncrementAndDecrementStock(t *testing.T) {
+	db, product := newInventoryTestDB(t)
+	inventoryDb := NewInventoryDB(db)
+	stock, err := inventoryDb.Increment(product.ID.String(), 10)
+	assert.NoError(t, err)
+	assert.Equal(t, 10, stock.Quantity)
+	assert.Equal(t, 10, stock.Available)
+
+	stock, err = inventoryDb.Decrement(product.ID.String(), 4)
+	assert.NoError(t, err)
+	assert.Equal(t, 6, stock.Quantity)
+
+	_, err = inventoryDb.Decrement(product.ID.String(), 7)
+	assert.ErrorIs(t, err, entity.ErrInsufficientStock)
+	_, err = inventoryDb.Increment(product.ID.String(), 0)
+	assert.ErrorIs(t, err, entity.ErrInvalidQuantity)
+
+	stock, err = inventoryDb.FindStock(product.ID.String())
+	assert.NoError(t, err)
+	assert.Equal(t, 6, stock.Quantity)
+}
+
+func TestConcurrentDecrementNeverGoesNegative(t *testing.T) {
+	db, product := newConcurrentInventoryTestDB(t)
+	inventoryDb := NewInventoryDB(db)
+	_, err := inventoryDb.Increment(product.ID.String(), 10)
+	assert.NoError(t, err)
+
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	succeeded := 0
+	// released at once, so that the decrements overlap
+	start := make(chan struct{})
+	for i := 0; i < 25; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			<-start
+			_, err := inventoryDb.Decrement(product.ID.String(), 1)
+			if err != nil {
+				assert.ErrorIs(t, err, entity.ErrInsufficientStock)
+				return
+			}
+			mu.Lock()
+			succeeded++
+			mu.Unlock()
+		}()
+	}
+	close(start)
+	wg.Wait()
+	assert.Equal(t, 10, succeeded)
+	stock, err := inventoryDb.FindStock(product.ID.String())
+	assert.NoError(t, err)
+	assert.Equal(t, 0, stock.Quantity)
+}
+
+func TestReserveReleaseAndCommit(t *testing.T) {
+	db, product := newInventoryTestDB(t)
+	inventoryDb := NewInventoryDB(db)
+	_, err := inventoryDb.Increment(product.ID.String(), 5)
+	assert.NoError(t, err)
+
+	reservation, err := inventoryDb.Reserve(product.ID.String(), 3, time.Minute)
+	assert.NoError(t, err)
+	stock, err := inventoryDb.FindStock(product.ID.String())
+	assert.NoError(t, err)
+	assert.Equal(t, 5, stock.Quantity)
+	assert.Equal(t, 3, stock.Reserved)
+	assert.Equal(t, 2, stock.Available)
+
+	_, err = inventoryDb.Reserve(product.ID.String(), 3, time.Minute)
+	assert.ErrorIs(t, err, entity.ErrInsufficientStock)
+	_, err = inventoryDb.Decrement(product.ID.String(), 3)
+	assert.ErrorIs(t, err, entity.ErrInsufficientStock)
+
+	assert.NoError(t, inventoryDb.Release(reservation.ID.String()))
+	stock, err = inventoryDb.FindStock(product.ID.String())
+	assert.NoError(t, err)
+	assert.Equal(t, 5, stock.Available)
+
+	reservation, err = inventoryDb.Reserve(product.ID.String(), 2, time.Minute)
+	assert.NoError(t, err)
+	stock, err = inventoryDb.Commit(reservation.ID.String())
+	assert.NoError(t, err)
+	assert.Equal(t, 3, stock.Quantity)
+	assert.Equal(t, 0, stock.Reserved)
+	assert.Equal(t, 3, stock.Available)
+
+	_, err = inventoryDb.Commit(reservation.ID.String())
+	assert.ErrorIs(t, err, gorm.ErrRecordNotFound)
+}
+
+func TestExpiredReservations(t *testing.T) {
+	db, product := newInventoryTestDB(t)
+	inventoryDb := NewInventoryDB(db)
+	_, err := inventoryDb.Increment(product.ID.String(), 5)
+	assert.NoError(t, err)
+	reservation, err := inventoryDb.Reserve(product.ID.String(), 5, time.Minute)
+	assert.NoError(t, err)
+	err = db.Model(reservation).Update("expires_at", time.Now().Add(-time.Second)).Error
+	assert.NoError(t, err)
+
+	stock, err := inventoryDb.FindStock(product.ID.String())
+	assert.NoError(t, err)
+	assert.Equal(t, 5, stock.Available)
+	_, err = inventoryDb.Commit(reservation.ID.String())
+	assert.ErrorIs(t, err, entity.ErrReservationExpired)
+
+	deleted, err := inventoryDb.DeleteExpiredReservations(time.Now())
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), deleted)
+	_, err = inventoryDb.FindReservation(reservation.ID.String())
+	assert.Error(t, err)
+}
@@ -0,0 +1,155 @@
+package database
+
+import (
+	"errors"
+	"time"
+
+	"github.com/brenoproti/go-api/internal/entity"
+	"gorm.io/gorm"
+)
+
+// LoginAttemptFilter narrows down the attempts returned by FindAll. Zero
+// values mean no filtering.
+type LoginAttemptFilter struct {
+	Email  string
+	IP     string
+	UserID string
+	Result entity.LoginResult
+}
+
+func (f LoginAttemptFilter) apply(db *gorm.DB) *gorm.DB {
+	query := db
+	if f.Email != "" {
+		query = query.Where("email = ?", entity.NormalizeEmail(f.Email))
+	}
+	if f.IP != "" {
+		query = query.Where("ip = ?", f.IP)
+	}
+	if f.UserID != "" {
+		query = query.Where("user_id = ?", f.UserID)
+	}
+	if f.Result != "" {
+		query = query.Where("result = ?", f.Result)
+	}
+	return query
+}
+
+type LoginAttemptDB struct {
+	DB *gorm.DB
+}
+
+func NewLoginAttemptDB(db *gorm.DB) *LoginAttemptDB {
+	return &LoginAttemptDB{
+		DB: db,
+	}
+}
+
+func (l *LoginAttemptDB) Create(attempt *entity.LoginAttempt) error {
+	return l.DB.Create(attempt).Error
+}
+
+// Reserve records an attempt before it is carried out, such as a pending
+// login before its password is checked, then sums up the other attempts it is
+// throttled on with its email and from its IP address since the given time,
+// see entity.LoginResult.ThrottledOn. Of concurrent attempts, the ones
+// reserved later see the earlier ones, see entity.LoginPending.
+func (l *LoginAttemptDB) Reserve(attempt *entity.LoginAttempt, since time.Time) (byEmail, byIP entity.LoginFailures, err error) {
+	if err := l.DB.Create(attempt).Error; err != nil {
+		return entity.LoginFailures{}, entity.LoginFailures{}, err
+	}
+	results := attempt.Result.ThrottledOn()
+	byEmail, err = l.emailFailures(attempt.Email, since, attempt.ID.String(), results)
+	if err != nil {
+		return entity.LoginFailures{}, entity.LoginFailures{}, err
+	}
+	byIP, err = l.failures("ip", attempt.IP, since, attempt.ID.String(), results)
+	if err != nil {
+		return entity.LoginFailures{}, entity.LoginFailures{}, err
+	}
+	return byEmail, byIP, nil
+}
+
+// Resolve saves how a reserved attempt went, and who the email belongs to.
+func (l *LoginAttemptDB) Resolve(attempt *entity.LoginAttempt) error {
+	if err := attempt.Validate(); err != nil {
+		return err
+	}
+	return l.DB.Model(&entity.LoginAttempt{}).Where("id = ?", attempt.ID).Updates(map[string]interface{}{
+		"result":  attempt.Result,
+		"user_id": attempt.UserID,
+	}).Error
+}
+
+// EmailFailures sums up the failed and pending logins with the email since
+// the given time. Logging in successfully starts over.
+func (l *LoginAttemptDB) EmailFailures(email string, since time.Time) (entity.LoginFailures, error) {
+	return l.emailFailures(email, since, "", entity.LoginPending.ThrottledOn())
+}
+
+func (l *LoginAttemptDB) emailFailures(email string, since time.Time, except string, results []entity.LoginResult) (entity.LoginFailures, error) {
+	email = entity.NormalizeEmail(email)
+	var success entity.LoginAttempt
+	err := l.DB.Where("email = ? AND result = ? AND created_at > ?", email, entity.LoginSucceeded, since).
+		Order("created_at desc").First(&success).Error
+	if err == nil {
+		since = success.CreatedAt
+	} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return entity.LoginFailures{}, err
+	}
+	return l.failures("email", email, since, except, results)
+}
+
+// IPFailures sums up the failed and pending logins from the IP address since
+// the given time, whatever the email. Logging in successfully does not start
+// over, or one account of their own would let anyone keep guessing the others.
+func (l *LoginAttemptDB) IPFailures(ip string, since time.Time) (entity.LoginFailures, error) {
+	return l.failures("ip", ip, since, "", entity.LoginPending.ThrottledOn())
+}
+
+// failures counts the attempts with the given results, leaving out the one
+// with the except ID when given.
+func (l *LoginAttemptDB) failures(column, value string, since time.Time, except string, results []entity.LoginResult) (entity.LoginFailures, error) {
+	query := func() *gorm.DB {
+		return l.DB.Model(&entity.LoginAttempt{}).
+			Where(column+" = ? AND result IN ? AND created_at > ? AND id <> ?", value, results, since, except)
+	}
+	var failures entity.LoginFailures
+	if err := query().Count(&failures.Count).Error; err != nil {
+		return entity.LoginFailures{}, err
+	}
+	if failures.Count == 0 {
+		return failures, nil
+	}
+	var last entity.LoginAttempt
+	if err := query().Order("created_at desc").First(&last).Error; err != nil {
+		return entity.LoginFailures{}, err
+	}
+	failures.Last = last.CreatedAt
+	return failures, nil
+}
+
+// FindAll returns the attempts newest first.
+func (l *LoginAttemptDB) FindAll(page, limit int, filter LoginAttemptFilter) ([]entity.LoginAttempt, error) {
+	page, limit = NormalizePage(page, limit)
+	var attempts []entity.LoginAttempt
+	err := filter.apply(l.DB).Order("created_at desc").Order("id asc").
+		Offset((page - 1) * limit).Limit(limit).Find(&attempts).Error
+	if err != nil {
+		return nil, err
+	}
+	return attempts, nil
+}
+
+func (l *LoginAttemptDB) Count(filter LoginAttemptFilter) (int64, error) {
+	var total int64
+	if err := filter.apply(l.DB.Model(&entity.LoginAttempt{})).Count(&total).Error; err != nil {
+		return 0, err
+	}
+	return total, nil
+}
+
+// Purge removes the attempts made before the given time for good.
+func (l *LoginAttemptDB) Purge(before time.Time) (int64, error) {
+	result := l.DB.Where("created_at < ?", before).Delete(&entity.LoginAttempt{})
+	return result.RowsAffected, result.Error
+}
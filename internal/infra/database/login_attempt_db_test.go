@@ -0,0 +1,205 @@
+package database
+
+import (
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/brenoproti/go-api/internal/entity"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+func newLoginAttemptTestDB(t *testing.T) *LoginAttemptDB {
+	db, err := gorm.Open(sqlite.Open("file::memory:"), &gorm.Config{})
+	if err != nil {
+		t.Error(err)
+	}
+	db.AutoMigrate(&entity.LoginAttempt{})
+	return NewLoginAttemptDB(db)
+}
+
+// newConcurrentLoginAttemptTestDB opens a database file that several
+// connections share, so that reservations really run concurrently, see
+// newConcurrentInventoryTestDB.
+func newConcurrentLoginAttemptTestDB(t *testing.T) *LoginAttemptDB {
+	dsn := filepath.Join(t.TempDir(), "login_attempts.db") + "?_busy_timeout=10000&_txlock=immediate&_journal_mode=WAL"
+	db, err := gorm.Open(sqlite.Open(dsn), &gorm.Config{})
+	if err != nil {
+		t.Error(err)
+	}
+	sqlDB, err := db.DB()
+	if err != nil {
+		t.Error(err)
+	}
+	sqlDB.SetMaxOpenConns(10)
+	t.Cleanup(func() { sqlDB.Close() })
+	db.AutoMigrate(&entity.LoginAttempt{})
+	return NewLoginAttemptDB(db)
+}
+
+func createLoginAttempt(t *testing.T, db *LoginAttemptDB, email, ip string, result entity.LoginResult, at time.Time) {
+	attempt, err := entity.NewLoginAttempt(email, ip, "test", nil, result)
+	assert.NoError(t, err)
+	attempt.CreatedAt = at
+	assert.NoError(t, db.Create(attempt))
+}
+
+func TestLoginFailures(t *testing.T) {
+	attemptDb := newLoginAttemptTestDB(t)
+	now := time.Now()
+	since := now.Add(-time.Hour)
+	createLoginAttempt(t, attemptDb, "j@j.com", "10.0.0.1", entity.LoginFailed, now.Add(-2*time.Hour))
+	createLoginAttempt(t, attemptDb, "j@j.com", "10.0.0.1", entity.LoginFailed, now.Add(-3*time.Minute))
+	createLoginAttempt(t, attemptDb, "j@j.com", "10.0.0.2", entity.LoginFailed, now.Add(-2*time.Minute))
+	createLoginAttempt(t, attemptDb, "jane@j.com", "10.0.0.1", entity.LoginFailed, now.Add(-time.Minute))
+	createLoginAttempt(t, attemptDb, "jane@j.com", "10.0.0.1", entity.LoginThrottled, now)
+
+	failures, err := attemptDb.EmailFailures("J@J.com", since)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(2), failures.Count)
+	assert.WithinDuration(t, now.Add(-2*time.Minute), failures.Last, time.Millisecond)
+
+	failures, err = attemptDb.IPFailures("10.0.0.1", since)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(2), failures.Count)
+	assert.WithinDuration(t, now.Add(-time.Minute), failures.Last, time.Millisecond)
+
+	// logging in starts over for the email, not for the IP address
+	createLoginAttempt(t, attemptDb, "j@j.com", "10.0.0.1", entity.LoginSucceeded, now.Add(-90*time.Second))
+	failures, err = attemptDb.EmailFailures("j@j.com", since)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(0), failures.Count)
+	failures, err = attemptDb.IPFailures("10.0.0.1", since)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(2), failures.Count)
+
+	failures, err = attemptDb.EmailFailures("unknown@j.com", since)
+	assert.NoError(t, err)
+	assert.Equal(t, entity.LoginFailures{}, failures)
+}
+
+func TestReserveLoginAttempt(t *testing.T) {
+	attemptDb := newLoginAttemptTestDB(t)
+	now := time.Now()
+	since := now.Add(-time.Hour)
+	createLoginAttempt(t, attemptDb, "j@j.com", "10.0.0.1", entity.LoginFailed, now.Add(-time.Minute))
+
+	first, err := entity.NewLoginAttempt("j@j.com", "10.0.0.1", "test", nil, entity.LoginPending)
+	assert.NoError(t, err)
+	byEmail, byIP, err := attemptDb.Reserve(first, since)
+	assert.NoError(t, err)
+	// the attempt does not count against itself
+	assert.Equal(t, int64(1), byEmail.Count)
+	assert.Equal(t, int64(1), byIP.Count)
+
+	// but pending ones count against the others
+	second, err := entity.NewLoginAttempt("j@j.com", "10.0.0.2", "test", nil, entity.LoginPending)
+	assert.NoError(t, err)
+	byEmail, byIP, err = attemptDb.Reserve(second, since)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(2), byEmail.Count)
+	assert.Equal(t, int64(0), byIP.Count)
+
+	first.Result = entity.LoginThrottled
+	assert.NoError(t, attemptDb.Resolve(first))
+	failures, err := attemptDb.EmailFailures("j@j.com", since)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(2), failures.Count)
+	attempts, err := attemptDb.FindAll(1, 10, LoginAttemptFilter{Result: entity.LoginThrottled})
+	assert.NoError(t, err)
+	assert.Len(t, attempts, 1)
+	assert.Equal(t, first.ID, attempts[0].ID)
+
+	second.Result = "unknown"
+	assert.ErrorIs(t, attemptDb.Resolve(second), entity.ErrInvalidLoginResult)
+
+	// asking for emails is only throttled on the requests of the same kind
+	reset, err := entity.NewLoginAttempt("j@j.com", "10.0.0.1", "test", nil, entity.LoginResetRequested)
+	assert.NoError(t, err)
+	byEmail, byIP, err = attemptDb.Reserve(reset, since)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(0), byEmail.Count)
+	assert.Equal(t, int64(0), byIP.Count)
+	again, err := entity.NewLoginAttempt("j@j.com", "10.0.0.2", "test", nil, entity.LoginResetRequested)
+	assert.NoError(t, err)
+	byEmail, byIP, err = attemptDb.Reserve(again, since)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), byEmail.Count)
+	assert.Equal(t, int64(0), byIP.Count)
+	failures, err = attemptDb.EmailFailures("j@j.com", since)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(2), failures.Count)
+}
+
+func TestReserveConcurrentLoginAttempts(t *testing.T) {
+	attemptDb := newConcurrentLoginAttemptTestDB(t)
+	throttle := entity.LoginThrottle{MaxEmailFailures: 5, BaseDelay: time.Second, MaxDelay: time.Minute, Lockout: time.Hour}
+
+	const attempts = 10
+	var wg sync.WaitGroup
+	var checked int32
+	start := make(chan struct{})
+	for i := 0; i < attempts; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			attempt, err := entity.NewLoginAttempt("j@j.com", "10.0.0.1", "test", nil, entity.LoginPending)
+			assert.NoError(t, err)
+			<-start
+			now := time.Now()
+			byEmail, byIP, err := attemptDb.Reserve(attempt, throttle.Since(now))
+			assert.NoError(t, err)
+			if throttle.RetryAfter(byEmail, byIP, now) == 0 {
+				atomic.AddInt32(&checked, 1)
+			}
+		}()
+	}
+	close(start)
+	wg.Wait()
+	// the password of at most one of them gets checked
+	assert.LessOrEqual(t, checked, int32(1))
+}
+
+func TestFindAllLoginAttempts(t *testing.T) {
+	attemptDb := newLoginAttemptTestDB(t)
+	now := time.Now()
+	createLoginAttempt(t, attemptDb, "j@j.com", "10.0.0.1", entity.LoginFailed, now.Add(-2*time.Minute))
+	createLoginAttempt(t, attemptDb, "j@j.com", "10.0.0.2", entity.LoginSucceeded, now.Add(-time.Minute))
+	createLoginAttempt(t, attemptDb, "jane@j.com", "10.0.0.1", entity.LoginFailed, now)
+
+	attempts, err := attemptDb.FindAll(1, 10, LoginAttemptFilter{})
+	assert.NoError(t, err)
+	assert.Len(t, attempts, 3)
+	assert.Equal(t, "jane@j.com", attempts[0].Email)
+
+	filter := LoginAttemptFilter{Email: "J@j.com", Result: entity.LoginFailed}
+	attempts, err = attemptDb.FindAll(1, 10, filter)
+	assert.NoError(t, err)
+	assert.Len(t, attempts, 1)
+	assert.Equal(t, "10.0.0.1", attempts[0].IP)
+	total, err := attemptDb.Count(filter)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), total)
+
+	total, err = attemptDb.Count(LoginAttemptFilter{IP: "10.0.0.1"})
+	assert.NoError(t, err)
+	assert.Equal(t, int64(2), total)
+}
+
+func TestPurgeLoginAttempts(t *testing.T) {
+	attemptDb := newLoginAttemptTestDB(t)
+	now := time.Now()
+	createLoginAttempt(t, attemptDb, "j@j.com", "10.0.0.1", entity.LoginFailed, now.Add(-48*time.Hour))
+	createLoginAttempt(t, attemptDb, "j@j.com", "10.0.0.1", entity.LoginFailed, now)
+
+	purged, err := attemptDb.Purge(now.Add(-24 * time.Hour))
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), purged)
+	total, err := attemptDb.Count(LoginAttemptFilter{})
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), total)
+}
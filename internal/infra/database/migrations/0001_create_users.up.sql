@@ -0,0 +1,6 @@
+CREATE TABLE IF NOT EXISTS users (
+	id VARCHAR(36) NOT NULL PRIMARY KEY,
+	name VARCHAR(255) NOT NULL,
+	email VARCHAR(255) NOT NULL,
+	password VARCHAR(255) NOT NULL
+);
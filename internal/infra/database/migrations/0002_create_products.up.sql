@@ -0,0 +1,8 @@
+CREATE TABLE IF NOT EXISTS products (
+	id VARCHAR(36) NOT NULL PRIMARY KEY,
+	name VARCHAR(255) NOT NULL,
+	price DOUBLE PRECISION NOT NULL,
+	created_at TIMESTAMP NULL
+);
+
+CREATE INDEX idx_products_created_at ON products (created_at);
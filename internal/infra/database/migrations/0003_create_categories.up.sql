@@ -0,0 +1,19 @@
+CREATE TABLE categories (
+	id VARCHAR(36) NOT NULL PRIMARY KEY,
+	name VARCHAR(255) NOT NULL,
+	parent_id VARCHAR(36) NULL,
+	created_at TIMESTAMP NULL,
+	FOREIGN KEY (parent_id) REFERENCES categories (id)
+);
+
+CREATE INDEX idx_categories_parent_id ON categories (parent_id);
+
+CREATE TABLE product_categories (
+	product_id VARCHAR(36) NOT NULL,
+	category_id VARCHAR(36) NOT NULL,
+	PRIMARY KEY (product_id, category_id),
+	FOREIGN KEY (product_id) REFERENCES products (id) ON DELETE CASCADE,
+	FOREIGN KEY (category_id) REFERENCES categories (id) ON DELETE CASCADE
+);
+
+CREATE INDEX idx_product_categories_category_id ON product_categories (category_id);
@@ -0,0 +1,19 @@
+CREATE TABLE stocks (
+	product_id VARCHAR(36) NOT NULL PRIMARY KEY,
+	quantity INTEGER NOT NULL DEFAULT 0,
+	updated_at TIMESTAMP NULL,
+	CHECK (quantity >= 0),
+	FOREIGN KEY (product_id) REFERENCES products (id) ON DELETE CASCADE
+);
+
+CREATE TABLE reservations (
+	id VARCHAR(36) NOT NULL PRIMARY KEY,
+	product_id VARCHAR(36) NOT NULL,
+	quantity INTEGER NOT NULL,
+	expires_at TIMESTAMP NOT NULL,
+	created_at TIMESTAMP NULL,
+	CHECK (quantity > 0),
+	FOREIGN KEY (product_id) REFERENCES products (id) ON DELETE CASCADE
+);
+
+CREATE INDEX idx_reservations_product_id_expires_at ON reservations (product_id, expires_at);
@@ -0,0 +1,7 @@
+ALTER TABLE products ADD COLUMN price DOUBLE PRECISION NOT NULL DEFAULT 0;
+
+UPDATE products SET price = price_amount / 100.0;
+
+ALTER TABLE products DROP COLUMN price_currency;
+
+ALTER TABLE products DROP COLUMN price_amount;
@@ -0,0 +1,8 @@
+-- Prices used to be floats without a currency, keep them as USD cents.
+ALTER TABLE products ADD COLUMN price_amount BIGINT NOT NULL DEFAULT 0;
+
+ALTER TABLE products ADD COLUMN price_currency VARCHAR(3) NOT NULL DEFAULT 'USD';
+
+UPDATE products SET price_amount = ROUND(price * 100);
+
+ALTER TABLE products DROP COLUMN price;
@@ -0,0 +1,3 @@
+DROP INDEX idx_products_price;
+
+DROP INDEX idx_products_name;
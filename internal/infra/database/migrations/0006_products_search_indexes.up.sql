@@ -0,0 +1,3 @@
+CREATE INDEX idx_products_name ON products (name);
+
+CREATE INDEX idx_products_price ON products (price_currency, price_amount);
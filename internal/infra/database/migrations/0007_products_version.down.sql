@@ -0,0 +1,3 @@
+ALTER TABLE products DROP COLUMN updated_at;
+
+ALTER TABLE products DROP COLUMN version;
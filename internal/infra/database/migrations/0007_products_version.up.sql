@@ -0,0 +1,5 @@
+ALTER TABLE products ADD COLUMN version BIGINT NOT NULL DEFAULT 1;
+
+ALTER TABLE products ADD COLUMN updated_at TIMESTAMP NULL;
+
+UPDATE products SET updated_at = created_at;
@@ -0,0 +1,3 @@
+ALTER TABLE products ADD COLUMN deleted_at TIMESTAMP NULL;
+
+CREATE INDEX idx_products_deleted_at ON products (deleted_at);
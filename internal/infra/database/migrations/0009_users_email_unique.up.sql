@@ -0,0 +1,4 @@
+-- Emails are compared lowercased and trimmed. Duplicate addresses left after normalizing make the index creation fail and have to be merged by hand first.
+UPDATE users SET email = LOWER(TRIM(email));
+
+CREATE UNIQUE INDEX idx_users_email ON users (email);
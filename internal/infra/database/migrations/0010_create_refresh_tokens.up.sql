@@ -0,0 +1,17 @@
+CREATE TABLE refresh_tokens (
+	id VARCHAR(36) NOT NULL PRIMARY KEY,
+	user_id VARCHAR(36) NOT NULL,
+	family_id VARCHAR(36) NOT NULL,
+	token_hash VARCHAR(64) NOT NULL,
+	expires_at TIMESTAMP NOT NULL,
+	created_at TIMESTAMP NULL,
+	revoked_at TIMESTAMP NULL,
+	replaced_by VARCHAR(36) NULL,
+	FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE CASCADE
+);
+
+CREATE UNIQUE INDEX idx_refresh_tokens_token_hash ON refresh_tokens (token_hash);
+
+CREATE INDEX idx_refresh_tokens_family_id ON refresh_tokens (family_id);
+
+CREATE INDEX idx_refresh_tokens_user_id ON refresh_tokens (user_id);
@@ -0,0 +1,2 @@
+-- Existing users become viewers, editors and admins have to be granted their role.
+ALTER TABLE users ADD COLUMN role VARCHAR(20) NOT NULL DEFAULT 'viewer';
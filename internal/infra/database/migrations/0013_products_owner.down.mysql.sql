@@ -0,0 +1,5 @@
+DROP INDEX idx_products_created_by ON products;
+
+ALTER TABLE products DROP COLUMN updated_by;
+
+ALTER TABLE products DROP COLUMN created_by;
@@ -0,0 +1,5 @@
+ALTER TABLE products ADD COLUMN created_by VARCHAR(36) NULL;
+
+ALTER TABLE products ADD COLUMN updated_by VARCHAR(36) NULL;
+
+CREATE INDEX idx_products_created_by ON products (created_by);
@@ -0,0 +1,13 @@
+CREATE TABLE password_resets (
+	id VARCHAR(36) NOT NULL PRIMARY KEY,
+	user_id VARCHAR(36) NOT NULL,
+	token_hash VARCHAR(64) NOT NULL,
+	expires_at TIMESTAMP NOT NULL,
+	created_at TIMESTAMP NULL,
+	used_at TIMESTAMP NULL,
+	FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE CASCADE
+);
+
+CREATE UNIQUE INDEX idx_password_resets_token_hash ON password_resets (token_hash);
+
+CREATE INDEX idx_password_resets_user_id ON password_resets (user_id);
@@ -0,0 +1,17 @@
+-- Existing users start unverified and can ask for a verification email.
+ALTER TABLE users ADD COLUMN email_verified_at TIMESTAMP NULL;
+
+CREATE TABLE email_verifications (
+	id VARCHAR(36) NOT NULL PRIMARY KEY,
+	user_id VARCHAR(36) NOT NULL,
+	email VARCHAR(255) NOT NULL,
+	token_hash VARCHAR(64) NOT NULL,
+	expires_at TIMESTAMP NOT NULL,
+	created_at TIMESTAMP NULL,
+	used_at TIMESTAMP NULL,
+	FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE CASCADE
+);
+
+CREATE UNIQUE INDEX idx_email_verifications_token_hash ON email_verifications (token_hash);
+
+CREATE INDEX idx_email_verifications_user_id ON email_verifications (user_id);
@@ -0,0 +1,19 @@
+-- Attempts are kept for auditing after their user is deleted.
+CREATE TABLE login_attempts (
+	id VARCHAR(36) NOT NULL PRIMARY KEY,
+	email VARCHAR(255) NOT NULL,
+	user_id VARCHAR(36) NULL,
+	ip VARCHAR(45) NOT NULL,
+	user_agent VARCHAR(255) NOT NULL DEFAULT '',
+	result VARCHAR(16) NOT NULL,
+	created_at TIMESTAMP NOT NULL,
+	FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE SET NULL
+);
+
+CREATE INDEX idx_login_attempts_email_created_at ON login_attempts (email, created_at);
+
+CREATE INDEX idx_login_attempts_ip_created_at ON login_attempts (ip, created_at);
+
+CREATE INDEX idx_login_attempts_user_id ON login_attempts (user_id);
+
+CREATE INDEX idx_login_attempts_created_at ON login_attempts (created_at);
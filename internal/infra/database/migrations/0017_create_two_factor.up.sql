@@ -0,0 +1,21 @@
+CREATE TABLE totp_secrets (
+	user_id VARCHAR(36) NOT NULL PRIMARY KEY,
+	secret VARCHAR(64) NOT NULL,
+	last_used_step BIGINT NOT NULL DEFAULT 0,
+	confirmed_at TIMESTAMP NULL,
+	created_at TIMESTAMP NULL,
+	FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE CASCADE
+);
+
+CREATE TABLE recovery_codes (
+	id VARCHAR(36) NOT NULL PRIMARY KEY,
+	user_id VARCHAR(36) NOT NULL,
+	code_hash VARCHAR(64) NOT NULL,
+	used_at TIMESTAMP NULL,
+	created_at TIMESTAMP NULL,
+	FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE CASCADE
+);
+
+CREATE UNIQUE INDEX idx_recovery_codes_code_hash ON recovery_codes (code_hash);
+
+CREATE INDEX idx_recovery_codes_user_id ON recovery_codes (user_id);
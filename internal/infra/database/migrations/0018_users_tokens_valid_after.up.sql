@@ -0,0 +1,2 @@
+-- Access tokens issued before this time are rejected, see User.TokensValidAfter.
+ALTER TABLE users ADD COLUMN tokens_valid_after TIMESTAMP NULL;
@@ -0,0 +1,265 @@
+// Package migrations applies the versioned schema migrations embedded in the
+// binary and records them in the schema_migrations table.
+//
+// Migrations are files named NNNN_description.up.sql and
+// NNNN_description.down.sql. A file suffixed with a driver name, such as
+// NNNN_description.up.postgres.sql, replaces the portable one on that driver.
+package migrations
+
+import (
+	"embed"
+	"errors"
+	"fmt"
+	"io/fs"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+//go:embed *.sql
+var files embed.FS
+
+var (
+	ErrNoDownMigration    = errors.New("migration has no down script")
+	ErrPendingMigrations  = errors.New("database has pending migrations")
+	ErrUnknownMigration   = errors.New("database has a migration unknown to this binary")
+	ErrInvalidStepsNumber = errors.New("steps must be greater than zero")
+)
+
+var fileName = regexp.MustCompile(`^(\d+)_(\w+)\.(up|down)(?:\.(\w+))?\.sql$`)
+
+type Migration struct {
+	Version int64
+	Name    string
+	up      string
+	down    string
+}
+
+type Status struct {
+	Version   int64
+	Name      string
+	AppliedAt *time.Time
+}
+
+type schemaMigration struct {
+	Version   int64 `gorm:"primaryKey;autoIncrement:false"`
+	Name      string
+	AppliedAt time.Time
+}
+
+func (schemaMigration) TableName() string {
+	return "schema_migrations"
+}
+
+type Migrator struct {
+	DB         *gorm.DB
+	Migrations []Migration
+}
+
+// New loads the embedded migrations for the given driver.
+func New(db *gorm.DB, driver string) (*Migrator, error) {
+	migrations, err := Load(files, driver)
+	if err != nil {
+		return nil, err
+	}
+	return &Migrator{
+		DB:         db,
+		Migrations: migrations,
+	}, nil
+}
+
+// Load reads the migrations in fsys ordered by version, picking the
+// driver-specific script over the portable one when both exist.
+func Load(fsys fs.FS, driver string) ([]Migration, error) {
+	entries, err := fs.ReadDir(fsys, ".")
+	if err != nil {
+		return nil, err
+	}
+	byVersion := map[int64]*Migration{}
+	specific := map[string]bool{}
+	for _, e := range entries {
+		m := fileName.FindStringSubmatch(e.Name())
+		if m == nil {
+			continue
+		}
+		version, name, direction, fileDriver := m[1], m[2], m[3], m[4]
+		if fileDriver != "" && fileDriver != driver {
+			continue
+		}
+		v, err := strconv.ParseInt(version, 10, 64)
+		if err != nil {
+			return nil, err
+		}
+		key := version + "." + direction
+		if fileDriver == "" && specific[key] {
+			continue
+		}
+		content, err := fs.ReadFile(fsys, e.Name())
+		if err != nil {
+			return nil, err
+		}
+		mig, ok := byVersion[v]
+		if !ok {
+			mig = &Migration{Version: v, Name: name}
+			byVersion[v] = mig
+		} else if mig.Name != name {
+			return nil, fmt.Errorf("migration %d has conflicting names %q and %q", v, mig.Name, name)
+		}
+		if direction == "up" {
+			mig.up = string(content)
+		} else {
+			mig.down = string(content)
+		}
+		if fileDriver != "" {
+			specific[key] = true
+		}
+	}
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, m := range byVersion {
+		if m.up == "" {
+			return nil, fmt.Errorf("migration %d_%s has no up script", m.Version, m.Name)
+		}
+		migrations = append(migrations, *m)
+	}
+	sort.Slice(migrations, func(i, j int) bool {
+		return migrations[i].Version < migrations[j].Version
+	})
+	return migrations, nil
+}
+
+// Up applies every pending migration in version order.
+func (m *Migrator) Up() error {
+	applied, err := m.applied()
+	if err != nil {
+		return err
+	}
+	for _, mig := range m.Migrations {
+		if _, ok := applied[mig.Version]; ok {
+			continue
+		}
+		err := m.DB.Transaction(func(tx *gorm.DB) error {
+			if err := exec(tx, mig.up); err != nil {
+				return err
+			}
+			return tx.Create(&schemaMigration{
+				Version:   mig.Version,
+				Name:      mig.Name,
+				AppliedAt: time.Now(),
+			}).Error
+		})
+		if err != nil {
+			return fmt.Errorf("applying migration %d_%s: %w", mig.Version, mig.Name, err)
+		}
+	}
+	return nil
+}
+
+// Down rolls back the last steps applied migrations.
+func (m *Migrator) Down(steps int) error {
+	if steps <= 0 {
+		return ErrInvalidStepsNumber
+	}
+	applied, err := m.applied()
+	if err != nil {
+		return err
+	}
+	known := map[int64]Migration{}
+	for _, mig := range m.Migrations {
+		known[mig.Version] = mig
+	}
+	versions := make([]int64, 0, len(applied))
+	for v := range applied {
+		versions = append(versions, v)
+	}
+	sort.Slice(versions, func(i, j int) bool { return versions[i] > versions[j] })
+	for i := 0; i < steps && i < len(versions); i++ {
+		mig, ok := known[versions[i]]
+		if !ok {
+			return fmt.Errorf("%w: %d", ErrUnknownMigration, versions[i])
+		}
+		if mig.down == "" {
+			return fmt.Errorf("%w: %d_%s", ErrNoDownMigration, mig.Version, mig.Name)
+		}
+		err := m.DB.Transaction(func(tx *gorm.DB) error {
+			if err := exec(tx, mig.down); err != nil {
+				return err
+			}
+			return tx.Delete(&schemaMigration{}, "version = ?", mig.Version).Error
+		})
+		if err != nil {
+			return fmt.Errorf("rolling back migration %d_%s: %w", mig.Version, mig.Name, err)
+		}
+	}
+	return nil
+}
+
+// Status lists every known migration and when it was applied, if ever.
+func (m *Migrator) Status() ([]Status, error) {
+	applied, err := m.applied()
+	if err != nil {
+		return nil, err
+	}
+	status := make([]Status, 0, len(m.Migrations))
+	for _, mig := range m.Migrations {
+		s := Status{Version: mig.Version, Name: mig.Name}
+		if a, ok := applied[mig.Version]; ok {
+			appliedAt := a.AppliedAt
+			s.AppliedAt = &appliedAt
+		}
+		status = append(status, s)
+	}
+	return status, nil
+}
+
+// Check returns ErrPendingMigrations when the schema is behind the binary.
+func (m *Migrator) Check() error {
+	status, err := m.Status()
+	if err != nil {
+		return err
+	}
+	for _, s := range status {
+		if s.AppliedAt == nil {
+			return fmt.Errorf("%w: %d_%s", ErrPendingMigrations, s.Version, s.Name)
+		}
+	}
+	return nil
+}
+
+func (m *Migrator) applied() (map[int64]schemaMigration, error) {
+	err := m.DB.Exec(`CREATE TABLE IF NOT EXISTS schema_migrations (
+	version BIGINT NOT NULL PRIMARY KEY,
+	name VARCHAR(255) NOT NULL,
+	applied_at TIMESTAMP NOT NULL
+)`).Error
+	if err != nil {
+		return nil, err
+	}
+	var rows []schemaMigration
+	if err := m.DB.Find(&rows).Error; err != nil {
+		return nil, err
+	}
+	applied := make(map[int64]schemaMigration, len(rows))
+	for _, r := range rows {
+		applied[r.Version] = r
+	}
+	return applied, nil
+}
+
+// exec runs each statement of a script separately, since not every driver
+// accepts several statements in a single call.
+func exec(tx *gorm.DB, script string) error {
+	for _, stmt := range strings.Split(script, ";") {
+		stmt = strings.TrimSpace(stmt)
+		if stmt == "" {
+			continue
+		}
+		if err := tx.Exec(stmt).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
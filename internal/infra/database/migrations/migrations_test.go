@@ -0,0 +1,225 @@
+package migrations
+
+import (
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/brenoproti/go-api/internal/entity"
+	"github.com/brenoproti/go-api/internal/infra/database"
+	pkg "github.com/brenoproti/go-api/pkg/entity"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+func newMigrator(t *testing.T) *Migrator {
+	db, err := gorm.Open(sqlite.Open("file::memory:"), &gorm.Config{TranslateError: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	sqlDB, err := db.DB()
+	if err != nil {
+		t.Fatal(err)
+	}
+	sqlDB.SetMaxOpenConns(1)
+	m, err := New(db, "sqlite")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return m
+}
+
+func TestLoadPrefersDriverSpecificScript(t *testing.T) {
+	fsys := fstest.MapFS{
+		"0001_init.up.sql":          {Data: []byte("portable")},
+		"0001_init.up.postgres.sql": {Data: []byte("postgres")},
+		"0001_init.down.sql":        {Data: []byte("down")},
+		"0002_next.up.sql":          {Data: []byte("next")},
+		"README.md":                 {Data: []byte("ignored")},
+	}
+	migrations, err := Load(fsys, "postgres")
+	assert.NoError(t, err)
+	assert.Len(t, migrations, 2)
+	assert.Equal(t, int64(1), migrations[0].Version)
+	assert.Equal(t, "init", migrations[0].Name)
+	assert.Equal(t, "postgres", migrations[0].up)
+	assert.Equal(t, "down", migrations[0].down)
+	assert.Equal(t, int64(2), migrations[1].Version)
+
+	migrations, err = Load(fsys, "sqlite")
+	assert.NoError(t, err)
+	assert.Equal(t, "portable", migrations[0].up)
+}
+
+func TestLoadWhenUpScriptIsMissing(t *testing.T) {
+	_, err := Load(fstest.MapFS{"0001_init.down.sql": {Data: []byte("down")}}, "sqlite")
+	assert.Error(t, err)
+}
+
+func TestUpAndDown(t *testing.T) {
+	m := newMigrator(t)
+	assert.ErrorIs(t, m.Check(), ErrPendingMigrations)
+
+	assert.NoError(t, m.Up())
+	assert.NoError(t, m.Check())
+	assert.True(t, m.DB.Migrator().HasTable("users"))
+	assert.True(t, m.DB.Migrator().HasTable("products"))
+	status, err := m.Status()
+	assert.NoError(t, err)
+	assert.Len(t, status, len(m.Migrations))
+	for _, s := range status {
+		assert.NotNil(t, s.AppliedAt)
+	}
+
+	// running again is a no-op
+	assert.NoError(t, m.Up())
+
+	assert.NoError(t, m.Down(len(m.Migrations)))
+	assert.False(t, m.DB.Migrator().HasTable("users"))
+	assert.False(t, m.DB.Migrator().HasTable("products"))
+	status, err = m.Status()
+	assert.NoError(t, err)
+	for _, s := range status {
+		assert.Nil(t, s.AppliedAt)
+	}
+}
+
+func TestSchemaMatchesEntities(t *testing.T) {
+	m := newMigrator(t)
+	assert.NoError(t, m.Up())
+
+	user, err := entity.NewUser("John", "j@j.com", "Str0ng-Passw0rd")
+	assert.NoError(t, err)
+	userDb := database.NewUser(m.DB)
+	assert.NoError(t, userDb.Create(user))
+	_, err = userDb.FindByEmail("j@j.com")
+	assert.NoError(t, err)
+	user.Name = "Johnny"
+	assert.NoError(t, userDb.Update(user))
+	users, err := userDb.FindAll(1, 10)
+	assert.NoError(t, err)
+	assert.Len(t, users, 1)
+	assert.Equal(t, entity.RoleViewer, users[0].Role)
+	_, err = userDb.SetRole(user.ID.String(), entity.RoleAdmin)
+	assert.NoError(t, err)
+	assert.NoError(t, user.ChangePassword("N3w-Passw0rd"))
+	assert.NoError(t, userDb.UpdatePassword(user))
+	duplicate, err := entity.NewUser("Johnny", "J@J.com", "Str0ng-Passw0rd")
+	assert.NoError(t, err)
+	assert.ErrorIs(t, m.DB.Create(duplicate).Error, gorm.ErrDuplicatedKey)
+
+	refreshToken, plain, err := entity.NewRefreshToken(user.ID, nil, time.Hour)
+	assert.NoError(t, err)
+	refreshTokenDb := database.NewRefreshTokenDB(m.DB)
+	assert.NoError(t, refreshTokenDb.Create(refreshToken))
+	_, _, err = refreshTokenDb.Rotate(plain, time.Hour)
+	assert.NoError(t, err)
+	_, _, err = refreshTokenDb.Rotate(plain, time.Hour)
+	assert.ErrorIs(t, err, entity.ErrRefreshTokenReused)
+
+	verification, verificationToken, err := entity.NewEmailVerification(user, time.Hour)
+	assert.NoError(t, err)
+	emailVerificationDb := database.NewEmailVerificationDB(m.DB)
+	assert.NoError(t, emailVerificationDb.Create(verification))
+	verified, err := emailVerificationDb.Verify(verificationToken)
+	assert.NoError(t, err)
+	assert.True(t, verified.IsEmailVerified())
+
+	reset, resetToken, err := entity.NewPasswordReset(user.ID, time.Hour)
+	assert.NoError(t, err)
+	passwordResetDb := database.NewPasswordResetDB(m.DB)
+	assert.NoError(t, passwordResetDb.Create(reset))
+	_, err = passwordResetDb.Reset(resetToken, "N3w-Passw0rd")
+	assert.NoError(t, err)
+
+	attempt, err := entity.NewLoginAttempt(user.Email, "10.0.0.1", "test", &user.ID, entity.LoginFailed)
+	assert.NoError(t, err)
+	loginAttemptDb := database.NewLoginAttemptDB(m.DB)
+	assert.NoError(t, loginAttemptDb.Create(attempt))
+	failures, err := loginAttemptDb.EmailFailures(user.Email, time.Now().Add(-time.Minute))
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), failures.Count)
+	attempts, err := loginAttemptDb.FindAll(1, 10, database.LoginAttemptFilter{IP: "10.0.0.1"})
+	assert.NoError(t, err)
+	assert.Len(t, attempts, 1)
+
+	totpSecret, err := entity.NewTOTPSecret(user.ID)
+	assert.NoError(t, err)
+	totpDb := database.NewTOTPDB(m.DB)
+	assert.NoError(t, totpDb.Enroll(totpSecret))
+	recoveryCodes, plainCodes, err := entity.NewRecoveryCodes(user.ID)
+	assert.NoError(t, err)
+	assert.NoError(t, totpDb.Confirm(user.ID.String(), 10, recoveryCodes))
+	assert.NoError(t, totpDb.UseCode(user.ID.String(), 11))
+	assert.NoError(t, totpDb.UseRecoveryCode(user.ID.String(), plainCodes[0]))
+
+	revokedToken, err := entity.NewRevokedToken(pkg.NewID(), user.ID, time.Now().Add(time.Minute))
+	assert.NoError(t, err)
+	assert.NoError(t, database.NewRevokedTokenDB(m.DB).Revoke(revokedToken))
+	revoked, err := database.NewRevokedTokenDB(m.DB).IsRevoked(revokedToken.ID.String())
+	assert.NoError(t, err)
+	assert.True(t, revoked)
+
+	product, err := entity.NewProduct("Product 1", pkg.NewMoney(1050, "USD"))
+	assert.NoError(t, err)
+	product.CreatedBy, product.UpdatedBy = &user.ID, &user.ID
+	productDb := database.NewProductDB(m.DB)
+	assert.NoError(t, productDb.Create(product))
+	products, err := productDb.FindAll(1, 10, "asc", database.ProductFilter{CreatedBy: user.ID.String()})
+	assert.NoError(t, err)
+	assert.Len(t, products, 1)
+
+	category, err := entity.NewCategory("Category 1", nil)
+	assert.NoError(t, err)
+	assert.NoError(t, database.NewCategoryDB(m.DB).Create(category))
+	assert.NoError(t, productDb.SetCategories(product.ID.String(), []string{category.ID.String()}))
+	products, err = productDb.FindAll(1, 10, "asc", database.ProductFilter{CategoryID: category.ID.String(), IncludeDescendants: true})
+	assert.NoError(t, err)
+	assert.Len(t, products, 1)
+
+	inventoryDb := database.NewInventoryDB(m.DB)
+	_, err = inventoryDb.Increment(product.ID.String(), 2)
+	assert.NoError(t, err)
+	reservation, err := inventoryDb.Reserve(product.ID.String(), 1, time.Minute)
+	assert.NoError(t, err)
+	stock, err := inventoryDb.Commit(reservation.ID.String())
+	assert.NoError(t, err)
+	assert.Equal(t, 1, stock.Quantity)
+
+	product.Name = "Product 2"
+	assert.NoError(t, productDb.Update(product))
+	assert.Equal(t, int64(2), product.Version)
+	assert.NoError(t, productDb.Delete(product.ID.String(), product.Version))
+	_, err = productDb.Restore(product.ID.String())
+	assert.NoError(t, err)
+	assert.NoError(t, productDb.Delete(product.ID.String(), 0))
+	purged, err := productDb.Purge(time.Now().Add(time.Second))
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), purged)
+}
+
+func TestMoneyMigrationConvertsFloatPrices(t *testing.T) {
+	m := newMigrator(t)
+	all := m.Migrations
+	for i, mig := range all {
+		if mig.Name == "products_price_money" {
+			m.Migrations = all[:i]
+		}
+	}
+	assert.NoError(t, m.Up())
+	id := pkg.NewID().String()
+	err := m.DB.Exec("INSERT INTO products (id, name, price) VALUES (?, ?, ?)", id, "Product 1", 19.99).Error
+	assert.NoError(t, err)
+
+	m.Migrations = all
+	assert.NoError(t, m.Up())
+	var product entity.Product
+	assert.NoError(t, m.DB.First(&product, "id = ?", id).Error)
+	assert.Equal(t, pkg.NewMoney(1999, "USD"), product.Price)
+}
+
+func TestDownWhenStepsIsInvalid(t *testing.T) {
+	m := newMigrator(t)
+	assert.ErrorIs(t, m.Down(0), ErrInvalidStepsNumber)
+}
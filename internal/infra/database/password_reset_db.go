@@ -0,0 +1,81 @@
+package database
+
+import (
+	"errors"
+	"time"
+
+	"github.com/brenoproti/go-api/internal/entity"
+	"gorm.io/gorm"
+)
+
+type PasswordResetDB struct {
+	DB *gorm.DB
+}
+
+func NewPasswordResetDB(db *gorm.DB) *PasswordResetDB {
+	return &PasswordResetDB{
+		DB: db,
+	}
+}
+
+func (p *PasswordResetDB) Create(reset *entity.PasswordReset) error {
+	return p.DB.Create(reset).Error
+}
+
+// Reset sets the password of the user the token was issued to and uses up
+// every pending reset token of theirs. The user's refresh and access tokens
+// are revoked as well, since whoever knew the old password may hold some.
+func (p *PasswordResetDB) Reset(token, password string) (*entity.User, error) {
+	var user entity.User
+	err := p.DB.Transaction(func(tx *gorm.DB) error {
+		var reset entity.PasswordReset
+		err := tx.First(&reset, "token_hash = ?", entity.HashToken(token)).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return entity.ErrInvalidResetToken
+		}
+		if err != nil {
+			return err
+		}
+		now := time.Now()
+		if !reset.IsUsable(now) {
+			return entity.ErrInvalidResetToken
+		}
+		// only one of concurrent resets with the same token can use it
+		result := tx.Model(&entity.PasswordReset{}).
+			Where("user_id = ? AND used_at IS NULL", reset.UserID).
+			Update("used_at", now)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return entity.ErrInvalidResetToken
+		}
+		if err := tx.First(&user, "id = ?", reset.UserID).Error; err != nil {
+			return err
+		}
+		if err := user.ChangePassword(password); err != nil {
+			return err
+		}
+		err = tx.Model(&user).Updates(map[string]interface{}{
+			"password":           user.Password,
+			"tokens_valid_after": user.TokensValidAfter,
+		}).Error
+		if err != nil {
+			return err
+		}
+		return tx.Model(&entity.RefreshToken{}).
+			Where("user_id = ? AND revoked_at IS NULL", user.ID).
+			Update("revoked_at", now).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+// DeleteExpired removes the reset tokens that expired before the given time,
+// used or not.
+func (p *PasswordResetDB) DeleteExpired(before time.Time) (int64, error) {
+	result := p.DB.Where("expires_at < ?", before).Delete(&entity.PasswordReset{})
+	return result.RowsAffected, result.Error
+}
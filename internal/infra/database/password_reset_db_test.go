@@ -0,0 +1,69 @@
+package database
+
+import (
+	"testing"
+	"time"
+
+	"github.com/brenoproti/go-api/internal/entity"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+func newPasswordResetTestDB(t *testing.T) (*gorm.DB, *entity.User) {
+	db, err := gorm.Open(sqlite.Open("file::memory:"), &gorm.Config{})
+	if err != nil {
+		t.Error(err)
+	}
+	db.AutoMigrate(&entity.User{}, &entity.RefreshToken{}, &entity.PasswordReset{})
+	user, _ := entity.NewUser("John", "j@j.com", "Str0ng-Passw0rd")
+	assert.NoError(t, NewUser(db).Create(user))
+	return db, user
+}
+
+func TestResetPassword(t *testing.T) {
+	db, user := newPasswordResetTestDB(t)
+	resetDb := NewPasswordResetDB(db)
+	refreshToken, _, _ := entity.NewRefreshToken(user.ID, nil, time.Hour)
+	assert.NoError(t, NewRefreshTokenDB(db).Create(refreshToken))
+	first, firstToken, _ := entity.NewPasswordReset(user.ID, time.Hour)
+	assert.NoError(t, resetDb.Create(first))
+	second, token, _ := entity.NewPasswordReset(user.ID, time.Hour)
+	assert.NoError(t, resetDb.Create(second))
+
+	reset, err := resetDb.Reset(token, "N3w-Passw0rd")
+	assert.NoError(t, err)
+	assert.Equal(t, user.ID, reset.ID)
+	found, err := NewUser(db).FindById(user.ID.String())
+	assert.NoError(t, err)
+	assert.True(t, found.ValidatePassword("N3w-Passw0rd"))
+	assert.False(t, found.ValidatePassword("Str0ng-Passw0rd"))
+	assert.False(t, found.AcceptsTokenIssuedAt(time.Now().Add(-time.Minute)))
+
+	var revoked entity.RefreshToken
+	assert.NoError(t, db.First(&revoked, "id = ?", refreshToken.ID).Error)
+	assert.True(t, revoked.IsRevoked())
+
+	// tokens are single use, and resetting uses up the other ones too
+	_, err = resetDb.Reset(token, "An0ther-Passw0rd")
+	assert.ErrorIs(t, err, entity.ErrInvalidResetToken)
+	_, err = resetDb.Reset(firstToken, "An0ther-Passw0rd")
+	assert.ErrorIs(t, err, entity.ErrInvalidResetToken)
+	_, err = resetDb.Reset("unknown", "An0ther-Passw0rd")
+	assert.ErrorIs(t, err, entity.ErrInvalidResetToken)
+}
+
+func TestExpiredPasswordResets(t *testing.T) {
+	db, user := newPasswordResetTestDB(t)
+	resetDb := NewPasswordResetDB(db)
+	expired, token, _ := entity.NewPasswordReset(user.ID, time.Hour)
+	expired.CreatedAt = time.Now().Add(-2 * time.Hour)
+	expired.ExpiresAt = time.Now().Add(-time.Hour)
+	assert.NoError(t, resetDb.Create(expired))
+
+	_, err := resetDb.Reset(token, "N3w-Passw0rd")
+	assert.ErrorIs(t, err, entity.ErrInvalidResetToken)
+	deleted, err := resetDb.DeleteExpired(time.Now())
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), deleted)
+}
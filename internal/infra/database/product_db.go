@@ -1,12 +1,16 @@
 package database
 
 import (
-	"fmt"
+	"errors"
+	"time"
 
 	"github.com/brenoproti/go-api/internal/entity"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
+var ErrVersionConflict = errors.New("product was modified concurrently")
+
 type ProductDB struct {
 	DB *gorm.DB
 }
@@ -30,37 +34,256 @@ func (p *ProductDB) FindById(id string) (*entity.Product, error) {
 	return &product, nil
 }
 
-func (p *ProductDB) FindAll(page, limit int, sort string) ([]entity.Product, error) {
+func (p *ProductDB) FindAll(page, limit int, sort string, filter ProductFilter) ([]entity.Product, error) {
+	order, err := ParseProductSort(sort)
+	if err != nil {
+		return nil, err
+	}
+	query, err := filter.apply(p.DB)
+	if err != nil {
+		return nil, err
+	}
+	page, limit = NormalizePage(page, limit)
+	query = query.Clauses(clause.OrderBy{Columns: order}).Offset((page - 1) * limit).Limit(limit)
+	var products []entity.Product
+	if err := query.Find(&products).Error; err != nil {
+		return nil, err
+	}
+	return products, nil
+}
+
+// FindAfter returns up to limit products following the cursor in
+// (created_at, id) order, starting from the beginning when cursor is nil,
+// along with the cursor of the next page or nil on the last one. Unlike
+// offsets, the position stays valid when products are inserted or removed
+// while paging.
+func (p *ProductDB) FindAfter(cursor *ProductCursor, limit int, filter ProductFilter) ([]entity.Product, *ProductCursor, error) {
+	query, err := filter.apply(p.DB)
+	if err != nil {
+		return nil, nil, err
+	}
+	if cursor != nil {
+		query = query.Where("created_at > ? OR (created_at = ? AND id > ?)", cursor.CreatedAt, cursor.CreatedAt, cursor.ID)
+	}
+	_, limit = NormalizePage(1, limit)
 	var products []entity.Product
-	var err error
-	if sort != "" && sort != "asc" && sort != "desc" {
-		sort = "asc"
+	// fetching one extra product tells whether there is a next page
+	err = query.Order("created_at asc").Order("id asc").Limit(limit + 1).Find(&products).Error
+	if err != nil {
+		return nil, nil, err
 	}
-	if page != 0 && limit != 0 {
-		offset := (page - 1) * limit
-		err = p.DB.Offset(offset).Limit(limit).Order(fmt.Sprintf("created_at %s", sort)).Find(&products).Error
-	} else {
-		err = p.DB.Order(fmt.Sprintf("created_at %s", sort)).Find(&products).Error
+	if len(products) <= limit {
+		return products, nil, nil
 	}
+	products = products[:limit]
+	last := products[limit-1]
+	return products, &ProductCursor{CreatedAt: last.CreatedAt, ID: last.ID.String()}, nil
+}
+
+func (p *ProductDB) Count(filter ProductFilter) (int64, error) {
+	query, err := filter.apply(p.DB)
 	if err != nil {
+		return 0, err
+	}
+	var total int64
+	if err := query.Model(&entity.Product{}).Count(&total).Error; err != nil {
+		return 0, err
+	}
+	return total, nil
+}
+
+func (p *ProductDB) FindCategories(id string) ([]entity.Category, error) {
+	if _, err := p.FindById(id); err != nil {
 		return nil, err
 	}
-	return products, nil
+	var categories []entity.Category
+	err := p.DB.Where("id IN (?)",
+		p.DB.Model(&productCategory{}).Select("category_id").Where("product_id = ?", id),
+	).Order("name asc").Find(&categories).Error
+	if err != nil {
+		return nil, err
+	}
+	return categories, nil
 }
 
+// SetCategories replaces the categories the product belongs to.
+func (p *ProductDB) SetCategories(id string, categoryIDs []string) error {
+	if _, err := p.FindById(id); err != nil {
+		return err
+	}
+	unique := map[string]bool{}
+	links := []productCategory{}
+	for _, c := range categoryIDs {
+		if unique[c] {
+			continue
+		}
+		unique[c] = true
+		links = append(links, productCategory{ProductID: id, CategoryID: c})
+	}
+	if len(links) > 0 {
+		var found int64
+		err := p.DB.Model(&entity.Category{}).Where("id IN ?", categoryIDs).Count(&found).Error
+		if err != nil {
+			return err
+		}
+		if found != int64(len(links)) {
+			return ErrUnknownCategory
+		}
+	}
+	return p.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("product_id = ?", id).Delete(&productCategory{}).Error; err != nil {
+			return err
+		}
+		if len(links) == 0 {
+			return nil
+		}
+		return tx.Create(&links).Error
+	})
+}
+
+// Update saves the product if it is still at product.Version, bumping the
+// version. A zero version skips the check. The owner cannot be changed, the
+// user making the change is expected in product.UpdatedBy.
 func (p *ProductDB) Update(product *entity.Product) error {
 	p2, err := p.FindById(product.ID.String())
 	if err != nil {
 		return err
 	}
 	product.CreatedAt = p2.CreatedAt
-	return p.DB.Save(product).Error
+	product.CreatedBy = p2.CreatedBy
+	if product.Version == 0 {
+		product.Version = p2.Version
+	}
+	if err := product.Validate(); err != nil {
+		return err
+	}
+	now := time.Now()
+	result := p.DB.Model(&entity.Product{}).
+		Where("id = ? AND version = ?", product.ID, product.Version).
+		Updates(map[string]interface{}{
+			"name":           product.Name,
+			"price_amount":   product.Price.Amount,
+			"price_currency": product.Price.Currency,
+			"version":        product.Version + 1,
+			"updated_at":     now,
+			"updated_by":     product.UpdatedBy,
+		})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrVersionConflict
+	}
+	product.Version++
+	product.UpdatedAt = now
+	return nil
 }
 
-func (p *ProductDB) Delete(id string) error {
+// Delete moves the product to the trash if it is still at the given version.
+// A zero version skips the check. The product keeps its categories and stock
+// so that it can be restored, until Purge removes it for good.
+func (p *ProductDB) Delete(id string, version int64) error {
 	product, err := p.FindById(id)
 	if err != nil {
 		return err
 	}
-	return p.DB.Delete(product).Error
+	if version != 0 && product.Version != version {
+		return ErrVersionConflict
+	}
+	return p.DB.Transaction(func(tx *gorm.DB) error {
+		// reservations would otherwise keep holding stock of a product that
+		// can no longer be sold
+		if err := tx.Where("product_id = ?", id).Delete(&entity.Reservation{}).Error; err != nil {
+			return err
+		}
+		result := tx.Where("version = ?", product.Version).Delete(product)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrVersionConflict
+		}
+		return nil
+	})
+}
+
+// FindDeleted lists the products in the trash, most recently deleted first.
+func (p *ProductDB) FindDeleted(page, limit int) ([]entity.Product, error) {
+	page, limit = NormalizePage(page, limit)
+	var products []entity.Product
+	err := p.DB.Unscoped().Where("deleted_at IS NOT NULL").
+		Order("deleted_at desc").Order("id asc").
+		Offset((page - 1) * limit).Limit(limit).
+		Find(&products).Error
+	if err != nil {
+		return nil, err
+	}
+	return products, nil
+}
+
+func (p *ProductDB) CountDeleted() (int64, error) {
+	var total int64
+	err := p.DB.Unscoped().Model(&entity.Product{}).Where("deleted_at IS NOT NULL").Count(&total).Error
+	if err != nil {
+		return 0, err
+	}
+	return total, nil
+}
+
+// Restore takes a product out of the trash, bumping its version.
+func (p *ProductDB) Restore(id string) (*entity.Product, error) {
+	var product entity.Product
+	err := p.DB.Unscoped().Where("deleted_at IS NOT NULL").First(&product, "id = ?", id).Error
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	result := p.DB.Unscoped().Model(&entity.Product{}).
+		Where("id = ? AND version = ? AND deleted_at IS NOT NULL", product.ID, product.Version).
+		Updates(map[string]interface{}{
+			"deleted_at": nil,
+			"version":    product.Version + 1,
+			"updated_at": now,
+		})
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return nil, ErrVersionConflict
+	}
+	product.DeletedAt = gorm.DeletedAt{}
+	product.Version++
+	product.UpdatedAt = now
+	return &product, nil
+}
+
+// Purge permanently removes the products deleted before the given time along
+// with their categories and stock, returning how many were removed.
+func (p *ProductDB) Purge(before time.Time) (int64, error) {
+	var purged int64
+	err := p.DB.Transaction(func(tx *gorm.DB) error {
+		var ids []string
+		err := tx.Unscoped().Model(&entity.Product{}).
+			Where("deleted_at IS NOT NULL AND deleted_at < ?", before).
+			Pluck("id", &ids).Error
+		if err != nil || len(ids) == 0 {
+			return err
+		}
+		if err := tx.Where("product_id IN ?", ids).Delete(&productCategory{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("product_id IN ?", ids).Delete(&entity.Reservation{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("product_id IN ?", ids).Delete(&entity.Stock{}).Error; err != nil {
+			return err
+		}
+		result := tx.Unscoped().Where("id IN ?", ids).Delete(&entity.Product{})
+		purged = result.RowsAffected
+		return result.Error
+	})
+	if err != nil {
+		return 0, err
+	}
+	return purged, nil
 }
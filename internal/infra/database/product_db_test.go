@@ -4,8 +4,10 @@ import (
 	"fmt"
 	"math/rand"
 	"testing"
+	"time"
 
 	"github.com/brenoproti/go-api/internal/entity"
+	pkg "github.com/brenoproti/go-api/pkg/entity"
 	"github.com/stretchr/testify/assert"
 	"gorm.io/driver/sqlite"
 	"gorm.io/gorm"
@@ -17,7 +19,7 @@ func TestCreateNewProduct(t *testing.T) {
 		t.Error(err)
 	}
 	db.AutoMigrate(&entity.Product{})
-	product, err := entity.NewProduct("Product 1", 10.5)
+	product, err := entity.NewProduct("Product 1", pkg.NewMoney(1050, "USD"))
 	assert.NoError(t, err)
 	productDb := NewProductDB(db)
 	err = productDb.Create(product)
@@ -35,30 +37,30 @@ func TestFindAllProducts(t *testing.T) {
 	db.AutoMigrate(&entity.Product{})
 	productDb := NewProductDB(db)
 	for i := 1; i < 24; i++ {
-		product, err := entity.NewProduct(fmt.Sprintf("Product %d", i), rand.Float64()*100)
+		product, err := entity.NewProduct(fmt.Sprintf("Product %d", i), pkg.NewMoney(rand.Int63n(10000)+1, "USD"))
 		assert.NoError(t, err)
 		err = productDb.Create(product)
 		assert.NoError(t, err)
 	}
-	products, err := productDb.FindAll(1, 10, "asc")
+	products, err := productDb.FindAll(1, 10, "asc", ProductFilter{})
 	assert.NoError(t, err)
 	assert.Len(t, products, 10)
 	assert.Equal(t, "Product 1", products[0].Name)
 	assert.Equal(t, "Product 10", products[9].Name)
 
-	products, err = productDb.FindAll(2, 10, "asc")
+	products, err = productDb.FindAll(2, 10, "asc", ProductFilter{})
 	assert.NoError(t, err)
 	assert.Len(t, products, 10)
 	assert.Equal(t, "Product 11", products[0].Name)
 	assert.Equal(t, "Product 20", products[9].Name)
 
-	products, err = productDb.FindAll(3, 10, "asc")
+	products, err = productDb.FindAll(3, 10, "asc", ProductFilter{})
 	assert.NoError(t, err)
 	assert.Len(t, products, 3)
 	assert.Equal(t, "Product 21", products[0].Name)
 	assert.Equal(t, "Product 23", products[2].Name)
 
-	products, err = productDb.FindAll(1, 24, "desc")
+	products, err = productDb.FindAll(1, 24, "desc", ProductFilter{})
 	assert.NoError(t, err)
 	assert.Len(t, products, 23)
 	assert.Equal(t, "Product 23", products[0].Name)
@@ -72,7 +74,7 @@ func TestFindById(t *testing.T) {
 	}
 	db.AutoMigrate(&entity.Product{})
 	productDb := NewProductDB(db)
-	product, err := entity.NewProduct("Product 1", 10.5)
+	product, err := entity.NewProduct("Product 1", pkg.NewMoney(1050, "USD"))
 	assert.NoError(t, err)
 	err = productDb.Create(product)
 	assert.NoError(t, err)
@@ -91,7 +93,7 @@ func TestUpdateProduct(t *testing.T) {
 	}
 	db.AutoMigrate(&entity.Product{})
 	productDb := NewProductDB(db)
-	product, err := entity.NewProduct("Product 1", 10.5)
+	product, err := entity.NewProduct("Product 1", pkg.NewMoney(1050, "USD"))
 	assert.NoError(t, err)
 	err = productDb.Create(product)
 	assert.NoError(t, err)
@@ -112,18 +114,312 @@ func TestDeleteProduct(t *testing.T) {
 	if err != nil {
 		t.Error(err)
 	}
-	db.AutoMigrate(&entity.Product{})
+	db.AutoMigrate(&entity.Product{}, &productCategory{}, &entity.Stock{}, &entity.Reservation{})
 	productDb := NewProductDB(db)
-	product, err := entity.NewProduct("Product 1", 10.5)
+	product, err := entity.NewProduct("Product 1", pkg.NewMoney(1050, "USD"))
 	assert.NoError(t, err)
 	err = productDb.Create(product)
 	assert.NoError(t, err)
 	product, err = productDb.FindById(product.ID.String())
 	assert.NoError(t, err)
 	assert.NotEmpty(t, product.ID)
-	err = productDb.Delete(product.ID.String())
+	err = productDb.Delete(product.ID.String(), 0)
 	assert.NoError(t, err)
 	product, err = productDb.FindById(product.ID.String())
 	assert.Error(t, err)
 	assert.Empty(t, product)
 }
+
+func TestFindAllProductsWithFilter(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open("file::memory:"), &gorm.Config{})
+	if err != nil {
+		t.Error(err)
+	}
+	db.AutoMigrate(&entity.Product{})
+	productDb := NewProductDB(db)
+	start := time.Now()
+	for i, p := range []struct {
+		name  string
+		price pkg.Money
+	}{
+		{"Blue Shirt", pkg.NewMoney(2000, "USD")},
+		{"Red Shirt", pkg.NewMoney(3000, "USD")},
+		{"Red Shoes", pkg.NewMoney(9000, "USD")},
+		{"100% Cotton", pkg.NewMoney(3000, "EUR")},
+	} {
+		product, err := entity.NewProduct(p.name, p.price)
+		assert.NoError(t, err)
+		product.CreatedAt = start.Add(time.Duration(i) * time.Hour)
+		assert.NoError(t, productDb.Create(product))
+	}
+
+	products, err := productDb.FindAll(0, 0, "name", ProductFilter{Name: "shirt"})
+	assert.NoError(t, err)
+	assert.Len(t, products, 2)
+	assert.Equal(t, "Blue Shirt", products[0].Name)
+
+	products, err = productDb.FindAll(0, 0, "", ProductFilter{Name: "%"})
+	assert.NoError(t, err)
+	assert.Len(t, products, 1)
+	assert.Equal(t, "100% Cotton", products[0].Name)
+
+	gte, lte := pkg.NewMoney(2500, "USD"), pkg.NewMoney(9000, "USD")
+	products, err = productDb.FindAll(0, 0, "-price", ProductFilter{PriceGte: &gte, PriceLte: &lte})
+	assert.NoError(t, err)
+	assert.Len(t, products, 2)
+	assert.Equal(t, "Red Shoes", products[0].Name)
+	assert.Equal(t, "Red Shirt", products[1].Name)
+
+	from, to := start.Add(time.Hour), start.Add(3*time.Hour)
+	products, err = productDb.FindAll(0, 0, "-created_at", ProductFilter{CreatedGte: &from, CreatedLt: &to})
+	assert.NoError(t, err)
+	assert.Len(t, products, 2)
+	assert.Equal(t, "Red Shoes", products[0].Name)
+
+	products, err = productDb.FindAll(0, 0, "-price,name", ProductFilter{Currency: "USD"})
+	assert.NoError(t, err)
+	assert.Len(t, products, 3)
+	assert.Equal(t, "Red Shoes", products[0].Name)
+
+	_, err = productDb.FindAll(0, 0, "price; DROP TABLE products", ProductFilter{})
+	assert.ErrorIs(t, err, ErrInvalidSort)
+}
+
+func TestParseProductSort(t *testing.T) {
+	columns, err := ParseProductSort("-price,name")
+	assert.NoError(t, err)
+	assert.Len(t, columns, 3)
+	assert.Equal(t, "price_amount", columns[0].Column.Name)
+	assert.True(t, columns[0].Desc)
+	assert.Equal(t, "name", columns[1].Column.Name)
+	assert.False(t, columns[1].Desc)
+	assert.Equal(t, "id", columns[2].Column.Name)
+
+	columns, err = ParseProductSort("desc")
+	assert.NoError(t, err)
+	assert.Equal(t, "created_at", columns[0].Column.Name)
+	assert.True(t, columns[0].Desc)
+
+	for _, sort := range []string{"password", "name,name", "-", "name,"} {
+		_, err = ParseProductSort(sort)
+		assert.ErrorIs(t, err, ErrInvalidSort, sort)
+	}
+}
+
+func TestFindAllProductsLimitsPageSize(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open("file::memory:"), &gorm.Config{})
+	if err != nil {
+		t.Error(err)
+	}
+	db.AutoMigrate(&entity.Product{})
+	productDb := NewProductDB(db)
+	for i := 0; i < MaxPageSize+5; i++ {
+		product, err := entity.NewProduct(fmt.Sprintf("Product %d", i), pkg.NewMoney(100, "USD"))
+		assert.NoError(t, err)
+		assert.NoError(t, productDb.Create(product))
+	}
+	products, err := productDb.FindAll(0, 0, "asc", ProductFilter{})
+	assert.NoError(t, err)
+	assert.Len(t, products, DefaultPageSize)
+
+	products, err = productDb.FindAll(1, MaxPageSize+5, "asc", ProductFilter{})
+	assert.NoError(t, err)
+	assert.Len(t, products, MaxPageSize)
+
+	total, err := productDb.Count(ProductFilter{})
+	assert.NoError(t, err)
+	assert.Equal(t, int64(MaxPageSize+5), total)
+
+	total, err = productDb.Count(ProductFilter{Name: "Product 10"})
+	assert.NoError(t, err)
+	assert.Equal(t, int64(6), total)
+}
+
+func TestFindAfterWalksAllProductsWhileInserting(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open("file::memory:"), &gorm.Config{})
+	if err != nil {
+		t.Error(err)
+	}
+	db.AutoMigrate(&entity.Product{})
+	productDb := NewProductDB(db)
+	start := time.Now()
+	for i := 0; i < 10; i++ {
+		product, err := entity.NewProduct(fmt.Sprintf("Product %d", i), pkg.NewMoney(100, "USD"))
+		assert.NoError(t, err)
+		// pairs of products share the same created_at, so the id breaks ties
+		product.CreatedAt = start.Add(time.Duration(i/2) * time.Second)
+		assert.NoError(t, productDb.Create(product))
+	}
+
+	seen := map[string]bool{}
+	var cursor *ProductCursor
+	for pages := 0; ; pages++ {
+		assert.Less(t, pages, 4)
+		products, next, err := productDb.FindAfter(cursor, 3, ProductFilter{})
+		assert.NoError(t, err)
+		for _, p := range products {
+			assert.False(t, seen[p.ID.String()], "duplicated %s", p.Name)
+			seen[p.ID.String()] = true
+		}
+		if next == nil {
+			assert.Len(t, products, 1)
+			break
+		}
+		assert.Len(t, products, 3)
+		cursor = next
+
+		// a product inserted before the cursor must not shift the next page
+		if pages == 0 {
+			product, err := entity.NewProduct("Late product", pkg.NewMoney(100, "USD"))
+			assert.NoError(t, err)
+			product.CreatedAt = start.Add(-time.Second)
+			assert.NoError(t, productDb.Create(product))
+		}
+	}
+	assert.Len(t, seen, 10)
+}
+
+func TestUpdateProductWhenInvalid(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open("file::memory:"), &gorm.Config{})
+	if err != nil {
+		t.Error(err)
+	}
+	db.AutoMigrate(&entity.Product{})
+	productDb := NewProductDB(db)
+	product, err := entity.NewProduct("Product 1", pkg.NewMoney(1050, "USD"))
+	assert.NoError(t, err)
+	assert.NoError(t, productDb.Create(product))
+
+	err = productDb.Update(&entity.Product{ID: product.ID, Name: "Product 2"})
+	assert.ErrorIs(t, err, entity.ErrPriceIsRequired)
+	product, err = productDb.FindById(product.ID.String())
+	assert.NoError(t, err)
+	assert.Equal(t, "Product 1", product.Name)
+	assert.Equal(t, pkg.NewMoney(1050, "USD"), product.Price)
+}
+
+func TestUpdateProductWhenVersionConflicts(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open("file::memory:"), &gorm.Config{})
+	if err != nil {
+		t.Error(err)
+	}
+	db.AutoMigrate(&entity.Product{}, &productCategory{}, &entity.Stock{}, &entity.Reservation{})
+	productDb := NewProductDB(db)
+	product, err := entity.NewProduct("Product 1", pkg.NewMoney(1050, "USD"))
+	assert.NoError(t, err)
+	assert.NoError(t, productDb.Create(product))
+	assert.Equal(t, int64(1), product.Version)
+
+	first, _ := productDb.FindById(product.ID.String())
+	second, _ := productDb.FindById(product.ID.String())
+	first.Name = "First"
+	assert.NoError(t, productDb.Update(first))
+	assert.Equal(t, int64(2), first.Version)
+	second.Name = "Second"
+	assert.ErrorIs(t, productDb.Update(second), ErrVersionConflict)
+
+	found, err := productDb.FindById(product.ID.String())
+	assert.NoError(t, err)
+	assert.Equal(t, "First", found.Name)
+	assert.Equal(t, int64(2), found.Version)
+
+	assert.ErrorIs(t, productDb.Delete(product.ID.String(), 1), ErrVersionConflict)
+	assert.NoError(t, productDb.Delete(product.ID.String(), 2))
+}
+
+func TestDeletedProductsCanBeRestoredUntilPurged(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open("file::memory:"), &gorm.Config{})
+	if err != nil {
+		t.Error(err)
+	}
+	db.AutoMigrate(&entity.Product{}, &entity.Category{}, &productCategory{}, &entity.Stock{}, &entity.Reservation{})
+	productDb := NewProductDB(db)
+	kept, _ := entity.NewProduct("Kept", pkg.NewMoney(1050, "USD"))
+	deleted, _ := entity.NewProduct("Deleted", pkg.NewMoney(2050, "USD"))
+	assert.NoError(t, productDb.Create(kept))
+	assert.NoError(t, productDb.Create(deleted))
+	category, _ := entity.NewCategory("Category", nil)
+	assert.NoError(t, NewCategoryDB(db).Create(category))
+	assert.NoError(t, productDb.SetCategories(deleted.ID.String(), []string{category.ID.String()}))
+	_, err = NewInventoryDB(db).Increment(deleted.ID.String(), 3)
+	assert.NoError(t, err)
+
+	assert.NoError(t, productDb.Delete(deleted.ID.String(), 0))
+	_, err = productDb.FindById(deleted.ID.String())
+	assert.ErrorIs(t, err, gorm.ErrRecordNotFound)
+	products, err := productDb.FindAll(1, 10, "asc", ProductFilter{})
+	assert.NoError(t, err)
+	assert.Len(t, products, 1)
+	total, err := productDb.Count(ProductFilter{})
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), total)
+	_, err = NewInventoryDB(db).FindStock(deleted.ID.String())
+	assert.ErrorIs(t, err, gorm.ErrRecordNotFound)
+
+	trash, err := productDb.FindDeleted(1, 10)
+	assert.NoError(t, err)
+	assert.Len(t, trash, 1)
+	assert.Equal(t, deleted.ID, trash[0].ID)
+	assert.True(t, trash[0].DeletedAt.Valid)
+	total, err = productDb.CountDeleted()
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), total)
+
+	restored, err := productDb.Restore(deleted.ID.String())
+	assert.NoError(t, err)
+	assert.False(t, restored.DeletedAt.Valid)
+	assert.Equal(t, int64(2), restored.Version)
+	_, err = productDb.Restore(deleted.ID.String())
+	assert.ErrorIs(t, err, gorm.ErrRecordNotFound)
+	categories, err := productDb.FindCategories(deleted.ID.String())
+	assert.NoError(t, err)
+	assert.Len(t, categories, 1)
+	stock, err := NewInventoryDB(db).FindStock(deleted.ID.String())
+	assert.NoError(t, err)
+	assert.Equal(t, 3, stock.Quantity)
+
+	assert.NoError(t, productDb.Delete(deleted.ID.String(), 2))
+	purged, err := productDb.Purge(time.Now().Add(-time.Hour))
+	assert.NoError(t, err)
+	assert.Equal(t, int64(0), purged)
+	purged, err = productDb.Purge(time.Now().Add(time.Second))
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), purged)
+	total, err = productDb.CountDeleted()
+	assert.NoError(t, err)
+	assert.Equal(t, int64(0), total)
+	var links int64
+	db.Model(&productCategory{}).Count(&links)
+	assert.Equal(t, int64(0), links)
+	_, err = productDb.Restore(deleted.ID.String())
+	assert.ErrorIs(t, err, gorm.ErrRecordNotFound)
+}
+
+func TestProductOwnership(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open("file::memory:"), &gorm.Config{})
+	if err != nil {
+		t.Error(err)
+	}
+	db.AutoMigrate(&entity.Product{})
+	productDb := NewProductDB(db)
+	owner, editor := pkg.NewID(), pkg.NewID()
+	mine, _ := entity.NewProduct("Mine", pkg.NewMoney(1050, "USD"))
+	mine.CreatedBy, mine.UpdatedBy = &owner, &owner
+	assert.NoError(t, productDb.Create(mine))
+	other, _ := entity.NewProduct("Other", pkg.NewMoney(1050, "USD"))
+	assert.NoError(t, productDb.Create(other))
+
+	products, err := productDb.FindAll(1, 10, "", ProductFilter{CreatedBy: owner.String()})
+	assert.NoError(t, err)
+	assert.Len(t, products, 1)
+	assert.Equal(t, mine.ID, products[0].ID)
+
+	// the owner is kept whoever makes the change
+	changed := &entity.Product{ID: mine.ID, Name: "Changed", Price: mine.Price, CreatedBy: &editor, UpdatedBy: &editor}
+	assert.NoError(t, productDb.Update(changed))
+	found, err := productDb.FindById(mine.ID.String())
+	assert.NoError(t, err)
+	assert.Equal(t, owner, *found.CreatedBy)
+	assert.Equal(t, editor, *found.UpdatedBy)
+	assert.True(t, found.IsOwnedBy(owner))
+}
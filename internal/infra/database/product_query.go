@@ -0,0 +1,133 @@
+package database
+
+import (
+	"errors"
+	"strings"
+	"time"
+
+	pkg "github.com/brenoproti/go-api/pkg/entity"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var ErrInvalidSort = errors.New("invalid sort")
+
+const (
+	DefaultPageSize = 20
+	MaxPageSize     = 100
+)
+
+// productSortColumns whitelists the fields products can be sorted by and maps
+// them to their columns.
+var productSortColumns = map[string]string{
+	"name":       "name",
+	"price":      "price_amount",
+	"created_at": "created_at",
+}
+
+// ProductFilter narrows down the products returned by FindAll. Zero values
+// mean no filtering.
+type ProductFilter struct {
+	Name               string
+	Currency           string
+	PriceGte           *pkg.Money
+	PriceLte           *pkg.Money
+	CreatedGte         *time.Time
+	CreatedLt          *time.Time
+	CategoryID         string
+	IncludeDescendants bool
+	CreatedBy          string
+}
+
+func (f ProductFilter) apply(db *gorm.DB) (*gorm.DB, error) {
+	query := db
+	if f.Name != "" {
+		query = query.Where("LOWER(name) LIKE ? ESCAPE '!'", "%"+escapeLike(strings.ToLower(f.Name))+"%")
+	}
+	if f.Currency != "" {
+		query = query.Where("price_currency = ?", f.Currency)
+	}
+	if f.PriceGte != nil {
+		query = query.Where("price_currency = ? AND price_amount >= ?", f.PriceGte.Currency, f.PriceGte.Amount)
+	}
+	if f.PriceLte != nil {
+		query = query.Where("price_currency = ? AND price_amount <= ?", f.PriceLte.Currency, f.PriceLte.Amount)
+	}
+	if f.CreatedGte != nil {
+		query = query.Where("created_at >= ?", *f.CreatedGte)
+	}
+	if f.CreatedLt != nil {
+		query = query.Where("created_at < ?", *f.CreatedLt)
+	}
+	if f.CreatedBy != "" {
+		query = query.Where("created_by = ?", f.CreatedBy)
+	}
+	if f.CategoryID != "" {
+		categoryIDs := []string{f.CategoryID}
+		if f.IncludeDescendants {
+			descendants, err := descendantIDs(db, f.CategoryID)
+			if err != nil {
+				return nil, err
+			}
+			categoryIDs = append(categoryIDs, descendants...)
+		}
+		query = query.Where("id IN (?)",
+			db.Model(&productCategory{}).Select("product_id").Where("category_id IN ?", categoryIDs),
+		)
+	}
+	return query, nil
+}
+
+// ProductCursor is the position of the last product of a keyset page.
+type ProductCursor struct {
+	CreatedAt time.Time `json:"created_at"`
+	ID        string    `json:"id"`
+}
+
+// ParseProductSort turns a sort expression such as "-price,name" into order
+// by columns. A leading "-" sorts that field in descending order. For
+// compatibility, "asc" and "desc" alone sort by created_at. Only whitelisted
+// fields are accepted, and the id is always added as a final tie breaker so
+// that pages are stable.
+func ParseProductSort(sort string) ([]clause.OrderByColumn, error) {
+	switch sort {
+	case "", "asc":
+		sort = "created_at"
+	case "desc":
+		sort = "-created_at"
+	}
+	var columns []clause.OrderByColumn
+	seen := map[string]bool{}
+	for _, field := range strings.Split(sort, ",") {
+		field = strings.TrimSpace(field)
+		desc := strings.HasPrefix(field, "-")
+		field = strings.TrimPrefix(strings.TrimPrefix(field, "-"), "+")
+		column, ok := productSortColumns[field]
+		if !ok || seen[column] {
+			return nil, ErrInvalidSort
+		}
+		seen[column] = true
+		columns = append(columns, clause.OrderByColumn{Column: clause.Column{Name: column}, Desc: desc})
+	}
+	columns = append(columns, clause.OrderByColumn{Column: clause.Column{Name: "id"}})
+	return columns, nil
+}
+
+// NormalizePage defaults to the first page and clamps the limit to
+// MaxPageSize, so a listing never returns the whole table at once.
+func NormalizePage(page, limit int) (int, int) {
+	if page < 1 {
+		page = 1
+	}
+	if limit < 1 {
+		limit = DefaultPageSize
+	}
+	if limit > MaxPageSize {
+		limit = MaxPageSize
+	}
+	return page, limit
+}
+
+func escapeLike(s string) string {
+	return strings.NewReplacer("!", "!!", "%", "!%", "_", "!_").Replace(s)
+}
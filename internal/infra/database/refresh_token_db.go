@@ -0,0 +1,116 @@
+package database
+
+import (
+	"errors"
+	"time"
+
+	"github.com/brenoproti/go-api/internal/entity"
+	"gorm.io/gorm"
+)
+
+// RefreshTokenDB stores refresh tokens and rotates them. A token can only be
+// exchanged once; using it again revokes every token of its family, since
+// either the client or an attacker holds a stolen copy.
+type RefreshTokenDB struct {
+	DB *gorm.DB
+}
+
+func NewRefreshTokenDB(db *gorm.DB) *RefreshTokenDB {
+	return &RefreshTokenDB{
+		DB: db,
+	}
+}
+
+func (t *RefreshTokenDB) Create(token *entity.RefreshToken) error {
+	return t.DB.Create(token).Error
+}
+
+// Rotate exchanges a refresh token for a new one of the same family, valid
+// for ttl, returning the new entity and the token to hand to the client.
+func (t *RefreshTokenDB) Rotate(token string, ttl time.Duration) (*entity.RefreshToken, string, error) {
+	var current entity.RefreshToken
+	err := t.DB.First(&current, "token_hash = ?", entity.HashToken(token)).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, "", entity.ErrInvalidRefreshToken
+	}
+	if err != nil {
+		return nil, "", err
+	}
+	if current.IsReplaced() {
+		return nil, "", t.revokeReusedFamily(&current)
+	}
+	if current.IsRevoked() {
+		return nil, "", entity.ErrRefreshTokenRevoked
+	}
+	if current.IsExpired(time.Now()) {
+		return nil, "", entity.ErrRefreshTokenExpired
+	}
+	next, plain, err := entity.NewRefreshToken(current.UserID, &current.FamilyID, ttl)
+	if err != nil {
+		return nil, "", err
+	}
+	reused := false
+	err = t.DB.Transaction(func(tx *gorm.DB) error {
+		// only one of concurrent rotations of the same token can revoke it
+		result := tx.Model(&entity.RefreshToken{}).
+			Where("id = ? AND revoked_at IS NULL", current.ID).
+			Updates(map[string]interface{}{"revoked_at": next.CreatedAt, "replaced_by": next.ID})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			reused = true
+			return nil
+		}
+		return tx.Create(next).Error
+	})
+	if err != nil {
+		return nil, "", err
+	}
+	if reused {
+		return nil, "", t.revokeReusedFamily(&current)
+	}
+	return next, plain, nil
+}
+
+func (t *RefreshTokenDB) revokeReusedFamily(token *entity.RefreshToken) error {
+	if err := t.RevokeFamily(token.FamilyID.String()); err != nil {
+		return err
+	}
+	return entity.ErrRefreshTokenReused
+}
+
+func (t *RefreshTokenDB) RevokeFamily(familyID string) error {
+	return t.DB.Model(&entity.RefreshToken{}).
+		Where("family_id = ? AND revoked_at IS NULL", familyID).
+		Update("revoked_at", time.Now()).Error
+}
+
+// Revoke revokes the family of a token of the given user, ending the session
+// it was issued for.
+func (t *RefreshTokenDB) Revoke(token, userID string) error {
+	var current entity.RefreshToken
+	err := t.DB.First(&current, "token_hash = ? AND user_id = ?", entity.HashToken(token), userID).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return entity.ErrInvalidRefreshToken
+	}
+	if err != nil {
+		return err
+	}
+	return t.RevokeFamily(current.FamilyID.String())
+}
+
+// RevokeUser revokes every refresh token of the user, signing them out of
+// all their sessions.
+func (t *RefreshTokenDB) RevokeUser(userID string) error {
+	return t.DB.Model(&entity.RefreshToken{}).
+		Where("user_id = ? AND revoked_at IS NULL", userID).
+		Update("revoked_at", time.Now()).Error
+}
+
+// DeleteExpired removes the tokens that expired before the given time. Their
+// families can still be revoked through the tokens that replaced them.
+func (t *RefreshTokenDB) DeleteExpired(before time.Time) (int64, error) {
+	result := t.DB.Where("expires_at < ?", before).Delete(&entity.RefreshToken{})
+	return result.RowsAffected, result.Error
+}
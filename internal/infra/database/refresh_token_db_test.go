@@ -0,0 +1,113 @@
+package database
+
+import (
+	"testing"
+	"time"
+
+	"github.com/brenoproti/go-api/internal/entity"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+func newRefreshTokenTestDB(t *testing.T) (*gorm.DB, *entity.User) {
+	db, err := gorm.Open(sqlite.Open("file::memory:"), &gorm.Config{})
+	if err != nil {
+		t.Error(err)
+	}
+	db.AutoMigrate(&entity.User{}, &entity.RefreshToken{})
+	user, _ := entity.NewUser("John", "j@j.com", "Str0ng-Passw0rd")
+	assert.NoError(t, NewUser(db).Create(user))
+	return db, user
+}
+
+func TestRotateRefreshToken(t *testing.T) {
+	db, user := newRefreshTokenTestDB(t)
+	tokenDb := NewRefreshTokenDB(db)
+	first, token, err := entity.NewRefreshToken(user.ID, nil, time.Hour)
+	assert.NoError(t, err)
+	assert.NoError(t, tokenDb.Create(first))
+
+	second, rotated, err := tokenDb.Rotate(token, time.Hour)
+	assert.NoError(t, err)
+	assert.NotEqual(t, token, rotated)
+	assert.Equal(t, first.FamilyID, second.FamilyID)
+	assert.Equal(t, user.ID, second.UserID)
+
+	var replaced entity.RefreshToken
+	assert.NoError(t, db.First(&replaced, "id = ?", first.ID).Error)
+	assert.True(t, replaced.IsRevoked())
+	assert.Equal(t, second.ID, *replaced.ReplacedBy)
+
+	third, rotated, err := tokenDb.Rotate(rotated, time.Hour)
+	assert.NoError(t, err)
+	assert.Equal(t, first.FamilyID, third.FamilyID)
+
+	_, _, err = tokenDb.Rotate("unknown", time.Hour)
+	assert.ErrorIs(t, err, entity.ErrInvalidRefreshToken)
+}
+
+func TestReusedRefreshTokenRevokesTheFamily(t *testing.T) {
+	db, user := newRefreshTokenTestDB(t)
+	tokenDb := NewRefreshTokenDB(db)
+	stolen, token, _ := entity.NewRefreshToken(user.ID, nil, time.Hour)
+	assert.NoError(t, tokenDb.Create(stolen))
+	other, otherToken, _ := entity.NewRefreshToken(user.ID, nil, time.Hour)
+	assert.NoError(t, tokenDb.Create(other))
+
+	_, latest, err := tokenDb.Rotate(token, time.Hour)
+	assert.NoError(t, err)
+	_, _, err = tokenDb.Rotate(token, time.Hour)
+	assert.ErrorIs(t, err, entity.ErrRefreshTokenReused)
+	_, _, err = tokenDb.Rotate(latest, time.Hour)
+	assert.ErrorIs(t, err, entity.ErrRefreshTokenRevoked)
+
+	// other sessions of the user are left alone
+	_, _, err = tokenDb.Rotate(otherToken, time.Hour)
+	assert.NoError(t, err)
+}
+
+func TestExpiredRefreshTokens(t *testing.T) {
+	db, user := newRefreshTokenTestDB(t)
+	tokenDb := NewRefreshTokenDB(db)
+	expired, token, _ := entity.NewRefreshToken(user.ID, nil, time.Hour)
+	expired.ExpiresAt = time.Now().Add(-time.Second)
+	assert.NoError(t, tokenDb.Create(expired))
+	_, _, err := tokenDb.Rotate(token, time.Hour)
+	assert.ErrorIs(t, err, entity.ErrRefreshTokenExpired)
+
+	valid, _, _ := entity.NewRefreshToken(user.ID, nil, time.Hour)
+	assert.NoError(t, tokenDb.Create(valid))
+	deleted, err := tokenDb.DeleteExpired(time.Now())
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), deleted)
+}
+
+func TestRevokeUserRefreshTokens(t *testing.T) {
+	db, user := newRefreshTokenTestDB(t)
+	tokenDb := NewRefreshTokenDB(db)
+	first, firstToken, _ := entity.NewRefreshToken(user.ID, nil, time.Hour)
+	second, secondToken, _ := entity.NewRefreshToken(user.ID, nil, time.Hour)
+	assert.NoError(t, tokenDb.Create(first))
+	assert.NoError(t, tokenDb.Create(second))
+
+	assert.NoError(t, tokenDb.RevokeUser(user.ID.String()))
+	_, _, err := tokenDb.Rotate(firstToken, time.Hour)
+	assert.ErrorIs(t, err, entity.ErrRefreshTokenRevoked)
+	_, _, err = tokenDb.Rotate(secondToken, time.Hour)
+	assert.ErrorIs(t, err, entity.ErrRefreshTokenRevoked)
+}
+
+func TestRevokeRefreshToken(t *testing.T) {
+	db, user := newRefreshTokenTestDB(t)
+	tokenDb := NewRefreshTokenDB(db)
+	first, token, _ := entity.NewRefreshToken(user.ID, nil, time.Hour)
+	assert.NoError(t, tokenDb.Create(first))
+	_, rotated, err := tokenDb.Rotate(token, time.Hour)
+	assert.NoError(t, err)
+
+	assert.ErrorIs(t, tokenDb.Revoke(rotated, first.FamilyID.String()), entity.ErrInvalidRefreshToken)
+	assert.NoError(t, tokenDb.Revoke(rotated, user.ID.String()))
+	_, _, err = tokenDb.Rotate(rotated, time.Hour)
+	assert.ErrorIs(t, err, entity.ErrRefreshTokenRevoked)
+}
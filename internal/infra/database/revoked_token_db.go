@@ -0,0 +1,80 @@
+package database
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/brenoproti/go-api/internal/entity"
+	"gorm.io/gorm"
+)
+
+// RevokedTokenDB stores the access tokens revoked before they expire. Since
+// every authenticated request asks whether its token was revoked, the
+// revocations already seen are kept in memory until the token expires.
+// Tokens not found in memory are still looked up, as another instance may
+// have revoked them.
+type RevokedTokenDB struct {
+	DB *gorm.DB
+
+	mu    sync.RWMutex
+	cache map[string]time.Time
+}
+
+func NewRevokedTokenDB(db *gorm.DB) *RevokedTokenDB {
+	return &RevokedTokenDB{
+		DB:    db,
+		cache: map[string]time.Time{},
+	}
+}
+
+// Revoke keeps the token from being accepted again. Revoking a token twice
+// is not an error.
+func (t *RevokedTokenDB) Revoke(token *entity.RevokedToken) error {
+	err := t.DB.Create(token).Error
+	if err != nil && !errors.Is(err, gorm.ErrDuplicatedKey) {
+		return err
+	}
+	t.remember(token.ID.String(), token.ExpiresAt)
+	return nil
+}
+
+// IsRevoked tells whether the token with the given jti was revoked.
+func (t *RevokedTokenDB) IsRevoked(id string) (bool, error) {
+	t.mu.RLock()
+	_, found := t.cache[id]
+	t.mu.RUnlock()
+	if found {
+		return true, nil
+	}
+	var token entity.RevokedToken
+	err := t.DB.First(&token, "id = ?", id).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	t.remember(id, token.ExpiresAt)
+	return true, nil
+}
+
+func (t *RevokedTokenDB) remember(id string, expiresAt time.Time) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.cache[id] = expiresAt
+}
+
+// DeleteExpired forgets the revocations of the tokens that expired before
+// the given time, which would be rejected anyway.
+func (t *RevokedTokenDB) DeleteExpired(before time.Time) (int64, error) {
+	t.mu.Lock()
+	for id, expiresAt := range t.cache {
+		if expiresAt.Before(before) {
+			delete(t.cache, id)
+		}
+	}
+	t.mu.Unlock()
+	result := t.DB.Where("expires_at < ?", before).Delete(&entity.RevokedToken{})
+	return result.RowsAffected, result.Error
+}
@@ -0,0 +1,63 @@
+package database
+
+import (
+	"testing"
+	"time"
+
+	"github.com/brenoproti/go-api/internal/entity"
+	pkg "github.com/brenoproti/go-api/pkg/entity"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+func newRevokedTokenTestDB(t *testing.T) *gorm.DB {
+	db, err := gorm.Open(sqlite.Open("file::memory:"), &gorm.Config{TranslateError: true})
+	if err != nil {
+		t.Error(err)
+	}
+	db.AutoMigrate(&entity.RevokedToken{})
+	return db
+}
+
+func TestRevokeToken(t *testing.T) {
+	db := newRevokedTokenTestDB(t)
+	tokenDb := NewRevokedTokenDB(db)
+	token, err := entity.NewRevokedToken(pkg.NewID(), pkg.NewID(), time.Now().Add(time.Hour))
+	assert.NoError(t, err)
+
+	revoked, err := tokenDb.IsRevoked(token.ID.String())
+	assert.NoError(t, err)
+	assert.False(t, revoked)
+
+	assert.NoError(t, tokenDb.Revoke(token))
+	assert.NoError(t, tokenDb.Revoke(token))
+	revoked, err = tokenDb.IsRevoked(token.ID.String())
+	assert.NoError(t, err)
+	assert.True(t, revoked)
+
+	// another instance sharing the database sees the revocation too
+	revoked, err = NewRevokedTokenDB(db).IsRevoked(token.ID.String())
+	assert.NoError(t, err)
+	assert.True(t, revoked)
+}
+
+func TestDeleteExpiredRevokedTokens(t *testing.T) {
+	db := newRevokedTokenTestDB(t)
+	tokenDb := NewRevokedTokenDB(db)
+	expired, _ := entity.NewRevokedToken(pkg.NewID(), pkg.NewID(), time.Now().Add(-time.Minute))
+	active, _ := entity.NewRevokedToken(pkg.NewID(), pkg.NewID(), time.Now().Add(time.Hour))
+	assert.NoError(t, tokenDb.Revoke(expired))
+	assert.NoError(t, tokenDb.Revoke(active))
+
+	deleted, err := tokenDb.DeleteExpired(time.Now())
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), deleted)
+
+	revoked, err := tokenDb.IsRevoked(expired.ID.String())
+	assert.NoError(t, err)
+	assert.False(t, revoked)
+	revoked, err = tokenDb.IsRevoked(active.ID.String())
+	assert.NoError(t, err)
+	assert.True(t, revoked)
+}
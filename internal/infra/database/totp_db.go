@@ -0,0 +1,140 @@
+package database
+
+import (
+	"errors"
+	"time"
+
+	"github.com/brenoproti/go-api/internal/entity"
+	"gorm.io/gorm"
+)
+
+var ErrTwoFactorEnabled = errors.New("two-factor authentication is already enabled")
+
+type TOTPDB struct {
+	DB *gorm.DB
+}
+
+func NewTOTPDB(db *gorm.DB) *TOTPDB {
+	return &TOTPDB{
+		DB: db,
+	}
+}
+
+// Enroll stores a new secret for the user, in place of one that was not
+// confirmed yet.
+func (t *TOTPDB) Enroll(secret *entity.TOTPSecret) error {
+	return t.DB.Transaction(func(tx *gorm.DB) error {
+		var current entity.TOTPSecret
+		err := tx.First(&current, "user_id = ?", secret.UserID).Error
+		if err == nil && current.IsConfirmed() {
+			return ErrTwoFactorEnabled
+		}
+		if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+			return err
+		}
+		if err := tx.Where("user_id = ?", secret.UserID).Delete(&entity.TOTPSecret{}).Error; err != nil {
+			return err
+		}
+		return tx.Create(secret).Error
+	})
+}
+
+func (t *TOTPDB) FindByUser(userID string) (*entity.TOTPSecret, error) {
+	var secret entity.TOTPSecret
+	if err := t.DB.First(&secret, "user_id = ?", userID).Error; err != nil {
+		return nil, err
+	}
+	return &secret, nil
+}
+
+// Confirm enables two-factor authentication once the user typed in the code
+// of the given time step, and gives them new recovery codes. Their refresh
+// tokens are revoked, since they were issued without a second factor.
+func (t *TOTPDB) Confirm(userID string, step int64, codes []entity.RecoveryCode) error {
+	return t.DB.Transaction(func(tx *gorm.DB) error {
+		now := time.Now()
+		result := tx.Model(&entity.TOTPSecret{}).
+			Where("user_id = ? AND confirmed_at IS NULL AND last_used_step < ?", userID, step).
+			Updates(map[string]interface{}{"confirmed_at": now, "last_used_step": step})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return entity.ErrInvalidTOTPCode
+		}
+		if err := replaceRecoveryCodes(tx, userID, codes); err != nil {
+			return err
+		}
+		return tx.Model(&entity.RefreshToken{}).
+			Where("user_id = ? AND revoked_at IS NULL", userID).
+			Update("revoked_at", now).Error
+	})
+}
+
+// UseCode records that the user logged in with the code of the given time
+// step. It fails if that code, or a later one, was already used.
+func (t *TOTPDB) UseCode(userID string, step int64) error {
+	result := t.DB.Model(&entity.TOTPSecret{}).
+		Where("user_id = ? AND confirmed_at IS NOT NULL AND last_used_step < ?", userID, step).
+		Update("last_used_step", step)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return entity.ErrInvalidTOTPCode
+	}
+	return nil
+}
+
+// UseRecoveryCode uses up one of the recovery codes of the user.
+func (t *TOTPDB) UseRecoveryCode(userID, code string) error {
+	result := t.DB.Model(&entity.RecoveryCode{}).
+		Where("user_id = ? AND code_hash = ? AND used_at IS NULL", userID, entity.HashRecoveryCode(code)).
+		Update("used_at", time.Now())
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return entity.ErrInvalidTOTPCode
+	}
+	return nil
+}
+
+// ReplaceRecoveryCodes throws away the recovery codes of the user, used or
+// not, for new ones.
+func (t *TOTPDB) ReplaceRecoveryCodes(userID string, codes []entity.RecoveryCode) error {
+	return t.DB.Transaction(func(tx *gorm.DB) error {
+		return replaceRecoveryCodes(tx, userID, codes)
+	})
+}
+
+func replaceRecoveryCodes(tx *gorm.DB, userID string, codes []entity.RecoveryCode) error {
+	if err := tx.Where("user_id = ?", userID).Delete(&entity.RecoveryCode{}).Error; err != nil {
+		return err
+	}
+	return tx.Create(&codes).Error
+}
+
+// CountRecoveryCodes is how many recovery codes the user has left.
+func (t *TOTPDB) CountRecoveryCodes(userID string) (int64, error) {
+	var count int64
+	err := t.DB.Model(&entity.RecoveryCode{}).Where("user_id = ? AND used_at IS NULL", userID).Count(&count).Error
+	if err != nil {
+		return 0, err
+	}
+	return count, nil
+}
+
+// Disable removes the secret and recovery codes of the user.
+func (t *TOTPDB) Disable(userID string) error {
+	return t.DB.Transaction(func(tx *gorm.DB) error {
+		result := tx.Where("user_id = ?", userID).Delete(&entity.TOTPSecret{})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return gorm.ErrRecordNotFound
+		}
+		return tx.Where("user_id = ?", userID).Delete(&entity.RecoveryCode{}).Error
+	})
+}
@@ -0,0 +1,110 @@
+package database
+
+import (
+	"testing"
+	"time"
+
+	"github.com/brenoproti/go-api/internal/entity"
+	pkg "github.com/brenoproti/go-api/pkg/entity"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+)
+
+func newTOTPTestDB(t *testing.T) (*TOTPDB, *entity.User) {
+	db, user := newRefreshTokenTestDB(t)
+	db.AutoMigrate(&entity.TOTPSecret{}, &entity.RecoveryCode{})
+	return NewTOTPDB(db), user
+}
+
+func TestEnrollTOTP(t *testing.T) {
+	totpDb, user := newTOTPTestDB(t)
+	first, _ := entity.NewTOTPSecret(user.ID)
+	assert.NoError(t, totpDb.Enroll(first))
+	// enrolling again starts over until confirmed
+	second, _ := entity.NewTOTPSecret(user.ID)
+	assert.NoError(t, totpDb.Enroll(second))
+	found, err := totpDb.FindByUser(user.ID.String())
+	assert.NoError(t, err)
+	assert.Equal(t, second.Secret, found.Secret)
+	assert.False(t, found.IsConfirmed())
+
+	codes, _, _ := entity.NewRecoveryCodes(user.ID)
+	assert.NoError(t, totpDb.Confirm(user.ID.String(), 10, codes))
+	third, _ := entity.NewTOTPSecret(user.ID)
+	assert.ErrorIs(t, totpDb.Enroll(third), ErrTwoFactorEnabled)
+
+	_, err = totpDb.FindByUser(pkg.NewID().String())
+	assert.ErrorIs(t, err, gorm.ErrRecordNotFound)
+}
+
+func TestConfirmTOTP(t *testing.T) {
+	totpDb, user := newTOTPTestDB(t)
+	tokenDb := NewRefreshTokenDB(totpDb.DB)
+	refresh, token, _ := entity.NewRefreshToken(user.ID, nil, time.Hour)
+	assert.NoError(t, tokenDb.Create(refresh))
+	secret, _ := entity.NewTOTPSecret(user.ID)
+	assert.NoError(t, totpDb.Enroll(secret))
+
+	codes, _, _ := entity.NewRecoveryCodes(user.ID)
+	assert.NoError(t, totpDb.Confirm(user.ID.String(), 10, codes))
+	found, err := totpDb.FindByUser(user.ID.String())
+	assert.NoError(t, err)
+	assert.True(t, found.IsConfirmed())
+	assert.Equal(t, int64(10), found.LastUsedStep)
+	left, err := totpDb.CountRecoveryCodes(user.ID.String())
+	assert.NoError(t, err)
+	assert.Equal(t, int64(entity.RecoveryCodeCount), left)
+	_, _, err = tokenDb.Rotate(token, time.Hour)
+	assert.ErrorIs(t, err, entity.ErrRefreshTokenRevoked)
+
+	// only once
+	assert.ErrorIs(t, totpDb.Confirm(user.ID.String(), 11, codes), entity.ErrInvalidTOTPCode)
+}
+
+func TestUseTOTPCode(t *testing.T) {
+	totpDb, user := newTOTPTestDB(t)
+	secret, _ := entity.NewTOTPSecret(user.ID)
+	assert.NoError(t, totpDb.Enroll(secret))
+	// not before it is confirmed
+	assert.ErrorIs(t, totpDb.UseCode(user.ID.String(), 10), entity.ErrInvalidTOTPCode)
+
+	codes, _, _ := entity.NewRecoveryCodes(user.ID)
+	assert.NoError(t, totpDb.Confirm(user.ID.String(), 10, codes))
+	assert.ErrorIs(t, totpDb.UseCode(user.ID.String(), 10), entity.ErrInvalidTOTPCode)
+	assert.NoError(t, totpDb.UseCode(user.ID.String(), 11))
+	assert.ErrorIs(t, totpDb.UseCode(user.ID.String(), 11), entity.ErrInvalidTOTPCode)
+}
+
+func TestRecoveryCodes(t *testing.T) {
+	totpDb, user := newTOTPTestDB(t)
+	secret, _ := entity.NewTOTPSecret(user.ID)
+	assert.NoError(t, totpDb.Enroll(secret))
+	codes, plain, _ := entity.NewRecoveryCodes(user.ID)
+	assert.NoError(t, totpDb.Confirm(user.ID.String(), 10, codes))
+
+	assert.NoError(t, totpDb.UseRecoveryCode(user.ID.String(), plain[0]))
+	assert.ErrorIs(t, totpDb.UseRecoveryCode(user.ID.String(), plain[0]), entity.ErrInvalidTOTPCode)
+	assert.ErrorIs(t, totpDb.UseRecoveryCode(pkg.NewID().String(), plain[1]), entity.ErrInvalidTOTPCode)
+	left, err := totpDb.CountRecoveryCodes(user.ID.String())
+	assert.NoError(t, err)
+	assert.Equal(t, int64(entity.RecoveryCodeCount-1), left)
+
+	newCodes, newPlain, _ := entity.NewRecoveryCodes(user.ID)
+	assert.NoError(t, totpDb.ReplaceRecoveryCodes(user.ID.String(), newCodes))
+	assert.ErrorIs(t, totpDb.UseRecoveryCode(user.ID.String(), plain[1]), entity.ErrInvalidTOTPCode)
+	assert.NoError(t, totpDb.UseRecoveryCode(user.ID.String(), newPlain[1]))
+}
+
+func TestDisableTOTP(t *testing.T) {
+	totpDb, user := newTOTPTestDB(t)
+	secret, _ := entity.NewTOTPSecret(user.ID)
+	assert.NoError(t, totpDb.Enroll(secret))
+	codes, plain, _ := entity.NewRecoveryCodes(user.ID)
+	assert.NoError(t, totpDb.Confirm(user.ID.String(), 10, codes))
+
+	assert.NoError(t, totpDb.Disable(user.ID.String()))
+	_, err := totpDb.FindByUser(user.ID.String())
+	assert.ErrorIs(t, err, gorm.ErrRecordNotFound)
+	assert.ErrorIs(t, totpDb.UseRecoveryCode(user.ID.String(), plain[0]), entity.ErrInvalidTOTPCode)
+	assert.ErrorIs(t, totpDb.Disable(user.ID.String()), gorm.ErrRecordNotFound)
+}
@@ -1,10 +1,15 @@
 package database
 
 import (
+	"errors"
+	"time"
+
 	"github.com/brenoproti/go-api/internal/entity"
 	"gorm.io/gorm"
 )
 
+var ErrEmailTaken = errors.New("email is already taken")
+
 type User struct {
 	DB *gorm.DB
 }
@@ -16,13 +21,176 @@ func NewUser(db *gorm.DB) *User {
 }
 
 func (db *User) Create(user *entity.User) error {
-	return db.DB.Create(user).Error
+	user.Email = entity.NormalizeEmail(user.Email)
+	if err := db.checkEmailAvailable(user); err != nil {
+		return err
+	}
+	return emailTakenError(db.DB.Create(user).Error)
 }
 
 func (u *User) FindByEmail(email string) (*entity.User, error) {
 	var user entity.User
-	if err := u.DB.Where("email = ?", email).First(&user).Error; err != nil {
+	if err := u.DB.Where("email = ?", entity.NormalizeEmail(email)).First(&user).Error; err != nil {
 		return nil, err
 	}
 	return &user, nil
 }
+
+func (u *User) FindById(id string) (*entity.User, error) {
+	var user entity.User
+	if err := u.DB.First(&user, "id = ?", id).Error; err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+func (u *User) FindAll(page, limit int) ([]entity.User, error) {
+	page, limit = NormalizePage(page, limit)
+	var users []entity.User
+	err := u.DB.Order("name asc").Order("id asc").Offset((page - 1) * limit).Limit(limit).Find(&users).Error
+	if err != nil {
+		return nil, err
+	}
+	return users, nil
+}
+
+func (u *User) Count() (int64, error) {
+	var total int64
+	if err := u.DB.Model(&entity.User{}).Count(&total).Error; err != nil {
+		return 0, err
+	}
+	return total, nil
+}
+
+// Update saves the name and email of the user. The password and role are
+// left as is, see SetRole. Changing the email makes it unverified again.
+func (u *User) Update(user *entity.User) error {
+	current, err := u.FindById(user.ID.String())
+	if err != nil {
+		return err
+	}
+	user.Role = current.Role
+	user.Email = entity.NormalizeEmail(user.Email)
+	user.EmailVerifiedAt = current.EmailVerifiedAt
+	if user.Email != current.Email {
+		user.EmailVerifiedAt = nil
+	}
+	if err := user.Validate(); err != nil {
+		return err
+	}
+	if err := u.checkEmailAvailable(user); err != nil {
+		return err
+	}
+	err = u.DB.Model(&entity.User{}).Where("id = ?", user.ID).Updates(map[string]interface{}{
+		"name":              user.Name,
+		"email":             user.Email,
+		"email_verified_at": user.EmailVerifiedAt,
+	}).Error
+	return emailTakenError(err)
+}
+
+// SetRole changes the role of the user. Their access and refresh tokens are
+// revoked, since they carry the former role, so the change takes effect
+// right away.
+func (u *User) SetRole(id string, role entity.Role) (*entity.User, error) {
+	user, err := u.FindById(id)
+	if err != nil {
+		return nil, err
+	}
+	if !role.IsValid() {
+		return nil, entity.ErrInvalidRole
+	}
+	if user.Role == role {
+		return user, nil
+	}
+	user.Role = role
+	user.RevokeTokens()
+	err = u.DB.Transaction(func(tx *gorm.DB) error {
+		err := tx.Model(&entity.User{}).Where("id = ?", id).Updates(map[string]interface{}{
+			"role":               user.Role,
+			"tokens_valid_after": user.TokensValidAfter,
+		}).Error
+		if err != nil {
+			return err
+		}
+		return tx.Model(&entity.RefreshToken{}).
+			Where("user_id = ? AND revoked_at IS NULL", user.ID).
+			Update("revoked_at", time.Now()).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+// UpdatePassword saves the password hash of the user along with the time
+// their earlier access tokens stop being valid, and revokes their refresh
+// tokens, so that other sessions have to log in again.
+func (u *User) UpdatePassword(user *entity.User) error {
+	return u.DB.Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&entity.User{}).Where("id = ?", user.ID).Updates(map[string]interface{}{
+			"password":           user.Password,
+			"tokens_valid_after": user.TokensValidAfter,
+		})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return gorm.ErrRecordNotFound
+		}
+		return tx.Model(&entity.RefreshToken{}).
+			Where("user_id = ? AND revoked_at IS NULL", user.ID).
+			Update("revoked_at", time.Now()).Error
+	})
+}
+
+func (u *User) Delete(id string) error {
+	user, err := u.FindById(id)
+	if err != nil {
+		return err
+	}
+	return u.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("user_id = ?", id).Delete(&entity.RefreshToken{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("user_id = ?", id).Delete(&entity.PasswordReset{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("user_id = ?", id).Delete(&entity.EmailVerification{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("user_id = ?", id).Delete(&entity.RecoveryCode{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("user_id = ?", id).Delete(&entity.TOTPSecret{}).Error; err != nil {
+			return err
+		}
+		// login attempts are kept for auditing
+		err := tx.Model(&entity.LoginAttempt{}).Where("user_id = ?", id).Update("user_id", nil).Error
+		if err != nil {
+			return err
+		}
+		return tx.Delete(user).Error
+	})
+}
+
+// checkEmailAvailable fails when another user has the email. The unique
+// index still guards against concurrent requests, see emailTakenError.
+func (u *User) checkEmailAvailable(user *entity.User) error {
+	var taken int64
+	err := u.DB.Model(&entity.User{}).Where("email = ? AND id <> ?", user.Email, user.ID).Count(&taken).Error
+	if err != nil {
+		return err
+	}
+	if taken > 0 {
+		return ErrEmailTaken
+	}
+	return nil
+}
+
+func emailTakenError(err error) error {
+	if errors.Is(err, gorm.ErrDuplicatedKey) {
+		return ErrEmailTaken
+	}
+	return err
+}
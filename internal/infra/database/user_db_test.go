@@ -2,6 +2,7 @@ package database
 
 import (
 	"testing"
+	"time"
 
 	"github.com/brenoproti/go-api/internal/entity"
 	"github.com/stretchr/testify/assert"
@@ -15,7 +16,7 @@ func TestCreateUser(t *testing.T) {
 		t.Error(err)
 	}
 	db.AutoMigrate(&entity.User{})
-	user, _ := entity.NewUser("John", "j@j.com", "12345678")
+	user, _ := entity.NewUser("John", "j@j.com", "Str0ng-Passw0rd")
 	userDb := NewUser(db)
 	err = userDb.Create(user)
 	assert.Nil(t, err)
@@ -34,7 +35,7 @@ func TestFindByEmail(t *testing.T) {
 		t.Error(err)
 	}
 	db.AutoMigrate(&entity.User{})
-	user, _ := entity.NewUser("John", "j@j.com", "12345678")
+	user, _ := entity.NewUser("John", "j@j.com", "Str0ng-Passw0rd")
 	userDb := NewUser(db)
 	err = userDb.Create(user)
 	println(user.ID.String())
@@ -46,3 +47,172 @@ func TestFindByEmail(t *testing.T) {
 	assert.Equal(t, user.Email, userFound.Email)
 	assert.NotEmpty(t, userFound.Password)
 }
+
+func TestFindUserById(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open("file::memory:"), &gorm.Config{})
+	if err != nil {
+		t.Error(err)
+	}
+	db.AutoMigrate(&entity.User{})
+	user, _ := entity.NewUser("John", "j@j.com", "Str0ng-Passw0rd")
+	userDb := NewUser(db)
+	assert.Nil(t, userDb.Create(user))
+	userFound, err := userDb.FindById(user.ID.String())
+	assert.Nil(t, err)
+	assert.Equal(t, user.Email, userFound.Email)
+
+	_, err = userDb.FindById("unknown")
+	assert.ErrorIs(t, err, gorm.ErrRecordNotFound)
+}
+
+func TestFindAllUsers(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open("file::memory:"), &gorm.Config{})
+	if err != nil {
+		t.Error(err)
+	}
+	db.AutoMigrate(&entity.User{})
+	userDb := NewUser(db)
+	for _, name := range []string{"Carol", "Alice", "Bob"} {
+		user, _ := entity.NewUser(name, name+"@j.com", "Str0ng-Passw0rd")
+		assert.Nil(t, userDb.Create(user))
+	}
+	users, err := userDb.FindAll(1, 2)
+	assert.Nil(t, err)
+	assert.Len(t, users, 2)
+	assert.Equal(t, "Alice", users[0].Name)
+	assert.Equal(t, "Bob", users[1].Name)
+	users, err = userDb.FindAll(2, 2)
+	assert.Nil(t, err)
+	assert.Len(t, users, 1)
+	assert.Equal(t, "Carol", users[0].Name)
+	total, err := userDb.Count()
+	assert.Nil(t, err)
+	assert.Equal(t, int64(3), total)
+}
+
+func TestUpdateUser(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open("file::memory:"), &gorm.Config{})
+	if err != nil {
+		t.Error(err)
+	}
+	db.AutoMigrate(&entity.User{})
+	user, _ := entity.NewUser("John", "j@j.com", "Str0ng-Passw0rd")
+	userDb := NewUser(db)
+	assert.Nil(t, userDb.Create(user))
+
+	assert.Nil(t, userDb.Update(&entity.User{ID: user.ID, Name: "Johnny", Email: "johnny@j.com"}))
+	userFound, err := userDb.FindById(user.ID.String())
+	assert.Nil(t, err)
+	assert.Equal(t, "Johnny", userFound.Name)
+	assert.Equal(t, "johnny@j.com", userFound.Email)
+	assert.True(t, userFound.ValidatePassword("Str0ng-Passw0rd"))
+
+	assert.ErrorIs(t, userDb.Update(&entity.User{ID: user.ID, Name: "Johnny"}), entity.ErrEmailIsRequired)
+	unknown, _ := entity.NewUser("Jane", "jane@j.com", "Str0ng-Passw0rd")
+	assert.ErrorIs(t, userDb.Update(unknown), gorm.ErrRecordNotFound)
+}
+
+func TestSetUserRole(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open("file::memory:"), &gorm.Config{})
+	if err != nil {
+		t.Error(err)
+	}
+	db.AutoMigrate(&entity.User{}, &entity.RefreshToken{})
+	user, _ := entity.NewUser("John", "j@j.com", "Str0ng-Passw0rd")
+	userDb := NewUser(db)
+	assert.Nil(t, userDb.Create(user))
+	tokenDb := NewRefreshTokenDB(db)
+	refresh, token, _ := entity.NewRefreshToken(user.ID, nil, time.Hour)
+	assert.Nil(t, tokenDb.Create(refresh))
+
+	updated, err := userDb.SetRole(user.ID.String(), entity.RoleEditor)
+	assert.Nil(t, err)
+	assert.Equal(t, entity.RoleEditor, updated.Role)
+	// the tokens carrying the former role are revoked
+	assert.False(t, updated.AcceptsTokenIssuedAt(time.Now().Add(-time.Minute)))
+	_, _, err = tokenDb.Rotate(token, time.Hour)
+	assert.ErrorIs(t, err, entity.ErrRefreshTokenRevoked)
+	assert.Nil(t, userDb.Update(&entity.User{ID: user.ID, Name: "Johnny", Email: "j@j.com"}))
+	userFound, err := userDb.FindById(user.ID.String())
+	assert.Nil(t, err)
+	assert.Equal(t, entity.RoleEditor, userFound.Role)
+	assert.NotNil(t, userFound.TokensValidAfter)
+
+	_, err = userDb.SetRole(user.ID.String(), "owner")
+	assert.ErrorIs(t, err, entity.ErrInvalidRole)
+	unknown, _ := entity.NewUser("Jane", "jane@j.com", "Str0ng-Passw0rd")
+	_, err = userDb.SetRole(unknown.ID.String(), entity.RoleAdmin)
+	assert.ErrorIs(t, err, gorm.ErrRecordNotFound)
+}
+
+func TestUpdateUserPassword(t *testing.T) {
+	db, user := newRefreshTokenTestDB(t)
+	userDb := NewUser(db)
+	tokenDb := NewRefreshTokenDB(db)
+	refresh, token, _ := entity.NewRefreshToken(user.ID, nil, time.Hour)
+	assert.Nil(t, tokenDb.Create(refresh))
+
+	assert.Nil(t, user.ChangePassword("N3w-Passw0rd"))
+	assert.Nil(t, userDb.UpdatePassword(user))
+	userFound, err := userDb.FindById(user.ID.String())
+	assert.Nil(t, err)
+	assert.True(t, userFound.ValidatePassword("N3w-Passw0rd"))
+	assert.False(t, userFound.ValidatePassword("Str0ng-Passw0rd"))
+	assert.NotNil(t, userFound.TokensValidAfter)
+	assert.False(t, userFound.AcceptsTokenIssuedAt(time.Now().Add(-time.Minute)))
+	_, _, err = tokenDb.Rotate(token, time.Hour)
+	assert.ErrorIs(t, err, entity.ErrRefreshTokenRevoked)
+
+	unknown, _ := entity.NewUser("Jane", "jane@j.com", "Str0ng-Passw0rd")
+	assert.ErrorIs(t, userDb.UpdatePassword(unknown), gorm.ErrRecordNotFound)
+}
+
+func TestDeleteUser(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open("file::memory:"), &gorm.Config{})
+	if err != nil {
+		t.Error(err)
+	}
+	db.AutoMigrate(&entity.User{}, &entity.RefreshToken{}, &entity.PasswordReset{}, &entity.EmailVerification{}, &entity.LoginAttempt{}, &entity.TOTPSecret{}, &entity.RecoveryCode{})
+	user, _ := entity.NewUser("John", "j@j.com", "Str0ng-Passw0rd")
+	userDb := NewUser(db)
+	assert.Nil(t, userDb.Create(user))
+	attempt, _ := entity.NewLoginAttempt(user.Email, "10.0.0.1", "", &user.ID, entity.LoginSucceeded)
+	assert.Nil(t, db.Create(attempt).Error)
+	assert.Nil(t, userDb.Delete(user.ID.String()))
+	_, err = userDb.FindById(user.ID.String())
+	assert.ErrorIs(t, err, gorm.ErrRecordNotFound)
+	// login attempts are kept for auditing
+	var kept entity.LoginAttempt
+	assert.Nil(t, db.First(&kept, "id = ?", attempt.ID).Error)
+	assert.Nil(t, kept.UserID)
+	assert.ErrorIs(t, userDb.Delete(user.ID.String()), gorm.ErrRecordNotFound)
+}
+
+func TestUserEmailsAreUnique(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open("file::memory:"), &gorm.Config{TranslateError: true})
+	if err != nil {
+		t.Error(err)
+	}
+	db.AutoMigrate(&entity.User{})
+	userDb := NewUser(db)
+	john, _ := entity.NewUser("John", " J@J.com ", "Str0ng-Passw0rd")
+	assert.Equal(t, "j@j.com", john.Email)
+	assert.Nil(t, userDb.Create(john))
+
+	duplicate, _ := entity.NewUser("Johnny", "j@J.COM", "Str0ng-Passw0rd")
+	assert.ErrorIs(t, userDb.Create(duplicate), ErrEmailTaken)
+	userFound, err := userDb.FindByEmail("  J@j.com")
+	assert.Nil(t, err)
+	assert.Equal(t, john.ID, userFound.ID)
+
+	jane, _ := entity.NewUser("Jane", "jane@j.com", "Str0ng-Passw0rd")
+	assert.Nil(t, userDb.Create(jane))
+	jane.Email = "J@j.com"
+	assert.ErrorIs(t, userDb.Update(jane), ErrEmailTaken)
+	jane.Email = "JANE@j.com"
+	assert.Nil(t, userDb.Update(jane))
+
+	// the unique index catches what the check cannot, e.g. concurrent creates
+	jane2, _ := entity.NewUser("Jane", "jane@j.com", "Str0ng-Passw0rd")
+	assert.ErrorIs(t, emailTakenError(db.Create(jane2).Error), ErrEmailTaken)
+}
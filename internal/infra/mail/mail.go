@@ -0,0 +1,73 @@
+package mail
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+var (
+	ErrDriverIsRequired  = errors.New("mail driver is required")
+	ErrUnsupportedDriver = errors.New("unsupported mail driver")
+	ErrInvalidHeader     = errors.New("mail headers cannot contain line breaks")
+)
+
+// Message is a plain text email.
+type Message struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+// Mailer sends emails, for real through SMTP or to an Outbox during
+// development and tests.
+type Mailer interface {
+	Send(msg Message) error
+}
+
+// Config selects and configures a Mailer. Driver is either smtp or outbox,
+// in which case OutboxDir is where messages are written, if anywhere.
+type Config struct {
+	Driver    string
+	Host      string
+	Port      string
+	User      string
+	Password  string
+	From      string
+	OutboxDir string
+}
+
+// New returns the Mailer matching the configured driver.
+func New(c Config) (Mailer, error) {
+	switch c.Driver {
+	case "smtp":
+		return NewSMTPMailer(c.Host, c.Port, c.User, c.Password, c.From), nil
+	case "outbox":
+		return NewOutbox(c.OutboxDir, c.From), nil
+	case "":
+		return nil, ErrDriverIsRequired
+	default:
+		return nil, fmt.Errorf("%w: %q", ErrUnsupportedDriver, c.Driver)
+	}
+}
+
+// format renders the message as sent over SMTP.
+func format(from string, msg Message, date time.Time) ([]byte, error) {
+	for _, header := range []string{from, msg.To, msg.Subject} {
+		if strings.ContainsAny(header, "\r\n") {
+			return nil, ErrInvalidHeader
+		}
+	}
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "From: %s\r\n", from)
+	fmt.Fprintf(&b, "To: %s\r\n", msg.To)
+	fmt.Fprintf(&b, "Subject: %s\r\n", msg.Subject)
+	fmt.Fprintf(&b, "Date: %s\r\n", date.Format(time.RFC1123Z))
+	b.WriteString("MIME-Version: 1.0\r\n")
+	b.WriteString("Content-Type: text/plain; charset=UTF-8\r\n")
+	b.WriteString("\r\n")
+	b.WriteString(strings.ReplaceAll(strings.ReplaceAll(msg.Body, "\r\n", "\n"), "\n", "\r\n"))
+	return b.Bytes(), nil
+}
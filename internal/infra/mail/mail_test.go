@@ -0,0 +1,66 @@
+package mail
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNew(t *testing.T) {
+	mailer, err := New(Config{Driver: "smtp", Host: "localhost", Port: "25", From: "no-reply@example.com"})
+	assert.NoError(t, err)
+	assert.IsType(t, &SMTPMailer{}, mailer)
+	mailer, err = New(Config{Driver: "outbox"})
+	assert.NoError(t, err)
+	assert.IsType(t, &Outbox{}, mailer)
+	_, err = New(Config{})
+	assert.ErrorIs(t, err, ErrDriverIsRequired)
+	_, err = New(Config{Driver: "pigeon"})
+	assert.ErrorIs(t, err, ErrUnsupportedDriver)
+}
+
+func TestFormat(t *testing.T) {
+	date := time.Date(2023, 11, 12, 10, 0, 0, 0, time.UTC)
+	body, err := format("no-reply@example.com", Message{To: "j@j.com", Subject: "Hi", Body: "line 1\nline 2"}, date)
+	assert.NoError(t, err)
+	assert.Equal(t, "From: no-reply@example.com\r\n"+
+		"To: j@j.com\r\n"+
+		"Subject: Hi\r\n"+
+		"Date: Sun, 12 Nov 2023 10:00:00 +0000\r\n"+
+		"MIME-Version: 1.0\r\n"+
+		"Content-Type: text/plain; charset=UTF-8\r\n"+
+		"\r\n"+
+		"line 1\r\nline 2", string(body))
+
+	_, err = format("no-reply@example.com", Message{To: "j@j.com\r\nBcc: x@x.com", Subject: "Hi"}, date)
+	assert.ErrorIs(t, err, ErrInvalidHeader)
+}
+
+func TestOutbox(t *testing.T) {
+	dir := t.TempDir()
+	outbox := NewOutbox(dir, "no-reply@example.com")
+	assert.NoError(t, outbox.Send(Message{To: "j@j.com", Subject: "First", Body: "1"}))
+	assert.NoError(t, outbox.Send(Message{To: "k@k.com", Subject: "Other", Body: "2"}))
+	assert.NoError(t, outbox.Send(Message{To: "j@j.com", Subject: "Second", Body: "3"}))
+	assert.Len(t, outbox.Messages(), 3)
+
+	last, ok := outbox.Last("j@j.com")
+	assert.True(t, ok)
+	assert.Equal(t, "Second", last.Subject)
+	_, ok = outbox.Last("nobody@j.com")
+	assert.False(t, ok)
+
+	files, err := filepath.Glob(filepath.Join(dir, "*.eml"))
+	assert.NoError(t, err)
+	assert.Len(t, files, 3)
+	content, err := os.ReadFile(files[0])
+	assert.NoError(t, err)
+	assert.True(t, strings.Contains(string(content), "Subject: First"))
+
+	assert.ErrorIs(t, outbox.Send(Message{To: "j@j.com", Subject: "a\nb"}), ErrInvalidHeader)
+	assert.Len(t, outbox.Messages(), 3)
+}
@@ -0,0 +1,67 @@
+package mail
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// Outbox keeps the emails instead of sending them, so that they can be read
+// during development or checked by tests. When Dir is set, every message is
+// also written there as an .eml file.
+type Outbox struct {
+	Dir  string
+	From string
+
+	mu       sync.Mutex
+	messages []Message
+}
+
+func NewOutbox(dir, from string) *Outbox {
+	return &Outbox{
+		Dir:  dir,
+		From: from,
+	}
+}
+
+func (o *Outbox) Send(msg Message) error {
+	now := time.Now()
+	body, err := format(o.From, msg, now)
+	if err != nil {
+		return err
+	}
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if o.Dir != "" {
+		if err := os.MkdirAll(o.Dir, 0o755); err != nil {
+			return err
+		}
+		name := fmt.Sprintf("%s-%03d.eml", now.UTC().Format("20060102T150405.000000000"), len(o.messages))
+		if err := os.WriteFile(filepath.Join(o.Dir, name), body, 0o644); err != nil {
+			return err
+		}
+	}
+	o.messages = append(o.messages, msg)
+	return nil
+}
+
+// Messages returns the emails sent so far, oldest first.
+func (o *Outbox) Messages() []Message {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	return append([]Message(nil), o.messages...)
+}
+
+// Last returns the most recent email sent to the address, if any.
+func (o *Outbox) Last(to string) (Message, bool) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	for i := len(o.messages) - 1; i >= 0; i-- {
+		if o.messages[i].To == to {
+			return o.messages[i], true
+		}
+	}
+	return Message{}, false
+}
@@ -0,0 +1,191 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/brenoproti/go-api/internal/dto"
+	"github.com/brenoproti/go-api/internal/entity"
+	"github.com/brenoproti/go-api/internal/infra/database"
+	pkg "github.com/brenoproti/go-api/pkg/entity"
+	"github.com/go-chi/chi"
+)
+
+type CategoryHandler struct {
+	CategoryDB database.CategoryInterface
+}
+
+func NewCategoryHandler(db database.CategoryInterface) *CategoryHandler {
+	return &CategoryHandler{
+		CategoryDB: db,
+	}
+}
+
+// Create category godoc
+// @Summary Create a new category
+// @Description Create a new category, optionally nested under a parent
+// @Tags categories
+// @Accept  json
+// @Produce  json
+// @Param request body dto.CategoryDTO true "Category info"
+// @Success 201 {string} string	"Category created"
+// @Failure 400 {object} dto.ProblemDTO "Bad request"
+// @Failure 401 {object} dto.ProblemDTO "Unauthorized"
+// @Failure 403 {object} dto.ProblemDTO "Forbidden"
+// @Failure 500 {object} dto.ProblemDTO "Internal server error"
+// @Router /categories [post]
+// @Security ApiKeyAuth
+func (h *CategoryHandler) Create(w http.ResponseWriter, r *http.Request) {
+	var category dto.CategoryDTO
+	err := decode(r, &category)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	parentID, err := parseParentID(category.ParentID)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	entity, err := entity.NewCategory(category.Name, parentID)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	err = h.CategoryDB.Create(entity)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	w.Header().Add("id", entity.ID.String())
+	w.WriteHeader(http.StatusCreated)
+}
+
+// FindById category godoc
+// @Summary Find a category by ID
+// @Description Find a category by ID
+// @Tags categories
+// @Accept  json
+// @Produce  json
+// @Param id path string true "Category ID"
+// @Success 200 {object} entity.Category
+// @Failure 404 {object} dto.ProblemDTO "Not found"
+// @Router /categories/{id} [get]
+// @Security ApiKeyAuth
+func (h *CategoryHandler) FindById(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+	category, err := h.CategoryDB.FindById(id)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(category)
+}
+
+// GetCategories godoc
+// @Summary List categories
+// @Description List categories as a flat list, or nested under their parents when tree is true
+// @Tags categories
+// @Accept  json
+// @Produce  json
+// @Param tree query bool false "Return categories as a tree"
+// @Success 200 {array} entity.Category
+// @Failure 500 {object} dto.ProblemDTO "Internal server error"
+// @Router /categories [get]
+// @Security ApiKeyAuth
+func (h *CategoryHandler) GetCategories(w http.ResponseWriter, r *http.Request) {
+	categories, err := h.CategoryDB.FindAll()
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	if r.URL.Query().Get("tree") == "true" {
+		json.NewEncoder(w).Encode(entity.CategoryTree(categories))
+		return
+	}
+	json.NewEncoder(w).Encode(categories)
+}
+
+// Update category godoc
+// @Summary Update a category
+// @Description Rename a category or move it under another parent
+// @Tags categories
+// @Accept  json
+// @Produce  json
+// @Param id path string true "Category ID"
+// @Param request body dto.CategoryDTO true "Category info"
+// @Success 200 {string} string	"Category updated"
+// @Failure 400 {object} dto.ProblemDTO "Bad request"
+// @Failure 403 {object} dto.ProblemDTO "Forbidden"
+// @Failure 404 {object} dto.ProblemDTO "Not found"
+// @Failure 500 {object} dto.ProblemDTO "Internal server error"
+// @Router /categories/{id} [put]
+// @Security ApiKeyAuth
+func (h *CategoryHandler) Update(w http.ResponseWriter, r *http.Request) {
+	var category dto.CategoryDTO
+	err := decode(r, &category)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	id, err := pkg.ParseID(chi.URLParam(r, "id"))
+	if err != nil {
+		writeError(w, r, entity.ErrInvalidId)
+		return
+	}
+	parentID, err := parseParentID(category.ParentID)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	entity := &entity.Category{ID: id, Name: category.Name, ParentID: parentID}
+	if err := entity.Validate(); err != nil {
+		writeError(w, r, err)
+		return
+	}
+	err = h.CategoryDB.Update(entity)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+}
+
+// Delete category godoc
+// @Summary Delete a category
+// @Description Delete a category without subcategories
+// @Tags categories
+// @Accept  json
+// @Produce  json
+// @Param id path string true "Category ID"
+// @Success 200 {string} string	"Category deleted"
+// @Failure 403 {object} dto.ProblemDTO "Forbidden"
+// @Failure 404 {object} dto.ProblemDTO "Not found"
+// @Failure 409 {object} dto.ProblemDTO "Category has subcategories"
+// @Failure 500 {object} dto.ProblemDTO "Internal server error"
+// @Router /categories/{id} [delete]
+// @Security ApiKeyAuth
+func (h *CategoryHandler) Delete(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+	err := h.CategoryDB.Delete(id)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+}
+
+func parseParentID(parentID *string) (*pkg.ID, error) {
+	if parentID == nil || *parentID == "" {
+		return nil, nil
+	}
+	id, err := pkg.ParseID(*parentID)
+	if err != nil {
+		return nil, entity.ErrInvalidParent
+	}
+	return &id, nil
+}
@@ -0,0 +1,44 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/brenoproti/go-api/internal/dto"
+)
+
+// validationError carries every invalid field of a request body.
+type validationError struct {
+	fields []dto.FieldErrorDTO
+}
+
+func (e *validationError) Error() string {
+	return fmt.Sprintf("%d invalid fields", len(e.fields))
+}
+
+// decode reads the JSON body of the request into v and validates it against
+// its validate tags, reporting all the invalid fields at once.
+func decode(r *http.Request, v interface{}) error {
+	return decodeBody(r, v, false)
+}
+
+// decodeStrict is like decode but also rejects fields v does not have.
+func decodeStrict(r *http.Request, v interface{}) error {
+	return decodeBody(r, v, true)
+}
+
+func decodeBody(r *http.Request, v interface{}, strict bool) error {
+	defer r.Body.Close()
+	decoder := json.NewDecoder(r.Body)
+	if strict {
+		decoder.DisallowUnknownFields()
+	}
+	if err := decoder.Decode(v); err != nil {
+		return err
+	}
+	if fields := dto.Validate(v); len(fields) > 0 {
+		return &validationError{fields: fields}
+	}
+	return nil
+}
@@ -0,0 +1,151 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/brenoproti/go-api/internal/dto"
+	"github.com/brenoproti/go-api/internal/entity"
+	"github.com/brenoproti/go-api/internal/infra/database"
+	"github.com/brenoproti/go-api/internal/infra/mail"
+	"gorm.io/gorm"
+)
+
+var errEmailNotVerified = errors.New("email is not verified")
+
+type EmailVerificationHandler struct {
+	UserDB              database.UserInterface
+	EmailVerificationDB database.EmailVerificationInterface
+	// LoginAttemptDB and LoginThrottle throttle the verifications asked for
+	// again like logins.
+	LoginAttemptDB database.LoginAttemptInterface
+	LoginThrottle  entity.LoginThrottle
+	Mailer         mail.Mailer
+	BaseURL        string
+	ExpiresIn      int
+}
+
+func NewEmailVerificationHandler(userDB database.UserInterface, emailVerificationDB database.EmailVerificationInterface, loginAttemptDB database.LoginAttemptInterface, loginThrottle entity.LoginThrottle, mailer mail.Mailer, baseURL string, expiresIn int) *EmailVerificationHandler {
+	return &EmailVerificationHandler{
+		UserDB:              userDB,
+		EmailVerificationDB: emailVerificationDB,
+		LoginAttemptDB:      loginAttemptDB,
+		LoginThrottle:       loginThrottle,
+		Mailer:              mailer,
+		BaseURL:             baseURL,
+		ExpiresIn:           expiresIn,
+	}
+}
+
+// Verify email godoc
+// @Summary Verify an email
+// @Description Verify the email of an account with the token emailed on signup or when the email changed
+// @Tags users
+// @Accept  json
+// @Produce  json
+// @Param token query string true "Verification token"
+// @Success 200 {object} entity.User
+// @Failure 400 {object} dto.ProblemDTO "Bad request"
+// @Failure 500 {object} dto.ProblemDTO "Internal server error"
+// @Router /users/verify [get]
+func (h *EmailVerificationHandler) Verify(w http.ResponseWriter, r *http.Request) {
+	token := r.URL.Query().Get("token")
+	if token == "" {
+		writeError(w, r, entity.ErrInvalidVerificationToken)
+		return
+	}
+	user, err := h.EmailVerificationDB.Verify(token)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(user)
+}
+
+// Resend verification godoc
+// @Summary Resend the verification email
+// @Description Email a new verification token to an account whose email is not verified yet. The response is the
+// @Description same whether the account exists or not, and is sent before looking the account up, so that it does not
+// @Description reveal which emails are registered. Asking again with the same email or from the same IP address has
+// @Description to wait longer and longer, like failed logins.
+// @Tags users
+// @Accept  json
+// @Produce  json
+// @Param request body dto.ResendVerificationDTO true "Email of the account"
+// @Success 202 {string} string "Verification sent if the account needs one"
+// @Failure 400 {object} dto.ProblemDTO "Bad request"
+// @Failure 429 {object} dto.ProblemDTO "Too many verifications asked for, retry after the number of seconds in the Retry-After header"
+// @Header 429 {integer} Retry-After "Seconds to wait before trying again"
+// @Failure 500 {object} dto.ProblemDTO "Internal server error"
+// @Router /users/verify/resend [post]
+func (h *EmailVerificationHandler) Resend(w http.ResponseWriter, r *http.Request) {
+	var request dto.ResendVerificationDTO
+	err := decode(r, &request)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	attempt, wait, err := reserveAttempt(h.LoginAttemptDB, h.LoginThrottle, r, request.Email, nil, entity.LoginVerifyRequested)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	if wait > 0 {
+		writeThrottled(w, r, h.LoginAttemptDB, attempt, wait, errTooManyEmails)
+		return
+	}
+	inBackground("resending email verification", func() error {
+		return h.resend(request.Email)
+	})
+	w.WriteHeader(http.StatusAccepted)
+}
+
+// resend emails a new verification token to the user with the email, if any
+// and not verified yet.
+func (h *EmailVerificationHandler) resend(email string) error {
+	user, err := h.UserDB.FindByEmail(email)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if user.IsEmailVerified() {
+		return nil
+	}
+	return h.Send(user)
+}
+
+// Send emails a new verification token to the user. Failing to deliver it is
+// only logged, the user can ask for another one.
+func (h *EmailVerificationHandler) Send(user *entity.User) error {
+	ttl := time.Second * time.Duration(h.ExpiresIn)
+	verification, token, err := entity.NewEmailVerification(user, ttl)
+	if err != nil {
+		return err
+	}
+	if err := h.EmailVerificationDB.Create(verification); err != nil {
+		return err
+	}
+	link := h.BaseURL + "/users/verify?token=" + url.QueryEscape(token)
+	err = h.Mailer.Send(mail.Message{
+		To:      user.Email,
+		Subject: "Verify your email",
+		Body: fmt.Sprintf("Hi %s,\n\n"+
+			"Open this link within %d hours to verify your email:\n\n"+
+			"%s\n\n"+
+			"If you did not create an account, ignore this email.\n",
+			user.Name, int(ttl.Hours()), link),
+	})
+	if err != nil {
+		log.Printf("sending email verification to %s: %v", user.Email, err)
+	}
+	return nil
+}
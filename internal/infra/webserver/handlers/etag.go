@@ -0,0 +1,47 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/brenoproti/go-api/internal/entity"
+)
+
+func productETag(product *entity.Product) string {
+	return `"` + strconv.FormatInt(product.Version, 10) + `"`
+}
+
+// etagMatches reports whether an If-Match or If-None-Match header lists the
+// entity tag, using the strong comparison for If-Match and the weak one for
+// If-None-Match as RFC 9110 requires.
+func etagMatches(header, etag string, weak bool) bool {
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" {
+			return true
+		}
+		if weak {
+			candidate = strings.TrimPrefix(candidate, "W/")
+		}
+		if candidate == etag {
+			return true
+		}
+	}
+	return false
+}
+
+// checkIfMatch compares the If-Match header of the request with the current
+// version of the product. It returns the version the change must apply to,
+// zero when the request has no precondition, or false when the precondition
+// failed.
+func checkIfMatch(r *http.Request, product *entity.Product) (int64, bool) {
+	header := r.Header.Get("If-Match")
+	if header == "" {
+		return 0, true
+	}
+	if !etagMatches(header, productETag(product), false) {
+		return 0, false
+	}
+	return product.Version, true
+}
@@ -0,0 +1,268 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/brenoproti/go-api/internal/dto"
+	"github.com/brenoproti/go-api/internal/entity"
+	"github.com/brenoproti/go-api/internal/infra/database"
+	"github.com/go-chi/chi"
+)
+
+type InventoryHandler struct {
+	InventoryDB database.InventoryInterface
+	// ProductDB finds who owns the products whose stock changes.
+	ProductDB      database.ProductInterface
+	ReservationTTL int
+	// MaxReservationTTL is the longest, in seconds, clients can hold stock
+	// for, so that a single reservation cannot take it off sale for good.
+	MaxReservationTTL int
+}
+
+func NewInventoryHandler(db database.InventoryInterface, productDB database.ProductInterface, reservationTTL, maxReservationTTL int) *InventoryHandler {
+	return &InventoryHandler{
+		InventoryDB:       db,
+		ProductDB:         productDB,
+		ReservationTTL:    reservationTTL,
+		MaxReservationTTL: maxReservationTTL,
+	}
+}
+
+// GetStock godoc
+// @Summary Get the stock of a product
+// @Description Get the quantity on hand, reserved and available of a product
+// @Tags inventory
+// @Accept  json
+// @Produce  json
+// @Param id path string true "Product ID"
+// @Success 200 {object} entity.Stock
+// @Failure 404 {object} dto.ProblemDTO "Not found"
+// @Failure 500 {object} dto.ProblemDTO "Internal server error"
+// @Router /products/{id}/stock [get]
+// @Security ApiKeyAuth
+func (h *InventoryHandler) GetStock(w http.ResponseWriter, r *http.Request) {
+	stock, err := h.InventoryDB.FindStock(chi.URLParam(r, "id"))
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(stock)
+}
+
+// Increment godoc
+// @Summary Increment the stock of a product
+// @Description Add a quantity to the stock of a product. Editors can only change the stock of their own products.
+// @Tags inventory
+// @Accept  json
+// @Produce  json
+// @Param id path string true "Product ID"
+// @Param request body dto.StockDTO true "Quantity"
+// @Success 200 {object} entity.Stock
+// @Failure 400 {object} dto.ProblemDTO "Bad request"
+// @Failure 403 {object} dto.ProblemDTO "Forbidden"
+// @Failure 404 {object} dto.ProblemDTO "Not found"
+// @Failure 500 {object} dto.ProblemDTO "Internal server error"
+// @Router /products/{id}/stock/increment [post]
+// @Security ApiKeyAuth
+func (h *InventoryHandler) Increment(w http.ResponseWriter, r *http.Request) {
+	h.changeStock(w, r, h.InventoryDB.Increment)
+}
+
+// Decrement godoc
+// @Summary Decrement the stock of a product
+// @Description Remove a quantity from the available stock of a product. Editors can only change the stock of their own products.
+// @Tags inventory
+// @Accept  json
+// @Produce  json
+// @Param id path string true "Product ID"
+// @Param request body dto.StockDTO true "Quantity"
+// @Success 200 {object} entity.Stock
+// @Failure 400 {object} dto.ProblemDTO "Bad request"
+// @Failure 403 {object} dto.ProblemDTO "Forbidden"
+// @Failure 404 {object} dto.ProblemDTO "Not found"
+// @Failure 409 {object} dto.ProblemDTO "Insufficient stock"
+// @Failure 500 {object} dto.ProblemDTO "Internal server error"
+// @Router /products/{id}/stock/decrement [post]
+// @Security ApiKeyAuth
+func (h *InventoryHandler) Decrement(w http.ResponseWriter, r *http.Request) {
+	h.changeStock(w, r, h.InventoryDB.Decrement)
+}
+
+func (h *InventoryHandler) changeStock(w http.ResponseWriter, r *http.Request, change func(string, int) (*entity.Stock, error)) {
+	var quantity dto.StockDTO
+	err := decode(r, &quantity)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	product, err := h.authorizeStockChange(r, chi.URLParam(r, "id"))
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	stock, err := change(product.ID.String(), quantity.Quantity)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(stock)
+}
+
+// Reserve godoc
+// @Summary Reserve stock of a product
+// @Description Hold a quantity of a product until the reservation expires, is released or is committed. The time to
+// @Description live cannot exceed the configured maximum. Editors can only reserve the stock of their own products.
+// @Tags inventory
+// @Accept  json
+// @Produce  json
+// @Param id path string true "Product ID"
+// @Param request body dto.ReservationDTO true "Quantity and time to live in seconds"
+// @Success 201 {object} entity.Reservation
+// @Failure 400 {object} dto.ProblemDTO "Bad request"
+// @Failure 403 {object} dto.ProblemDTO "Forbidden"
+// @Failure 404 {object} dto.ProblemDTO "Not found"
+// @Failure 409 {object} dto.ProblemDTO "Insufficient stock"
+// @Failure 500 {object} dto.ProblemDTO "Internal server error"
+// @Router /products/{id}/reservations [post]
+// @Security ApiKeyAuth
+func (h *InventoryHandler) Reserve(w http.ResponseWriter, r *http.Request) {
+	var reservation dto.ReservationDTO
+	err := decode(r, &reservation)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	ttl := reservation.TTL
+	if ttl == 0 {
+		ttl = h.ReservationTTL
+	}
+	if ttl > h.MaxReservationTTL {
+		writeError(w, r, errReservationTooLong)
+		return
+	}
+	product, err := h.authorizeStockChange(r, chi.URLParam(r, "id"))
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	entity, err := h.InventoryDB.Reserve(product.ID.String(), reservation.Quantity, time.Second*time.Duration(ttl))
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Add("id", entity.ID.String())
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(entity)
+}
+
+// FindReservation godoc
+// @Summary Find a reservation by ID
+// @Description Find a reservation by ID
+// @Tags inventory
+// @Accept  json
+// @Produce  json
+// @Param id path string true "Reservation ID"
+// @Success 200 {object} entity.Reservation
+// @Failure 404 {object} dto.ProblemDTO "Not found"
+// @Router /reservations/{id} [get]
+// @Security ApiKeyAuth
+func (h *InventoryHandler) FindReservation(w http.ResponseWriter, r *http.Request) {
+	reservation, err := h.InventoryDB.FindReservation(chi.URLParam(r, "id"))
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(reservation)
+}
+
+// Release godoc
+// @Summary Release a reservation
+// @Description Give the reserved quantity back to the available stock. Editors can only release the reservations of their own products.
+// @Tags inventory
+// @Accept  json
+// @Produce  json
+// @Param id path string true "Reservation ID"
+// @Success 200 {string} string "Reservation released"
+// @Failure 403 {object} dto.ProblemDTO "Forbidden"
+// @Failure 404 {object} dto.ProblemDTO "Not found"
+// @Failure 500 {object} dto.ProblemDTO "Internal server error"
+// @Router /reservations/{id} [delete]
+// @Security ApiKeyAuth
+func (h *InventoryHandler) Release(w http.ResponseWriter, r *http.Request) {
+	reservation, err := h.authorizeReservation(r)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	err = h.InventoryDB.Release(reservation.ID.String())
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+}
+
+// Commit godoc
+// @Summary Commit a reservation
+// @Description Turn a reservation into a definitive decrement of the stock. Editors can only commit the reservations of their own products.
+// @Tags inventory
+// @Accept  json
+// @Produce  json
+// @Param id path string true "Reservation ID"
+// @Success 200 {object} entity.Stock
+// @Failure 403 {object} dto.ProblemDTO "Forbidden"
+// @Failure 404 {object} dto.ProblemDTO "Not found"
+// @Failure 409 {object} dto.ProblemDTO "Reservation expired"
+// @Failure 500 {object} dto.ProblemDTO "Internal server error"
+// @Router /reservations/{id}/commit [post]
+// @Security ApiKeyAuth
+func (h *InventoryHandler) Commit(w http.ResponseWriter, r *http.Request) {
+	reservation, err := h.authorizeReservation(r)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	stock, err := h.InventoryDB.Commit(reservation.ID.String())
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(stock)
+}
+
+// authorizeStockChange finds the product, provided the user making the
+// request owns it or is an admin.
+func (h *InventoryHandler) authorizeStockChange(r *http.Request, productID string) (*entity.Product, error) {
+	product, err := h.ProductDB.FindById(productID)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := authorizeProductChange(r, product); err != nil {
+		return nil, err
+	}
+	return product, nil
+}
+
+// authorizeReservation finds the reservation in the URL, provided the user
+// making the request may change the stock of its product.
+func (h *InventoryHandler) authorizeReservation(r *http.Request) (*entity.Reservation, error) {
+	reservation, err := h.InventoryDB.FindReservation(chi.URLParam(r, "id"))
+	if err != nil {
+		return nil, err
+	}
+	if _, err := h.authorizeStockChange(r, reservation.ProductID.String()); err != nil {
+		return nil, err
+	}
+	return reservation, nil
+}
@@ -0,0 +1,138 @@
+package handlers
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/brenoproti/go-api/internal/entity"
+	"github.com/brenoproti/go-api/internal/infra/database"
+	pkg "github.com/brenoproti/go-api/pkg/entity"
+	"github.com/go-chi/chi"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+func TestReserveCapsTTL(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open("file::memory:"), &gorm.Config{})
+	assert.Nil(t, err)
+	db.AutoMigrate(&entity.Product{}, &entity.Stock{}, &entity.Reservation{})
+	owner := pkg.NewID()
+	product, err := entity.NewProduct("Product 1", pkg.NewMoney(1050, "USD"))
+	assert.Nil(t, err)
+	product.CreatedBy = &owner
+	assert.Nil(t, database.NewProductDB(db).Create(product))
+	inventoryDB := database.NewInventoryDB(db)
+	_, err = inventoryDB.Increment(product.ID.String(), 10)
+	assert.Nil(t, err)
+
+	keys := newTestKeys(t)
+	h := NewInventoryHandler(inventoryDB, database.NewProductDB(db), 900, 3600)
+	r := chi.NewRouter()
+	r.Use(Verifier(keys), Authenticator)
+	r.Post("/products/{id}/reservations", h.Reserve)
+	path := "/products/" + product.ID.String() + "/reservations"
+	token := newTestToken(t, keys, owner.String(), entity.RoleEditor)
+
+	w := serve(r, http.MethodPost, path, token, map[string]int{"quantity": 10, "ttl": 999999999})
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Contains(t, w.Body.String(), `"field":"ttl"`)
+	stock, err := inventoryDB.FindStock(product.ID.String())
+	assert.Nil(t, err)
+	assert.Equal(t, 10, stock.Available)
+
+	for _, ttl := range []int{0, 3600} {
+		w = serve(r, http.MethodPost, path, token, map[string]int{"quantity": 1, "ttl": ttl})
+		assert.Equal(t, http.StatusCreated, w.Code, ttl)
+	}
+}
+
+func TestChangeStockRequiresOwner(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open("file::memory:"), &gorm.Config{})
+	assert.Nil(t, err)
+	db.AutoMigrate(&entity.Product{}, &entity.Stock{}, &entity.Reservation{})
+	owner := pkg.NewID()
+	product, err := entity.NewProduct("Product 1", pkg.NewMoney(1050, "USD"))
+	assert.Nil(t, err)
+	product.CreatedBy = &owner
+	assert.Nil(t, database.NewProductDB(db).Create(product))
+	inventoryDB := database.NewInventoryDB(db)
+
+	keys := newTestKeys(t)
+	h := NewInventoryHandler(inventoryDB, database.NewProductDB(db), 900, 3600)
+	r := chi.NewRouter()
+	r.Use(Verifier(keys), Authenticator)
+	r.Post("/products/{id}/stock/increment", h.Increment)
+	r.Post("/products/{id}/stock/decrement", h.Decrement)
+	path := "/products/" + product.ID.String() + "/stock/"
+
+	other := newTestToken(t, keys, pkg.NewID().String(), entity.RoleEditor)
+	for _, change := range []string{"increment", "decrement"} {
+		w := serve(r, http.MethodPost, path+change, other, map[string]int{"quantity": 1})
+		assert.Equal(t, http.StatusForbidden, w.Code, change)
+	}
+	stock, err := inventoryDB.FindStock(product.ID.String())
+	assert.Nil(t, err)
+	assert.Equal(t, 0, stock.Quantity)
+
+	w := serve(r, http.MethodPost, path+"increment", newTestToken(t, keys, owner.String(), entity.RoleEditor), map[string]int{"quantity": 2})
+	assert.Equal(t, http.StatusOK, w.Code)
+	w = serve(r, http.MethodPost, path+"decrement", newTestToken(t, keys, pkg.NewID().String(), entity.RoleAdmin), map[string]int{"quantity": 1})
+	assert.Equal(t, http.StatusOK, w.Code)
+	stock, err = inventoryDB.FindStock(product.ID.String())
+	assert.Nil(t, err)
+	assert.Equal(t, 1, stock.Quantity)
+
+	w = serve(r, http.MethodPost, "/products/"+pkg.NewID().String()+"/stock/increment", other, map[string]int{"quantity": 1})
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
+func TestReservationsRequireOwner(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open("file::memory:"), &gorm.Config{})
+	assert.Nil(t, err)
+	db.AutoMigrate(&entity.Product{}, &entity.Stock{}, &entity.Reservation{})
+	owner := pkg.NewID()
+	product, err := entity.NewProduct("Product 1", pkg.NewMoney(1050, "USD"))
+	assert.Nil(t, err)
+	product.CreatedBy = &owner
+	assert.Nil(t, database.NewProductDB(db).Create(product))
+	inventoryDB := database.NewInventoryDB(db)
+	_, err = inventoryDB.Increment(product.ID.String(), 10)
+	assert.Nil(t, err)
+	reservation, err := inventoryDB.Reserve(product.ID.String(), 2, time.Minute)
+	assert.Nil(t, err)
+
+	keys := newTestKeys(t)
+	h := NewInventoryHandler(inventoryDB, database.NewProductDB(db), 900, 3600)
+	r := chi.NewRouter()
+	r.Use(Verifier(keys), Authenticator)
+	r.Post("/products/{id}/reservations", h.Reserve)
+	r.Delete("/reservations/{id}", h.Release)
+	r.Post("/reservations/{id}/commit", h.Commit)
+	reservationPath := "/reservations/" + reservation.ID.String()
+
+	other := newTestToken(t, keys, pkg.NewID().String(), entity.RoleEditor)
+	w := serve(r, http.MethodPost, "/products/"+product.ID.String()+"/reservations", other, map[string]int{"quantity": 1})
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	w = serve(r, http.MethodDelete, reservationPath, other, nil)
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	w = serve(r, http.MethodPost, reservationPath+"/commit", other, nil)
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	stock, err := inventoryDB.FindStock(product.ID.String())
+	assert.Nil(t, err)
+	assert.Equal(t, 10, stock.Quantity)
+	assert.Equal(t, 2, stock.Reserved)
+
+	w = serve(r, http.MethodPost, reservationPath+"/commit", newTestToken(t, keys, owner.String(), entity.RoleEditor), nil)
+	assert.Equal(t, http.StatusOK, w.Code)
+	stock, err = inventoryDB.FindStock(product.ID.String())
+	assert.Nil(t, err)
+	assert.Equal(t, 8, stock.Quantity)
+
+	// admins can release anyone's
+	another, err := inventoryDB.Reserve(product.ID.String(), 1, time.Minute)
+	assert.Nil(t, err)
+	w = serve(r, http.MethodDelete, "/reservations/"+another.ID.String(), newTestToken(t, keys, pkg.NewID().String(), entity.RoleAdmin), nil)
+	assert.Equal(t, http.StatusOK, w.Code)
+}
@@ -0,0 +1,46 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/brenoproti/go-api/pkg/jwtkeys"
+)
+
+type JWKSHandler struct {
+	Keys *jwtkeys.KeySet
+	// MaxAge is how many seconds verifiers may cache the key set for.
+	MaxAge int
+}
+
+func NewJWKSHandler(keys *jwtkeys.KeySet, maxAge int) *JWKSHandler {
+	return &JWKSHandler{
+		Keys:   keys,
+		MaxAge: maxAge,
+	}
+}
+
+// GetJWKS godoc
+// @Summary Get the keys tokens are signed with
+// @Description Publish the public keys access tokens are signed with as a JSON Web Key Set, so that other services can
+// @Description verify the tokens on their own with the key named by their kid header. New keys are listed before they
+// @Description sign any token, and replaced keys until the tokens they signed expire. The set is empty while tokens are
+// @Description signed with a shared secret.
+// @Tags users
+// @Accept  json
+// @Produce  json
+// @Success 200 {object} dto.JWKSDTO
+// @Failure 500 {object} dto.ProblemDTO "Internal server error"
+// @Router /.well-known/jwks.json [get]
+func (h *JWKSHandler) GetJWKS(w http.ResponseWriter, r *http.Request) {
+	set, err := h.Keys.PublicKeys()
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", fmt.Sprintf("public, max-age=%d", h.MaxAge))
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(set)
+}
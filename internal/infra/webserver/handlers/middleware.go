@@ -0,0 +1,237 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+
+	"github.com/brenoproti/go-api/internal/entity"
+	"github.com/brenoproti/go-api/internal/infra/database"
+	pkg "github.com/brenoproti/go-api/pkg/entity"
+	"github.com/brenoproti/go-api/pkg/jwtkeys"
+	"github.com/go-chi/jwtauth"
+	"github.com/lestrrat-go/jwx/jwt"
+	"gorm.io/gorm"
+)
+
+// Verifier looks for the token of the requests in their Authorization header,
+// then in their jwt cookie, and verifies it with the given keys. Like
+// jwtauth.Verifier, it leaves rejecting the requests to Authenticator.
+func Verifier(keys *jwtkeys.KeySet) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			var token jwt.Token
+			err := jwtauth.ErrNoTokenFound
+			tokenString := jwtauth.TokenFromHeader(r)
+			if tokenString == "" {
+				tokenString = jwtauth.TokenFromCookie(r)
+			}
+			if tokenString != "" {
+				token, err = keys.Verify(tokenString)
+			}
+			next.ServeHTTP(w, r.WithContext(jwtauth.NewContext(r.Context(), token, err)))
+		})
+	}
+}
+
+// Authenticator rejects the requests whose token, as found by Verifier, is
+// missing or invalid. It behaves like jwtauth.Authenticator but answers with
+// a problem like every other error.
+func Authenticator(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token, _, err := jwtauth.FromContext(r.Context())
+		if err != nil {
+			writeProblem(w, r, http.StatusUnauthorized, err.Error())
+			return
+		}
+		if token == nil || jwt.Validate(token) != nil || isMFAToken(token) {
+			writeProblem(w, r, http.StatusUnauthorized, "invalid token")
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// mfaAudience is the audience of the tokens that only prove a user gave
+// their password, and are not accepted as access tokens.
+const mfaAudience = "mfa"
+
+func isMFAToken(token jwt.Token) bool {
+	for _, audience := range token.Audience() {
+		if audience == mfaAudience {
+			return true
+		}
+	}
+	return false
+}
+
+// RequireRole rejects the requests whose token was issued to a user without
+// at least the given role. It must come after Authenticator.
+func RequireRole(role entity.Role) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			current, err := currentRole(r)
+			if err != nil {
+				writeError(w, r, err)
+				return
+			}
+			if !current.Includes(role) {
+				writeError(w, r, errForbidden)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+var (
+	errMissingSubject = errors.New("token has no subject")
+	errMissingTokenID = errors.New("token has no id")
+	errTokenRevoked   = errors.New("token has been revoked")
+)
+
+// RejectRevoked rejects the requests whose token was revoked, for instance
+// by logging out, or was issued before the password of the user changed. It
+// must come after Authenticator.
+func RejectRevoked(tokens database.RevokedTokenInterface, users database.UserInterface) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			token, _, err := jwtauth.FromContext(r.Context())
+			if err != nil {
+				writeError(w, r, err)
+				return
+			}
+			if token.JwtID() == "" {
+				writeError(w, r, errMissingTokenID)
+				return
+			}
+			revoked, err := tokens.IsRevoked(token.JwtID())
+			if err != nil {
+				writeError(w, r, err)
+				return
+			}
+			if revoked {
+				writeError(w, r, errTokenRevoked)
+				return
+			}
+			user, err := users.FindById(token.Subject())
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				writeError(w, r, errTokenRevoked)
+				return
+			}
+			if err != nil {
+				writeError(w, r, err)
+				return
+			}
+			if !user.AcceptsTokenIssuedAt(token.IssuedAt()) {
+				writeError(w, r, errTokenRevoked)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// currentUserID is the ID of the user the token of an authenticated request
+// was issued to.
+func currentUserID(r *http.Request) (string, error) {
+	_, claims, err := jwtauth.FromContext(r.Context())
+	if err != nil {
+		return "", err
+	}
+	sub, _ := claims["sub"].(string)
+	if sub == "" {
+		return "", errMissingSubject
+	}
+	return sub, nil
+}
+
+// currentUser is like currentUserID but parses the ID.
+func currentUser(r *http.Request) (pkg.ID, error) {
+	sub, err := currentUserID(r)
+	if err != nil {
+		return pkg.ID{}, err
+	}
+	id, err := pkg.ParseID(sub)
+	if err != nil {
+		return pkg.ID{}, errMissingSubject
+	}
+	return id, nil
+}
+
+// currentRole is the role of the user the token of an authenticated request
+// was issued to, as it was when the token was issued.
+func currentRole(r *http.Request) (entity.Role, error) {
+	_, claims, err := jwtauth.FromContext(r.Context())
+	if err != nil {
+		return "", err
+	}
+	role, _ := claims["role"].(string)
+	return entity.Role(role), nil
+}
+
+// RealIP sets the remote address of the requests that come through one of the
+// trusted proxies, given as IP addresses or CIDR ranges, to the client address
+// in their X-Forwarded-For header: the last one not added by a trusted proxy.
+// Unlike chi's RealIP, the header of requests from anywhere else is ignored,
+// or clients could pick the address their logins are throttled on.
+func RealIP(proxies []string) (func(http.Handler) http.Handler, error) {
+	var trusted []*net.IPNet
+	for _, proxy := range proxies {
+		proxy = strings.TrimSpace(proxy)
+		if proxy == "" {
+			continue
+		}
+		if !strings.Contains(proxy, "/") {
+			if ip := net.ParseIP(proxy); ip != nil && ip.To4() != nil {
+				proxy += "/32"
+			} else {
+				proxy += "/128"
+			}
+		}
+		_, network, err := net.ParseCIDR(proxy)
+		if err != nil {
+			return nil, fmt.Errorf("invalid trusted proxy %q: %w", proxy, err)
+		}
+		trusted = append(trusted, network)
+	}
+	isTrusted := func(address string) bool {
+		ip := net.ParseIP(address)
+		for _, network := range trusted {
+			if ip != nil && network.Contains(ip) {
+				return true
+			}
+		}
+		return false
+	}
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if isTrusted(clientIP(r)) {
+				forwarded := strings.Split(strings.Join(r.Header.Values("X-Forwarded-For"), ","), ",")
+				for i := len(forwarded) - 1; i >= 0; i-- {
+					address := strings.TrimSpace(forwarded[i])
+					if net.ParseIP(address) == nil {
+						break
+					}
+					r.RemoteAddr = address
+					if !isTrusted(address) {
+						break
+					}
+				}
+			}
+			next.ServeHTTP(w, r)
+		})
+	}, nil
+}
+
+// clientIP is the address the request came from, without the port. Behind a
+// proxy, RealIP has to set it from the forwarding headers.
+func clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
@@ -0,0 +1,270 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/brenoproti/go-api/internal/entity"
+	"github.com/brenoproti/go-api/internal/infra/database"
+	pkg "github.com/brenoproti/go-api/pkg/entity"
+	"github.com/brenoproti/go-api/pkg/jwtkeys"
+	"github.com/go-chi/chi"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+func newTestKeys(t *testing.T) *jwtkeys.KeySet {
+	keys, err := jwtkeys.New(jwtkeys.Config{Algorithm: "HS256", Secret: "secret"})
+	assert.Nil(t, err)
+	return keys
+}
+
+func newTestToken(t *testing.T, keys *jwtkeys.KeySet, sub string, role entity.Role) string {
+	_, token, err := keys.Encode(map[string]interface{}{
+		"jti":  pkg.NewID().String(),
+		"sub":  sub,
+		"role": string(role),
+		"iat":  time.Now().Unix(),
+		"exp":  time.Now().Add(time.Minute).Unix(),
+	})
+	assert.Nil(t, err)
+	return token
+}
+
+func serve(r http.Handler, method, path, token string, body interface{}) *httptest.ResponseRecorder {
+	var payload bytes.Buffer
+	if body != nil {
+		json.NewEncoder(&payload).Encode(body)
+	}
+	req := httptest.NewRequest(method, path, &payload)
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w
+}
+
+func TestRequireRole(t *testing.T) {
+	keys := newTestKeys(t)
+	ok := func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(http.StatusNoContent) }
+	r := chi.NewRouter()
+	r.Use(Verifier(keys), Authenticator)
+	r.With(RequireRole(entity.RoleViewer)).Get("/viewer", ok)
+	r.With(RequireRole(entity.RoleEditor)).Get("/editor", ok)
+	r.With(RequireRole(entity.RoleAdmin)).Get("/admin", ok)
+
+	statuses := map[entity.Role]map[string]int{
+		entity.RoleViewer: {"/viewer": http.StatusNoContent, "/editor": http.StatusForbidden, "/admin": http.StatusForbidden},
+		entity.RoleEditor: {"/viewer": http.StatusNoContent, "/editor": http.StatusNoContent, "/admin": http.StatusForbidden},
+		entity.RoleAdmin:  {"/viewer": http.StatusNoContent, "/editor": http.StatusNoContent, "/admin": http.StatusNoContent},
+		// tokens without a known role grant nothing
+		"":     {"/viewer": http.StatusForbidden, "/editor": http.StatusForbidden, "/admin": http.StatusForbidden},
+		"root": {"/viewer": http.StatusForbidden, "/editor": http.StatusForbidden, "/admin": http.StatusForbidden},
+	}
+	for role, paths := range statuses {
+		token := newTestToken(t, keys, pkg.NewID().String(), role)
+		for path, status := range paths {
+			w := serve(r, http.MethodGet, path, token, nil)
+			assert.Equal(t, status, w.Code, "%s %s", role, path)
+			if status == http.StatusForbidden {
+				assert.Equal(t, "application/problem+json", w.Header().Get("Content-Type"))
+			}
+		}
+	}
+
+	for _, token := range []string{"", "invalid"} {
+		w := serve(r, http.MethodGet, "/viewer", token, nil)
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+	}
+}
+
+func TestSetRoleIsAdminOnly(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open("file::memory:"), &gorm.Config{})
+	assert.Nil(t, err)
+	db.AutoMigrate(&entity.User{}, &entity.RefreshToken{})
+	userDB := database.NewUser(db)
+	user, err := entity.NewUser("John Doe", "john@email.com", "Str0ng-Passw0rd")
+	assert.Nil(t, err)
+	assert.Nil(t, userDB.Create(user))
+
+	keys := newTestKeys(t)
+	h := &UserHandler{UserDB: userDB}
+	r := chi.NewRouter()
+	r.Use(Verifier(keys), Authenticator)
+	r.With(RequireRole(entity.RoleAdmin)).Put("/users/{id}/role", h.SetRole)
+	path := "/users/" + user.ID.String() + "/role"
+
+	for _, role := range []entity.Role{entity.RoleViewer, entity.RoleEditor} {
+		token := newTestToken(t, keys, pkg.NewID().String(), role)
+		w := serve(r, http.MethodPut, path, token, map[string]string{"role": "admin"})
+		assert.Equal(t, http.StatusForbidden, w.Code, role)
+	}
+	found, err := userDB.FindById(user.ID.String())
+	assert.Nil(t, err)
+	assert.Equal(t, entity.RoleViewer, found.Role)
+
+	admin := newTestToken(t, keys, pkg.NewID().String(), entity.RoleAdmin)
+	w := serve(r, http.MethodPut, path, admin, map[string]string{"role": "editor"})
+	assert.Equal(t, http.StatusOK, w.Code)
+	found, err = userDB.FindById(user.ID.String())
+	assert.Nil(t, err)
+	assert.Equal(t, entity.RoleEditor, found.Role)
+
+	// admins cannot demote themselves
+	w = serve(r, http.MethodPut, path, newTestToken(t, keys, user.ID.String(), entity.RoleAdmin), map[string]string{"role": "viewer"})
+	assert.Equal(t, http.StatusForbidden, w.Code)
+}
+
+func TestFindUsersIsAdminOnly(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open("file::memory:"), &gorm.Config{})
+	assert.Nil(t, err)
+	db.AutoMigrate(&entity.User{})
+	userDB := database.NewUser(db)
+	user, err := entity.NewUser("John Doe", "john@email.com", "Str0ng-Passw0rd")
+	assert.Nil(t, err)
+	assert.Nil(t, userDB.Create(user))
+
+	keys := newTestKeys(t)
+	h := &UserHandler{UserDB: userDB}
+	r := chi.NewRouter()
+	r.Use(Verifier(keys), Authenticator)
+	r.With(RequireRole(entity.RoleAdmin)).Get("/users", h.GetUsers)
+	r.Get("/users/{id}", h.FindById)
+	path := "/users/" + user.ID.String()
+
+	for _, role := range []entity.Role{entity.RoleViewer, entity.RoleEditor} {
+		token := newTestToken(t, keys, pkg.NewID().String(), role)
+		assert.Equal(t, http.StatusForbidden, serve(r, http.MethodGet, "/users", token, nil).Code, role)
+		assert.Equal(t, http.StatusForbidden, serve(r, http.MethodGet, path, token, nil).Code, role)
+		// but everyone can find themselves
+		own := newTestToken(t, keys, user.ID.String(), role)
+		assert.Equal(t, http.StatusOK, serve(r, http.MethodGet, path, own, nil).Code, role)
+	}
+
+	admin := newTestToken(t, keys, pkg.NewID().String(), entity.RoleAdmin)
+	assert.Equal(t, http.StatusOK, serve(r, http.MethodGet, "/users", admin, nil).Code)
+	assert.Equal(t, http.StatusOK, serve(r, http.MethodGet, path, admin, nil).Code)
+}
+
+func TestRejectRevokedAfterPasswordChange(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open("file::memory:"), &gorm.Config{})
+	assert.Nil(t, err)
+	db.AutoMigrate(&entity.User{}, &entity.RefreshToken{}, &entity.RevokedToken{})
+	userDB := database.NewUser(db)
+	user, err := entity.NewUser("John Doe", "john@email.com", "Str0ng-Passw0rd")
+	assert.Nil(t, err)
+	assert.Nil(t, userDB.Create(user))
+
+	keys := newTestKeys(t)
+	ok := func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(http.StatusNoContent) }
+	r := chi.NewRouter()
+	r.Use(Verifier(keys), Authenticator, RejectRevoked(database.NewRevokedTokenDB(db), userDB))
+	r.Get("/", ok)
+
+	_, old, err := keys.Encode(map[string]interface{}{
+		"jti": pkg.NewID().String(),
+		"sub": user.ID.String(),
+		"iat": time.Now().Add(-time.Minute).Unix(),
+		"exp": time.Now().Add(time.Minute).Unix(),
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, http.StatusNoContent, serve(r, http.MethodGet, "/", old, nil).Code)
+
+	assert.Nil(t, user.ChangePassword("N3w-Passw0rd"))
+	assert.Nil(t, userDB.UpdatePassword(user))
+	assert.Equal(t, http.StatusUnauthorized, serve(r, http.MethodGet, "/", old, nil).Code)
+	// tokens issued since still work
+	fresh := newTestToken(t, keys, user.ID.String(), entity.RoleViewer)
+	assert.Equal(t, http.StatusNoContent, serve(r, http.MethodGet, "/", fresh, nil).Code)
+	// and so do none of a deleted user
+	assert.Nil(t, db.Delete(&entity.User{}, "id = ?", user.ID).Error)
+	assert.Equal(t, http.StatusUnauthorized, serve(r, http.MethodGet, "/", fresh, nil).Code)
+}
+
+func TestRealIP(t *testing.T) {
+	realIP, err := RealIP([]string{"10.0.0.0/8", " 192.0.2.1", ""})
+	assert.Nil(t, err)
+	handler := realIP(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(clientIP(r)))
+	}))
+	tests := []struct {
+		remote    string
+		forwarded []string
+		ip        string
+	}{
+		{"203.0.113.1:1234", nil, "203.0.113.1"},
+		// only trusted proxies can forward
+		{"203.0.113.1:1234", []string{"198.51.100.1"}, "203.0.113.1"},
+		{"192.0.2.1:1234", nil, "192.0.2.1"},
+		{"192.0.2.1:1234", []string{"198.51.100.1"}, "198.51.100.1"},
+		// clients can prepend whatever they like, but not past the proxies
+		{"192.0.2.1:1234", []string{"1.1.1.1, 198.51.100.1, 10.0.0.2"}, "198.51.100.1"},
+		{"192.0.2.1:1234", []string{"1.1.1.1", "198.51.100.1"}, "198.51.100.1"},
+		{"192.0.2.1:1234", []string{"10.0.0.3, 10.0.0.2"}, "10.0.0.3"},
+		{"192.0.2.1:1234", []string{"garbage"}, "192.0.2.1"},
+	}
+	for _, test := range tests {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.RemoteAddr = test.remote
+		for _, forwarded := range test.forwarded {
+			req.Header.Add("X-Forwarded-For", forwarded)
+		}
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+		assert.Equal(t, test.ip, w.Body.String(), "%s %v", test.remote, test.forwarded)
+	}
+
+	_, err = RealIP([]string{"proxy.local"})
+	assert.NotNil(t, err)
+}
+
+func TestSetRoleRevokesTokens(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open("file::memory:"), &gorm.Config{})
+	assert.Nil(t, err)
+	db.AutoMigrate(&entity.User{}, &entity.RefreshToken{}, &entity.RevokedToken{})
+	userDB := database.NewUser(db)
+	user, err := entity.NewUser("John Doe", "john@email.com", "Str0ng-Passw0rd")
+	assert.Nil(t, err)
+	user.Role = entity.RoleEditor
+	assert.Nil(t, userDB.Create(user))
+	admin, err := entity.NewUser("Jane Doe", "jane@email.com", "Str0ng-Passw0rd")
+	assert.Nil(t, err)
+	admin.Role = entity.RoleAdmin
+	assert.Nil(t, userDB.Create(admin))
+	refreshTokenDB := database.NewRefreshTokenDB(db)
+	refresh, plain, err := entity.NewRefreshToken(user.ID, nil, time.Hour)
+	assert.Nil(t, err)
+	assert.Nil(t, refreshTokenDB.Create(refresh))
+
+	keys := newTestKeys(t)
+	h := &UserHandler{UserDB: userDB}
+	ok := func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(http.StatusNoContent) }
+	r := chi.NewRouter()
+	r.Use(Verifier(keys), Authenticator, RejectRevoked(database.NewRevokedTokenDB(db), userDB))
+	r.With(RequireRole(entity.RoleEditor)).Get("/editor", ok)
+	r.With(RequireRole(entity.RoleAdmin)).Put("/users/{id}/role", h.SetRole)
+
+	_, editor, err := keys.Encode(map[string]interface{}{
+		"jti":  pkg.NewID().String(),
+		"sub":  user.ID.String(),
+		"role": string(entity.RoleEditor),
+		"iat":  time.Now().Add(-time.Minute).Unix(),
+		"exp":  time.Now().Add(time.Minute).Unix(),
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, http.StatusNoContent, serve(r, http.MethodGet, "/editor", editor, nil).Code)
+
+	token := newTestToken(t, keys, admin.ID.String(), entity.RoleAdmin)
+	w := serve(r, http.MethodPut, "/users/"+user.ID.String()+"/role", token, map[string]string{"role": "viewer"})
+	assert.Equal(t, http.StatusOK, w.Code)
+	// the demoted editor can neither use their token nor refresh it
+	assert.Equal(t, http.StatusUnauthorized, serve(r, http.MethodGet, "/editor", editor, nil).Code)
+	_, _, err = refreshTokenDB.Rotate(plain, time.Hour)
+	assert.ErrorIs(t, err, entity.ErrRefreshTokenRevoked)
+}
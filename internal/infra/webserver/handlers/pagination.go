@@ -0,0 +1,59 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/brenoproti/go-api/internal/dto"
+	"github.com/brenoproti/go-api/internal/infra/database"
+)
+
+// pageParams reads the page and limit query parameters, falling back to the
+// first page and default page size and clamping the limit to the maximum.
+func pageParams(r *http.Request) (int, int) {
+	page, err := strconv.Atoi(r.URL.Query().Get("page"))
+	if err != nil {
+		page = 0
+	}
+	limit, err := strconv.Atoi(r.URL.Query().Get("limit"))
+	if err != nil {
+		limit = 0
+	}
+	return database.NormalizePage(page, limit)
+}
+
+func newPage[T any](r *http.Request, data []T, total int64, page, limit int) dto.PageDTO {
+	if data == nil {
+		data = []T{}
+	}
+	totalPages := int((total + int64(limit) - 1) / int64(limit))
+	p := dto.PageDTO{
+		Data:       data,
+		Total:      total,
+		Page:       page,
+		Limit:      limit,
+		TotalPages: totalPages,
+	}
+	if page < totalPages {
+		p.Next = pageLink(r, page+1, limit)
+	}
+	if page > 1 && totalPages > 0 {
+		prev := page - 1
+		if prev > totalPages {
+			prev = totalPages
+		}
+		p.Prev = pageLink(r, prev, limit)
+	}
+	return p
+}
+
+// pageLink is the current request URL pointing to another page.
+func pageLink(r *http.Request, page, limit int) *string {
+	u := *r.URL
+	query := u.Query()
+	query.Set("page", strconv.Itoa(page))
+	query.Set("limit", strconv.Itoa(limit))
+	u.RawQuery = query.Encode()
+	link := u.RequestURI()
+	return &link
+}
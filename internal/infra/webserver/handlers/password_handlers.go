@@ -0,0 +1,138 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/brenoproti/go-api/internal/dto"
+	"github.com/brenoproti/go-api/internal/entity"
+	"github.com/brenoproti/go-api/internal/infra/database"
+	"github.com/brenoproti/go-api/internal/infra/mail"
+	"gorm.io/gorm"
+)
+
+type PasswordHandler struct {
+	UserDB          database.UserInterface
+	PasswordResetDB database.PasswordResetInterface
+	// LoginAttemptDB and LoginThrottle throttle the resets asked for like
+	// logins.
+	LoginAttemptDB database.LoginAttemptInterface
+	LoginThrottle  entity.LoginThrottle
+	Mailer         mail.Mailer
+	ResetExpiresIn int
+}
+
+func NewPasswordHandler(userDB database.UserInterface, passwordResetDB database.PasswordResetInterface, loginAttemptDB database.LoginAttemptInterface, loginThrottle entity.LoginThrottle, mailer mail.Mailer, resetExpiresIn int) *PasswordHandler {
+	return &PasswordHandler{
+		UserDB:          userDB,
+		PasswordResetDB: passwordResetDB,
+		LoginAttemptDB:  loginAttemptDB,
+		LoginThrottle:   loginThrottle,
+		Mailer:          mailer,
+		ResetExpiresIn:  resetExpiresIn,
+	}
+}
+
+// Forgot password godoc
+// @Summary Ask for a password reset
+// @Description Email a single-use token to reset the password of the account. The response is the same whether
+// @Description the account exists or not, and is sent before looking the account up, so that it does not reveal
+// @Description which emails are registered. Asking again with the same email or from the same IP address has to
+// @Description wait longer and longer, like failed logins.
+// @Tags users
+// @Accept  json
+// @Produce  json
+// @Param request body dto.ForgotPasswordDTO true "Email of the account"
+// @Success 202 {string} string "Reset token sent if the account exists"
+// @Failure 400 {object} dto.ProblemDTO "Bad request"
+// @Failure 429 {object} dto.ProblemDTO "Too many resets asked for, retry after the number of seconds in the Retry-After header"
+// @Header 429 {integer} Retry-After "Seconds to wait before trying again"
+// @Failure 500 {object} dto.ProblemDTO "Internal server error"
+// @Router /users/password/forgot [post]
+func (h *PasswordHandler) Forgot(w http.ResponseWriter, r *http.Request) {
+	var request dto.ForgotPasswordDTO
+	err := decode(r, &request)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	attempt, wait, err := reserveAttempt(h.LoginAttemptDB, h.LoginThrottle, r, request.Email, nil, entity.LoginResetRequested)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	if wait > 0 {
+		writeThrottled(w, r, h.LoginAttemptDB, attempt, wait, errTooManyEmails)
+		return
+	}
+	inBackground("sending password reset", func() error {
+		return h.sendReset(request.Email)
+	})
+	w.WriteHeader(http.StatusAccepted)
+}
+
+// sendReset emails a reset token to the user with the email, if any.
+// Failing to deliver it is only logged, the user can ask again.
+func (h *PasswordHandler) sendReset(email string) error {
+	user, err := h.UserDB.FindByEmail(email)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	ttl := time.Second * time.Duration(h.ResetExpiresIn)
+	reset, token, err := entity.NewPasswordReset(user.ID, ttl)
+	if err != nil {
+		return err
+	}
+	if err := h.PasswordResetDB.Create(reset); err != nil {
+		return err
+	}
+	if err := h.Mailer.Send(passwordResetMessage(user, token, ttl)); err != nil {
+		log.Printf("sending password reset to %s: %v", user.Email, err)
+	}
+	return nil
+}
+
+// Reset password godoc
+// @Summary Reset a password
+// @Description Set a new password with the token emailed by /users/password/forgot. The token can only be used once,
+// @Description and every session of the account is signed out.
+// @Tags users
+// @Accept  json
+// @Produce  json
+// @Param request body dto.ResetPasswordDTO true "Reset token and new password"
+// @Success 204 "Password reset"
+// @Failure 400 {object} dto.ProblemDTO "Bad request"
+// @Failure 500 {object} dto.ProblemDTO "Internal server error"
+// @Router /users/password/reset [post]
+func (h *PasswordHandler) Reset(w http.ResponseWriter, r *http.Request) {
+	var request dto.ResetPasswordDTO
+	err := decode(r, &request)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	if _, err := h.PasswordResetDB.Reset(request.Token, request.Password); err != nil {
+		writeError(w, r, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func passwordResetMessage(user *entity.User, token string, ttl time.Duration) mail.Message {
+	return mail.Message{
+		To:      user.Email,
+		Subject: "Reset your password",
+		Body: fmt.Sprintf("Hi %s,\n\n"+
+			"Someone asked to reset the password of your account. Send this token along with your new password\n"+
+			"to /users/password/reset within %d minutes:\n\n"+
+			"%s\n\n"+
+			"If it was not you, ignore this email, your password stays the same.\n",
+			user.Name, int(ttl.Minutes()), token),
+	}
+}
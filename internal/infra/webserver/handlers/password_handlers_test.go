@@ -0,0 +1,71 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/brenoproti/go-api/internal/entity"
+	"github.com/brenoproti/go-api/internal/infra/database"
+	"github.com/brenoproti/go-api/internal/infra/mail"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+type testMailer struct {
+	mu       sync.Mutex
+	messages []mail.Message
+}
+
+func (m *testMailer) Send(msg mail.Message) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.messages = append(m.messages, msg)
+	return nil
+}
+
+func (m *testMailer) sent() []mail.Message {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]mail.Message(nil), m.messages...)
+}
+
+func TestForgotIsThrottled(t *testing.T) {
+	dsn := "file:" + t.Name() + "?mode=memory&cache=shared"
+	db, err := gorm.Open(sqlite.Open(dsn), &gorm.Config{})
+	assert.Nil(t, err)
+	db.AutoMigrate(&entity.User{}, &entity.PasswordReset{}, &entity.LoginAttempt{})
+	userDB := database.NewUser(db)
+	user, err := entity.NewUser("John Doe", "john@email.com", "Str0ng-Passw0rd")
+	assert.Nil(t, err)
+	assert.Nil(t, userDB.Create(user))
+	mailer := &testMailer{}
+	h := NewPasswordHandler(userDB, database.NewPasswordResetDB(db), database.NewLoginAttemptDB(db),
+		entity.LoginThrottle{MaxEmailFailures: 5, MaxIPFailures: 20, BaseDelay: time.Minute, MaxDelay: time.Hour, Lockout: time.Hour}, mailer, 3600)
+
+	forgot := func(email, ip string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(`{"email": "`+email+`"}`))
+		req.RemoteAddr = ip + ":1234"
+		w := httptest.NewRecorder()
+		h.Forgot(w, req)
+		return w
+	}
+
+	// known and unknown emails get the same answer, the email is sent after
+	assert.Equal(t, http.StatusAccepted, forgot("jane@email.com", "192.0.2.1").Code)
+	assert.Equal(t, http.StatusAccepted, forgot("john@email.com", "192.0.2.2").Code)
+	assert.Eventually(t, func() bool { return len(mailer.sent()) == 1 }, time.Second, 10*time.Millisecond)
+	assert.Equal(t, "john@email.com", mailer.sent()[0].To)
+
+	// asking again has to wait, with the same email or from the same address
+	for _, w := range []*httptest.ResponseRecorder{forgot("john@email.com", "192.0.2.3"), forgot("bob@email.com", "192.0.2.1")} {
+		assert.Equal(t, http.StatusTooManyRequests, w.Code)
+		assert.NotEmpty(t, w.Header().Get("Retry-After"))
+	}
+	time.Sleep(50 * time.Millisecond)
+	assert.Len(t, mailer.sent(), 1)
+}
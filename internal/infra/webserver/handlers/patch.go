@@ -0,0 +1,49 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"mime"
+
+	jsonpatch "github.com/evanphx/json-patch/v5"
+)
+
+const (
+	mergePatchContentType = "application/merge-patch+json"
+	jsonPatchContentType  = "application/json-patch+json"
+)
+
+var (
+	errUnsupportedPatch = errors.New("unsupported patch content type")
+	errInvalidPatch     = errors.New("invalid patch")
+)
+
+// applyPatch applies an RFC 7396 merge patch or an RFC 6902 JSON patch to a
+// JSON document, depending on the content type of the request. Plain JSON is
+// treated as a merge patch.
+func applyPatch(contentType string, doc, patch []byte) ([]byte, error) {
+	patched, err := patchDocument(contentType, doc, patch)
+	if err != nil && !errors.Is(err, errUnsupportedPatch) && !errors.Is(err, jsonpatch.ErrTestFailed) {
+		return nil, fmt.Errorf("%w: %v", errInvalidPatch, err)
+	}
+	return patched, err
+}
+
+func patchDocument(contentType string, doc, patch []byte) ([]byte, error) {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil && contentType != "" {
+		return nil, errUnsupportedPatch
+	}
+	switch mediaType {
+	case "", "application/json", mergePatchContentType:
+		return jsonpatch.MergePatch(doc, patch)
+	case jsonPatchContentType:
+		p, err := jsonpatch.DecodePatch(patch)
+		if err != nil {
+			return nil, err
+		}
+		return p.Apply(doc)
+	default:
+		return nil, errUnsupportedPatch
+	}
+}
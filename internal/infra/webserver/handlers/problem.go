@@ -0,0 +1,236 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/brenoproti/go-api/internal/dto"
+	"github.com/brenoproti/go-api/internal/entity"
+	"github.com/brenoproti/go-api/internal/infra/database"
+	"github.com/brenoproti/go-api/pkg/cursor"
+	pkg "github.com/brenoproti/go-api/pkg/entity"
+	jsonpatch "github.com/evanphx/json-patch/v5"
+	"gorm.io/gorm"
+)
+
+const (
+	problemContentType    = "application/problem+json"
+	validationProblemType = "/problems/validation-error"
+)
+
+var (
+	errEmptyBody          = errors.New("request body is empty")
+	errMalformedBody      = errors.New("request body is not valid JSON")
+	errInvalidCredentials = errors.New("invalid email or password")
+	errForbidden          = errors.New("not allowed to act on this resource")
+	errOwnRole            = errors.New("admins cannot change their own role")
+	errWrongPassword      = errors.New("current password is wrong")
+	errPasswordUnchanged  = errors.New("new password must differ from the current one")
+	errInvalidMFAToken    = errors.New("invalid or expired MFA token")
+	errReservationTooLong = errors.New("reservation ttl exceeds the maximum")
+	// the Retry-After header tells how long to wait
+	errTooManyLoginAttempts = errors.New("too many failed login attempts, try again later")
+	errTooManyEmails        = errors.New("too many emails asked for, try again later")
+)
+
+// errorProblems maps known errors to the status they are reported with and,
+// for validation errors, the request field they are about. The first match
+// wins.
+var errorProblems = []struct {
+	err    error
+	status int
+	field  string
+}{
+	{entity.ErrIdIsRequired, http.StatusBadRequest, "id"},
+	{entity.ErrInvalidId, http.StatusBadRequest, "id"},
+	{entity.ErrNameIsRequired, http.StatusBadRequest, "name"},
+	{entity.ErrEmailIsRequired, http.StatusBadRequest, "email"},
+	{entity.ErrInvalidEmail, http.StatusBadRequest, "email"},
+	{entity.ErrInvalidRole, http.StatusBadRequest, "role"},
+	{entity.ErrInvalidLoginResult, http.StatusBadRequest, "result"},
+	{entity.ErrWeakPassword, http.StatusBadRequest, "password"},
+	{entity.ErrCommonPassword, http.StatusBadRequest, "password"},
+	{entity.ErrPasswordTooLong, http.StatusBadRequest, "password"},
+	{errWrongPassword, http.StatusBadRequest, "current_password"},
+	{errPasswordUnchanged, http.StatusBadRequest, "new_password"},
+	{entity.ErrInvalidResetToken, http.StatusBadRequest, "token"},
+	{entity.ErrInvalidVerificationToken, http.StatusBadRequest, "token"},
+	{entity.ErrInvalidTOTPCode, http.StatusBadRequest, "code"},
+	{entity.ErrPriceIsRequired, http.StatusBadRequest, "price"},
+	{entity.ErrInvalidPrice, http.StatusBadRequest, "price"},
+	{pkg.ErrInvalidAmount, http.StatusBadRequest, "price"},
+	{pkg.ErrInvalidCurrency, http.StatusBadRequest, "price"},
+	{entity.ErrInvalidParent, http.StatusBadRequest, "parent_id"},
+	{database.ErrUnknownCategory, http.StatusBadRequest, "category_ids"},
+	{database.ErrInvalidSort, http.StatusBadRequest, "sort"},
+	{cursor.ErrInvalidCursor, http.StatusBadRequest, "cursor"},
+	{entity.ErrInvalidQuantity, http.StatusBadRequest, "quantity"},
+	{entity.ErrInvalidExpiration, http.StatusBadRequest, "ttl"},
+	{errReservationTooLong, http.StatusBadRequest, "ttl"},
+	{errEmptyBody, http.StatusBadRequest, ""},
+	{errMalformedBody, http.StatusBadRequest, ""},
+	{errInvalidPatch, http.StatusBadRequest, ""},
+	{errInvalidCredentials, http.StatusUnauthorized, ""},
+	{errMissingSubject, http.StatusUnauthorized, ""},
+	{errMissingTokenID, http.StatusUnauthorized, ""},
+	{errTokenRevoked, http.StatusUnauthorized, ""},
+	{errInvalidMFAToken, http.StatusUnauthorized, ""},
+	{entity.ErrInvalidRefreshToken, http.StatusUnauthorized, ""},
+	{entity.ErrRefreshTokenExpired, http.StatusUnauthorized, ""},
+	{entity.ErrRefreshTokenRevoked, http.StatusUnauthorized, ""},
+	{entity.ErrRefreshTokenReused, http.StatusUnauthorized, ""},
+	{errForbidden, http.StatusForbidden, ""},
+	{errOwnRole, http.StatusForbidden, ""},
+	{errEmailNotVerified, http.StatusForbidden, ""},
+	{gorm.ErrRecordNotFound, http.StatusNotFound, ""},
+	{entity.ErrInsufficientStock, http.StatusConflict, ""},
+	{entity.ErrReservationExpired, http.StatusConflict, ""},
+	{database.ErrCategoryHasChildren, http.StatusConflict, ""},
+	{database.ErrEmailTaken, http.StatusConflict, "email"},
+	{database.ErrTwoFactorEnabled, http.StatusConflict, ""},
+	{jsonpatch.ErrTestFailed, http.StatusConflict, ""},
+	{database.ErrVersionConflict, http.StatusPreconditionFailed, ""},
+	{errUnsupportedPatch, http.StatusUnsupportedMediaType, ""},
+	{errTooManyLoginAttempts, http.StatusTooManyRequests, ""},
+	{errTooManyEmails, http.StatusTooManyRequests, ""},
+}
+
+// fieldError ties an error to the request field it was caused by, taking
+// precedence over the field errorProblems would report.
+type fieldError struct {
+	field string
+	err   error
+}
+
+func (e *fieldError) Error() string {
+	return e.field + ": " + e.err.Error()
+}
+
+func (e *fieldError) Unwrap() error {
+	return e.err
+}
+
+func invalidField(field string, err error) error {
+	return &fieldError{field: field, err: err}
+}
+
+// writeError reports err as a problem. Errors that are not known are logged
+// and reported as an internal server error without any detail.
+func writeError(w http.ResponseWriter, r *http.Request, err error) {
+	err = decodeError(err)
+	var ve *validationError
+	if errors.As(err, &ve) {
+		writeProblem(w, r, http.StatusBadRequest, "", ve.fields...)
+		return
+	}
+	var fe *fieldError
+	if errors.As(err, &fe) {
+		status, _ := errorStatus(fe.err)
+		if status == http.StatusInternalServerError {
+			status = http.StatusBadRequest
+		}
+		writeProblem(w, r, status, "", fieldProblem(fe.field, fe.err))
+		return
+	}
+	status, field := errorStatus(err)
+	switch {
+	case status == http.StatusInternalServerError:
+		log.Printf("%s %s: %v", r.Method, r.URL.Path, err)
+		writeProblem(w, r, status, "")
+	case field != "":
+		writeProblem(w, r, status, "", fieldProblem(field, err))
+	default:
+		writeProblem(w, r, status, err.Error())
+	}
+}
+
+// fieldProblem describes an invalid field, prefixing the message with the
+// field name unless the message already mentions it.
+func fieldProblem(field string, err error) dto.FieldErrorDTO {
+	message := err.Error()
+	if !strings.Contains(message, field) {
+		message = field + ": " + message
+	}
+	return dto.FieldErrorDTO{Field: field, Message: message}
+}
+
+// knownError reports whether writeError would describe err to the client
+// rather than hide it behind an internal server error.
+func knownError(err error) bool {
+	err = decodeError(err)
+	var ve *validationError
+	var fe *fieldError
+	if errors.As(err, &ve) || errors.As(err, &fe) {
+		return true
+	}
+	status, _ := errorStatus(err)
+	return status != http.StatusInternalServerError
+}
+
+func errorStatus(err error) (int, string) {
+	for _, p := range errorProblems {
+		if errors.Is(err, p.err) {
+			return p.status, p.field
+		}
+	}
+	return http.StatusInternalServerError, ""
+}
+
+// decodeError turns the errors of encoding/json into ones telling the client
+// what is wrong with the body.
+func decodeError(err error) error {
+	var syntaxErr *json.SyntaxError
+	var typeErr *json.UnmarshalTypeError
+	switch {
+	case errors.Is(err, io.EOF):
+		return errEmptyBody
+	case errors.As(err, &syntaxErr), errors.Is(err, io.ErrUnexpectedEOF):
+		return errMalformedBody
+	case errors.As(err, &typeErr) && typeErr.Field != "":
+		return invalidField(typeErr.Field, errors.New(typeErr.Field+" must be of type "+typeErr.Type.String()))
+	case strings.HasPrefix(err.Error(), "json: unknown field "):
+		field := strings.Trim(strings.TrimPrefix(err.Error(), "json: unknown field "), `"`)
+		return invalidField(field, errors.New("unknown field "+field))
+	}
+	return err
+}
+
+// writeProblem writes an application/problem+json response. Field errors
+// make a bad request a validation problem.
+func writeProblem(w http.ResponseWriter, r *http.Request, status int, detail string, fields ...dto.FieldErrorDTO) {
+	problem := dto.ProblemDTO{
+		Type:     "about:blank",
+		Title:    http.StatusText(status),
+		Status:   status,
+		Detail:   detail,
+		Instance: r.URL.Path,
+		Errors:   fields,
+	}
+	if len(fields) > 0 && status == http.StatusBadRequest {
+		problem.Type = validationProblemType
+		problem.Title = "Validation failed"
+	}
+	if problem.Detail == "" && len(fields) == 1 {
+		problem.Detail = fields[0].Message
+	} else if problem.Detail == "" && len(fields) > 1 {
+		problem.Detail = fmt.Sprintf("%d fields are invalid", len(fields))
+	}
+	w.Header().Set("Content-Type", problemContentType)
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(problem)
+}
+
+// NotFound reports unknown routes as a problem.
+func NotFound(w http.ResponseWriter, r *http.Request) {
+	writeProblem(w, r, http.StatusNotFound, "")
+}
+
+// MethodNotAllowed reports unsupported methods as a problem.
+func MethodNotAllowed(w http.ResponseWriter, r *http.Request) {
+	writeProblem(w, r, http.StatusMethodNotAllowed, "")
+}
@@ -0,0 +1,141 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/brenoproti/go-api/internal/dto"
+	"github.com/brenoproti/go-api/internal/entity"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+)
+
+func writeTestError(t *testing.T, err error) (*httptest.ResponseRecorder, dto.ProblemDTO) {
+	t.Helper()
+	w := httptest.NewRecorder()
+	writeError(w, httptest.NewRequest(http.MethodGet, "/things", nil), err)
+	var problem dto.ProblemDTO
+	assert.Nil(t, json.NewDecoder(w.Body).Decode(&problem))
+	return w, problem
+}
+
+func TestWriteErrorKnownErrors(t *testing.T) {
+	for _, p := range errorProblems {
+		t.Run(p.err.Error(), func(t *testing.T) {
+			// wrapping must not hide the error
+			w, problem := writeTestError(t, fmt.Errorf("doing things: %w", p.err))
+
+			assert.Equal(t, p.status, w.Code)
+			assert.Equal(t, problemContentType, w.Header().Get("Content-Type"))
+			assert.Equal(t, p.status, problem.Status)
+			assert.Equal(t, "/things", problem.Instance)
+			assert.NotEmpty(t, problem.Detail)
+			if p.field == "" {
+				assert.Equal(t, "about:blank", problem.Type)
+				assert.Equal(t, http.StatusText(p.status), problem.Title)
+				assert.Empty(t, problem.Errors)
+				return
+			}
+			if p.status == http.StatusBadRequest {
+				assert.Equal(t, validationProblemType, problem.Type)
+				assert.Equal(t, "Validation failed", problem.Title)
+			} else {
+				assert.Equal(t, "about:blank", problem.Type)
+				assert.Equal(t, http.StatusText(p.status), problem.Title)
+			}
+			if assert.Len(t, problem.Errors, 1) {
+				assert.Equal(t, p.field, problem.Errors[0].Field)
+				assert.Contains(t, problem.Errors[0].Message, p.field)
+				assert.Equal(t, problem.Errors[0].Message, problem.Detail)
+			}
+		})
+	}
+}
+
+func TestWriteErrorFieldErrors(t *testing.T) {
+	tests := []struct {
+		name   string
+		err    error
+		status int
+		fields []dto.FieldErrorDTO
+		detail string
+	}{
+		{
+			name:   "invalid field",
+			err:    invalidField("ids", errors.New("must not be empty")),
+			status: http.StatusBadRequest,
+			fields: []dto.FieldErrorDTO{{Field: "ids", Message: "ids: must not be empty"}},
+			detail: "ids: must not be empty",
+		},
+		{
+			name:   "invalid field overrides the field of a known error",
+			err:    invalidField("new_price", entity.ErrInvalidPrice),
+			status: http.StatusBadRequest,
+			fields: []dto.FieldErrorDTO{{Field: "new_price", Message: "new_price: invalid price"}},
+			detail: "new_price: invalid price",
+		},
+		{
+			name: "validation error",
+			err: &validationError{fields: []dto.FieldErrorDTO{
+				{Field: "name", Message: "name is required"},
+				{Field: "price", Message: "price must be greater than 0"},
+			}},
+			status: http.StatusBadRequest,
+			fields: []dto.FieldErrorDTO{
+				{Field: "name", Message: "name is required"},
+				{Field: "price", Message: "price must be greater than 0"},
+			},
+			detail: "2 fields are invalid",
+		},
+		{
+			name: "wrong json type",
+			err: json.Unmarshal([]byte(`{"name":1}`), &struct {
+				Name string `json:"name"`
+			}{}),
+			status: http.StatusBadRequest,
+			fields: []dto.FieldErrorDTO{{Field: "name", Message: "name must be of type string"}},
+			detail: "name must be of type string",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w, problem := writeTestError(t, tt.err)
+
+			assert.Equal(t, tt.status, w.Code)
+			assert.Equal(t, problemContentType, w.Header().Get("Content-Type"))
+			assert.Equal(t, validationProblemType, problem.Type)
+			assert.Equal(t, "Validation failed", problem.Title)
+			assert.Equal(t, tt.status, problem.Status)
+			assert.Equal(t, tt.detail, problem.Detail)
+			assert.Equal(t, tt.fields, problem.Errors)
+		})
+	}
+}
+
+func TestWriteErrorNotFound(t *testing.T) {
+	w, problem := writeTestError(t, gorm.ErrRecordNotFound)
+
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	assert.Equal(t, "about:blank", problem.Type)
+	assert.Equal(t, "Not Found", problem.Title)
+	assert.Equal(t, http.StatusNotFound, problem.Status)
+}
+
+func TestWriteErrorHidesUnknownErrors(t *testing.T) {
+	err := errors.New("dial tcp 10.0.0.3:3306: connection refused")
+	w, problem := writeTestError(t, err)
+
+	assert.Equal(t, http.StatusInternalServerError, w.Code)
+	assert.Equal(t, problemContentType, w.Header().Get("Content-Type"))
+	assert.Equal(t, "about:blank", problem.Type)
+	assert.Equal(t, "Internal Server Error", problem.Title)
+	assert.Equal(t, http.StatusInternalServerError, problem.Status)
+	assert.Empty(t, problem.Detail)
+	assert.Empty(t, problem.Errors)
+	assert.False(t, strings.Contains(w.Body.String(), err.Error()))
+}
@@ -1,56 +1,69 @@
 package handlers
 
 import (
+	"bytes"
 	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
 	"net/http"
-	"strconv"
+	"net/url"
+	"time"
 
 	"github.com/brenoproti/go-api/internal/dto"
 	"github.com/brenoproti/go-api/internal/entity"
 	"github.com/brenoproti/go-api/internal/infra/database"
+	"github.com/brenoproti/go-api/pkg/cursor"
 	pkg "github.com/brenoproti/go-api/pkg/entity"
 	"github.com/go-chi/chi"
 )
 
 type ProductHandler struct {
 	ProductDB database.ProductInterface
+	Cursor    *cursor.Signer
 }
 
-func NewProductHandler(db database.ProductInterface) *ProductHandler {
+func NewProductHandler(db database.ProductInterface, cursor *cursor.Signer) *ProductHandler {
 	return &ProductHandler{
 		ProductDB: db,
+		Cursor:    cursor,
 	}
 }
 
 // Create product godoc
 // @Summary Create a new product
-// @Description Create a new product
+// @Description Create a new product owned by the user making the request
 // @Tags products
 // @Accept  json
 // @Produce  json
 // @Param request body dto.ProductDTO true "Product info"
 // @Success 201 {string} string	"Product created"
-// @Failure 400 {string} string "Bad request"
-// @Failure 401 {string} string	"Unauthorized"
-// @Failure 500 {string} string "Internal server error"
+// @Failure 400 {object} dto.ProblemDTO "Bad request"
+// @Failure 401 {object} dto.ProblemDTO "Unauthorized"
+// @Failure 500 {object} dto.ProblemDTO "Internal server error"
 // @Router /products [post]
 // @Security ApiKeyAuth
 func (h *ProductHandler) Create(w http.ResponseWriter, r *http.Request) {
 	var product dto.ProductDTO
-	err := json.NewDecoder(r.Body).Decode(&product)
+	err := decode(r, &product)
 	if err != nil {
-		w.WriteHeader(http.StatusBadRequest)
+		writeError(w, r, err)
+		return
+	}
+	userID, err := currentUser(r)
+	if err != nil {
+		writeError(w, r, err)
 		return
 	}
-	defer r.Body.Close()
 	entity, err := entity.NewProduct(product.Name, product.Price)
 	if err != nil {
-		w.WriteHeader(http.StatusBadRequest)
+		writeError(w, r, err)
 		return
 	}
+	entity.CreatedBy, entity.UpdatedBy = &userID, &userID
 	err = h.ProductDB.Create(entity)
 	if err != nil {
-		w.WriteHeader(http.StatusInternalServerError)
+		writeError(w, r, err)
 		return
 	}
 	w.Header().Add("id", entity.ID.String())
@@ -59,26 +72,35 @@ func (h *ProductHandler) Create(w http.ResponseWriter, r *http.Request) {
 
 // FindById product godoc
 // @Summary Find a product by ID
-// @Description Find a product by ID
+// @Description Find a product by ID. The response carries an ETag with the product version, and a matching
+// @Description If-None-Match header returns 304 Not Modified.
 // @Tags products
 // @Accept  json
 // @Produce  json
 // @Param id path string true "Product ID"
-// @Success 200 {object} dto.ProductDTO
-// @Failure 400 {string} string "Bad request"
-// @Failure 404 {string} string "Not found"
-// @Failure 500 {string} string "Internal server error"
+// @Param If-None-Match header string false "ETag of the cached product"
+// @Success 200 {object} entity.Product
+// @Success 304 {string} string "Not modified"
+// @Failure 400 {object} dto.ProblemDTO "Bad request"
+// @Failure 404 {object} dto.ProblemDTO "Not found"
+// @Failure 500 {object} dto.ProblemDTO "Internal server error"
 // @Router /products/{id} [get]
 // @Security ApiKeyAuth
 func (p *ProductHandler) FindById(w http.ResponseWriter, r *http.Request) {
 	id := chi.URLParam(r, "id")
 	if id == "" {
-		w.WriteHeader(http.StatusBadRequest)
+		writeError(w, r, entity.ErrIdIsRequired)
 		return
 	}
 	product, err := p.ProductDB.FindById(id)
 	if err != nil {
-		w.WriteHeader(http.StatusNotFound)
+		writeError(w, r, err)
+		return
+	}
+	etag := productETag(product)
+	w.Header().Set("ETag", etag)
+	if header := r.Header.Get("If-None-Match"); header != "" && etagMatches(header, etag, true) {
+		w.WriteHeader(http.StatusNotModified)
 		return
 	}
 	w.Header().Set("Content-Type", "application/json")
@@ -87,63 +109,193 @@ func (p *ProductHandler) FindById(w http.ResponseWriter, r *http.Request) {
 }
 
 // Update product godoc
-// @Summary Update a product
-// @Description Update a product
+// @Summary Replace a product
+// @Description Replace every field of a product. Missing fields are rejected rather than reset, use PATCH for partial updates.
+// @Description Only the owner of the product or an admin can change it.
 // @Tags products
 // @Accept  json
 // @Produce  json
 // @Param id path string true "Product ID"
+// @Param If-Match header string false "ETag the product must still have"
 // @Param request body dto.ProductDTO true "Product info"
 // @Success 200 {string} string	"Product updated"
-// @Failure 400 {string} string "Bad request"
-// @Failure 404 {string} string "Not found"
-// @Failure 500 {string} string "Internal server error"
+// @Failure 400 {object} dto.ProblemDTO "Bad request"
+// @Failure 403 {object} dto.ProblemDTO "Not the owner of the product"
+// @Failure 404 {object} dto.ProblemDTO "Not found"
+// @Failure 412 {object} dto.ProblemDTO "Product was modified since it was read"
+// @Failure 500 {object} dto.ProblemDTO "Internal server error"
 // @Router /products/{id} [put]
 // @Security ApiKeyAuth
 func (p *ProductHandler) Update(w http.ResponseWriter, r *http.Request) {
-	var product entity.Product
-	err := json.NewDecoder(r.Body).Decode(&product)
+	var product dto.ProductDTO
+	err := decodeStrict(r, &product)
 	if err != nil {
-		w.WriteHeader(http.StatusBadRequest)
+		writeError(w, r, err)
+		return
+	}
+	id, err := pkg.ParseID(chi.URLParam(r, "id"))
+	if err != nil {
+		writeError(w, r, entity.ErrInvalidId)
+		return
+	}
+	current, err := p.ProductDB.FindById(id.String())
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	userID, err := authorizeProductChange(r, current)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	version, ok := checkIfMatch(r, current)
+	if !ok {
+		writeError(w, r, database.ErrVersionConflict)
+		return
+	}
+	updated := &entity.Product{
+		ID:        id,
+		Name:      product.Name,
+		Price:     product.Price,
+		Version:   version,
+		UpdatedBy: &userID,
+	}
+	err = p.ProductDB.Update(updated)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	w.Header().Set("ETag", productETag(updated))
+	w.WriteHeader(http.StatusOK)
+}
+
+// Patch product godoc
+// @Summary Partially update a product
+// @Description Apply an RFC 7396 merge patch (application/merge-patch+json or application/json) or an RFC 6902
+// @Description JSON patch (application/json-patch+json) to a product. The result is validated before being saved.
+// @Description Only the owner of the product or an admin can change it.
+// @Tags products
+// @Accept  json
+// @Accept  application/merge-patch+json
+// @Accept  application/json-patch+json
+// @Produce  json
+// @Param id path string true "Product ID"
+// @Param If-Match header string false "ETag the product must still have"
+// @Param request body object true "Merge patch or JSON patch"
+// @Success 200 {object} entity.Product
+// @Failure 400 {object} dto.ProblemDTO "Bad request"
+// @Failure 403 {object} dto.ProblemDTO "Not the owner of the product"
+// @Failure 404 {object} dto.ProblemDTO "Not found"
+// @Failure 409 {object} dto.ProblemDTO "JSON patch test operation failed"
+// @Failure 412 {object} dto.ProblemDTO "Product was modified since it was read"
+// @Failure 415 {object} dto.ProblemDTO "Unsupported patch content type"
+// @Failure 500 {object} dto.ProblemDTO "Internal server error"
+// @Router /products/{id} [patch]
+// @Security ApiKeyAuth
+func (p *ProductHandler) Patch(w http.ResponseWriter, r *http.Request) {
+	patch, err := io.ReadAll(r.Body)
+	if err != nil {
+		writeError(w, r, err)
 		return
 	}
 	defer r.Body.Close()
-	id := chi.URLParam(r, "id")
-	product.ID, err = pkg.ParseID(id)
+	product, err := p.ProductDB.FindById(chi.URLParam(r, "id"))
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	userID, err := authorizeProductChange(r, product)
 	if err != nil {
-		w.WriteHeader(http.StatusBadRequest)
+		writeError(w, r, err)
+		return
+	}
+	if _, ok := checkIfMatch(r, product); !ok {
+		writeError(w, r, database.ErrVersionConflict)
 		return
 	}
-	err = p.ProductDB.Update(&product)
+	original, err := json.Marshal(product)
 	if err != nil {
-		w.WriteHeader(http.StatusNotFound)
+		writeError(w, r, err)
 		return
 	}
+	patched, err := applyPatch(r.Header.Get("Content-Type"), original, patch)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	var updated entity.Product
+	decoder := json.NewDecoder(bytes.NewReader(patched))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&updated); err != nil {
+		// values the fields cannot hold, such as an id that is not a UUID,
+		// come from the patch rather than from the server
+		if !knownError(err) {
+			err = fmt.Errorf("%w: %v", errInvalidPatch, err)
+		}
+		writeError(w, r, err)
+		return
+	}
+	if fields := readOnlyProductFields(product, &updated); len(fields) > 0 {
+		writeProblem(w, r, http.StatusBadRequest, "", fields...)
+		return
+	}
+	if fields := dto.Validate(dto.ProductDTO{Name: updated.Name, Price: updated.Price}); len(fields) > 0 {
+		writeProblem(w, r, http.StatusBadRequest, "", fields...)
+		return
+	}
+	updated.UpdatedBy = &userID
+	// the patch applies to the version just read, so a concurrent change
+	// fails instead of being overwritten
+	if err := p.ProductDB.Update(&updated); err != nil {
+		writeError(w, r, err)
+		return
+	}
+	w.Header().Set("ETag", productETag(&updated))
+	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(updated)
 }
 
 // Delete product godoc
 // @Summary Delete a product
-// @Description Delete a product
+// @Description Move a product to the trash. It can be restored until it is purged after the retention period.
+// @Description Only the owner of the product or an admin can delete it.
 // @Tags products
 // @Accept  json
 // @Produce  json
 // @Param id path string true "Product ID"
+// @Param If-Match header string false "ETag the product must still have"
 // @Success 200 {string} string	"Product deleted"
-// @Failure 400 {string} string "Bad request"
-// @Failure 404 {string} string "Not found"
-// @Failure 500 {string} string "Internal server error"
+// @Failure 400 {object} dto.ProblemDTO "Bad request"
+// @Failure 403 {object} dto.ProblemDTO "Not the owner of the product"
+// @Failure 404 {object} dto.ProblemDTO "Not found"
+// @Failure 412 {object} dto.ProblemDTO "Product was modified since it was read"
+// @Failure 500 {object} dto.ProblemDTO "Internal server error"
 // @Router /products/{id} [delete]
 // @Security ApiKeyAuth
 func (p *ProductHandler) Delete(w http.ResponseWriter, r *http.Request) {
 	id := chi.URLParam(r, "id")
 	if id == "" {
-		w.WriteHeader(http.StatusBadRequest)
+		writeError(w, r, entity.ErrIdIsRequired)
 		return
 	}
-	err := p.ProductDB.Delete(id)
+	product, err := p.ProductDB.FindById(id)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	if _, err := authorizeProductChange(r, product); err != nil {
+		writeError(w, r, err)
+		return
+	}
+	version, ok := checkIfMatch(r, product)
+	if !ok {
+		writeError(w, r, database.ErrVersionConflict)
+		return
+	}
+	err = p.ProductDB.Delete(id, version)
 	if err != nil {
-		w.WriteHeader(http.StatusNotFound)
+		writeError(w, r, err)
 		return
 	}
 	w.WriteHeader(http.StatusOK)
@@ -151,36 +303,315 @@ func (p *ProductHandler) Delete(w http.ResponseWriter, r *http.Request) {
 
 // GetProducts godoc
 // @Summary Get products paginated
-// @Description Get products paginated
+// @Description Get products paginated. Passing the cursor parameter, empty for the first page, switches to keyset
+// @Description pagination ordered by creation, which stays consistent while products are added and returns next_cursor.
 // @Tags products
 // @Accept  json
 // @Produce  json
 // @Param page query int false "Page number"
-// @Param limit query int false "Limit per page"
-// @Param sort query string false "Sort by field"
-// @Success 200 {array} string
-// @Failure 400 {string} string "Bad request"
-// @Failure 500 {string} string "Internal server error"
+// @Param cursor query string false "Opaque cursor returned as next_cursor by the previous page"
+// @Param limit query int false "Limit per page, 20 by default and at most 100"
+// @Param sort query string false "Comma separated fields among name, price and created_at, prefixed with - for descending order (e.g. -price,name)"
+// @Param name query string false "Only products whose name contains this text"
+// @Param currency query string false "Only products priced in this ISO-4217 currency, required with price filters"
+// @Param price_gte query string false "Minimum price (e.g. 10.50)"
+// @Param price_lte query string false "Maximum price (e.g. 99.90)"
+// @Param created_gte query string false "Created at or after this RFC 3339 time or date"
+// @Param created_lte query string false "Created at or before this RFC 3339 time or date"
+// @Param category query string false "Only products in this category"
+// @Param include_descendants query bool false "Also match products in subcategories of category"
+// @Param mine query bool false "Only products created by the user making the request"
+// @Success 200 {object} dto.PageDTO{data=[]entity.Product}
+// @Failure 400 {object} dto.ProblemDTO "Bad request"
+// @Failure 500 {object} dto.ProblemDTO "Internal server error"
 // @Router /products [get]
 // @Security ApiKeyAuth
 func (p *ProductHandler) GetProducts(w http.ResponseWriter, r *http.Request) {
-	queryPage := r.URL.Query().Get("page")
-	queryLimit := r.URL.Query().Get("limit")
+	page, limit := pageParams(r)
 	sort := r.URL.Query().Get("sort")
-	page, err := strconv.Atoi(queryPage)
+	filter, err := productFilter(r.URL.Query())
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	if r.URL.Query().Get("mine") == "true" {
+		userID, err := currentUser(r)
+		if err != nil {
+			writeError(w, r, err)
+			return
+		}
+		filter.CreatedBy = userID.String()
+	}
+	if r.URL.Query().Has("cursor") {
+		p.getProductsAfter(w, r, limit, filter)
+		return
+	}
+	products, err := p.ProductDB.FindAll(page, limit, sort, filter)
 	if err != nil {
-		page = 0
+		writeError(w, r, err)
+		return
 	}
-	limit, err := strconv.Atoi(queryLimit)
+	total, err := p.ProductDB.Count(filter)
 	if err != nil {
-		limit = 0
+		writeError(w, r, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(newPage(r, products, total, page, limit))
+}
+
+func (p *ProductHandler) getProductsAfter(w http.ResponseWriter, r *http.Request, limit int, filter database.ProductFilter) {
+	var after *database.ProductCursor
+	if c := r.URL.Query().Get("cursor"); c != "" {
+		after = &database.ProductCursor{}
+		if err := p.Cursor.Decode(c, after); err != nil {
+			writeError(w, r, err)
+			return
+		}
 	}
-	products, err := p.ProductDB.FindAll(page, limit, sort)
+	products, next, err := p.ProductDB.FindAfter(after, limit, filter)
 	if err != nil {
-		w.WriteHeader(http.StatusInternalServerError)
+		writeError(w, r, err)
 		return
 	}
+	page := dto.CursorPageDTO{Limit: limit}
+	if next != nil {
+		encoded, err := p.Cursor.Encode(next)
+		if err != nil {
+			writeError(w, r, err)
+			return
+		}
+		page.NextCursor = &encoded
+	}
+	if products == nil {
+		products = []entity.Product{}
+	}
+	page.Data = products
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusOK)
-	json.NewEncoder(w).Encode(products)
+	json.NewEncoder(w).Encode(page)
+}
+
+// GetTrash godoc
+// @Summary List deleted products
+// @Description List the products in the trash, most recently deleted first. They can be restored until they are purged.
+// @Tags products
+// @Accept  json
+// @Produce  json
+// @Param page query int false "Page number"
+// @Param limit query int false "Limit per page, 20 by default and at most 100"
+// @Success 200 {object} dto.PageDTO{data=[]entity.Product}
+// @Failure 500 {object} dto.ProblemDTO "Internal server error"
+// @Router /products/trash [get]
+// @Security ApiKeyAuth
+func (p *ProductHandler) GetTrash(w http.ResponseWriter, r *http.Request) {
+	page, limit := pageParams(r)
+	products, err := p.ProductDB.FindDeleted(page, limit)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	total, err := p.ProductDB.CountDeleted()
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(newPage(r, products, total, page, limit))
+}
+
+// Restore product godoc
+// @Summary Restore a deleted product
+// @Description Take a product out of the trash
+// @Tags products
+// @Accept  json
+// @Produce  json
+// @Param id path string true "Product ID"
+// @Success 200 {object} entity.Product
+// @Failure 404 {object} dto.ProblemDTO "Not found"
+// @Failure 412 {object} dto.ProblemDTO "Product was modified concurrently"
+// @Failure 500 {object} dto.ProblemDTO "Internal server error"
+// @Router /products/{id}/restore [post]
+// @Security ApiKeyAuth
+func (p *ProductHandler) Restore(w http.ResponseWriter, r *http.Request) {
+	product, err := p.ProductDB.Restore(chi.URLParam(r, "id"))
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	w.Header().Set("ETag", productETag(product))
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(product)
+}
+
+// GetCategories product godoc
+// @Summary List the categories of a product
+// @Description List the categories of a product
+// @Tags products
+// @Accept  json
+// @Produce  json
+// @Param id path string true "Product ID"
+// @Success 200 {array} entity.Category
+// @Failure 404 {object} dto.ProblemDTO "Not found"
+// @Router /products/{id}/categories [get]
+// @Security ApiKeyAuth
+func (p *ProductHandler) GetCategories(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+	categories, err := p.ProductDB.FindCategories(id)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(categories)
+}
+
+// SetCategories product godoc
+// @Summary Set the categories of a product
+// @Description Replace the categories a product belongs to. Only the owner of the product or an admin can change them.
+// @Tags products
+// @Accept  json
+// @Produce  json
+// @Param id path string true "Product ID"
+// @Param request body dto.ProductCategoriesDTO true "Category IDs"
+// @Success 200 {string} string	"Categories updated"
+// @Failure 400 {object} dto.ProblemDTO "Bad request"
+// @Failure 403 {object} dto.ProblemDTO "Not the owner of the product"
+// @Failure 404 {object} dto.ProblemDTO "Not found"
+// @Failure 500 {object} dto.ProblemDTO "Internal server error"
+// @Router /products/{id}/categories [put]
+// @Security ApiKeyAuth
+func (p *ProductHandler) SetCategories(w http.ResponseWriter, r *http.Request) {
+	var categories dto.ProductCategoriesDTO
+	err := decode(r, &categories)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	id := chi.URLParam(r, "id")
+	product, err := p.ProductDB.FindById(id)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	if _, err := authorizeProductChange(r, product); err != nil {
+		writeError(w, r, err)
+		return
+	}
+	err = p.ProductDB.SetCategories(id, categories.CategoryIDs)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+}
+
+// readOnlyProductFields lists the fields a patch changed although they are
+// managed by the server.
+func readOnlyProductFields(product, patched *entity.Product) []dto.FieldErrorDTO {
+	changed := map[string]bool{
+		"id":         patched.ID != product.ID,
+		"version":    patched.Version != product.Version,
+		"created_at": !patched.CreatedAt.Equal(product.CreatedAt),
+		"updated_at": !patched.UpdatedAt.Equal(product.UpdatedAt),
+		"deleted_at": patched.DeletedAt.Valid,
+		"created_by": !sameUser(patched.CreatedBy, product.CreatedBy),
+		"updated_by": !sameUser(patched.UpdatedBy, product.UpdatedBy),
+	}
+	var fields []dto.FieldErrorDTO
+	for _, field := range []string{"id", "version", "created_at", "updated_at", "deleted_at", "created_by", "updated_by"} {
+		if changed[field] {
+			fields = append(fields, dto.FieldErrorDTO{Field: field, Message: field + " is read-only"})
+		}
+	}
+	return fields
+}
+
+func sameUser(a, b *pkg.ID) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return *a == *b
+}
+
+// authorizeProductChange returns the ID of the user making the request,
+// provided they own the product or are an admin.
+func authorizeProductChange(r *http.Request, product *entity.Product) (pkg.ID, error) {
+	userID, err := currentUser(r)
+	if err != nil {
+		return pkg.ID{}, err
+	}
+	role, err := currentRole(r)
+	if err != nil {
+		return pkg.ID{}, err
+	}
+	if !product.IsOwnedBy(userID) && !role.Includes(entity.RoleAdmin) {
+		return pkg.ID{}, errForbidden
+	}
+	return userID, nil
+}
+
+var (
+	errPriceFilterWithoutCurrency = errors.New("price filters require a currency")
+	errInvalidTime                = errors.New("must be an RFC 3339 time or a date")
+)
+
+func productFilter(query url.Values) (database.ProductFilter, error) {
+	filter := database.ProductFilter{
+		Name:               query.Get("name"),
+		Currency:           query.Get("currency"),
+		CategoryID:         query.Get("category"),
+		IncludeDescendants: query.Get("include_descendants") == "true",
+	}
+	for param, bound := range map[string]**pkg.Money{"price_gte": &filter.PriceGte, "price_lte": &filter.PriceLte} {
+		value := query.Get(param)
+		if value == "" {
+			continue
+		}
+		if filter.Currency == "" {
+			return filter, invalidField("currency", errPriceFilterWithoutCurrency)
+		}
+		price, err := pkg.ParseMoney(value, filter.Currency)
+		if errors.Is(err, pkg.ErrInvalidCurrency) {
+			return filter, invalidField("currency", err)
+		}
+		if err != nil {
+			return filter, invalidField(param, err)
+		}
+		*bound = &price
+	}
+	if value := query.Get("created_gte"); value != "" {
+		t, _, err := parseTime(value)
+		if err != nil {
+			return filter, invalidField("created_gte", errInvalidTime)
+		}
+		filter.CreatedGte = &t
+	}
+	if value := query.Get("created_lte"); value != "" {
+		t, dateOnly, err := parseTime(value)
+		if err != nil {
+			return filter, invalidField("created_lte", errInvalidTime)
+		}
+		// a date alone includes the whole day
+		if dateOnly {
+			t = t.AddDate(0, 0, 1)
+		} else {
+			t = t.Add(time.Nanosecond)
+		}
+		filter.CreatedLt = &t
+	}
+	return filter, nil
+}
+
+// parseTime accepts either an RFC 3339 time or a plain date.
+func parseTime(value string) (t time.Time, dateOnly bool, err error) {
+	if t, err = time.Parse(time.RFC3339, value); err == nil {
+		return t, false, nil
+	}
+	t, err = time.Parse(time.DateOnly, value)
+	return t, true, err
 }
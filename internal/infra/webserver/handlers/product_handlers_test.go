@@ -0,0 +1,320 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/brenoproti/go-api/internal/dto"
+	"github.com/brenoproti/go-api/internal/entity"
+	"github.com/brenoproti/go-api/internal/infra/database"
+	pkg "github.com/brenoproti/go-api/pkg/entity"
+	"github.com/go-chi/chi"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+// newProductTestRouter serves the product routes over a fresh database
+// holding one product, and returns a token of the user owning it.
+func newProductTestRouter(t *testing.T) (http.Handler, *database.ProductDB, *entity.Product, string) {
+	db, err := gorm.Open(sqlite.Open("file::memory:"), &gorm.Config{})
+	assert.Nil(t, err)
+	db.AutoMigrate(&entity.Product{}, &entity.Reservation{})
+	productDB := database.NewProductDB(db)
+	owner := pkg.NewID()
+	product, err := entity.NewProduct("Product 1", pkg.NewMoney(1050, "USD"))
+	assert.Nil(t, err)
+	product.CreatedBy, product.UpdatedBy = &owner, &owner
+	assert.Nil(t, productDB.Create(product))
+
+	keys := newTestKeys(t)
+	h := NewProductHandler(productDB, nil)
+	r := chi.NewRouter()
+	r.Use(Verifier(keys), Authenticator)
+	r.Get("/products/{id}", h.FindById)
+	r.Put("/products/{id}", h.Update)
+	r.Patch("/products/{id}", h.Patch)
+	r.Delete("/products/{id}", h.Delete)
+	return r, productDB, product, newTestToken(t, keys, owner.String(), entity.RoleEditor)
+}
+
+// patchProduct sends a raw patch, so that its content type can be chosen.
+func patchProduct(r http.Handler, product *entity.Product, token, contentType, patch string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPatch, "/products/"+product.ID.String(), strings.NewReader(patch))
+	req.Header.Set("Authorization", "Bearer "+token)
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w
+}
+
+// serveConditional sends a request carrying a precondition header, left out
+// when etag is empty.
+func serveConditional(r http.Handler, method string, product *entity.Product, token, header, etag string, body interface{}) *httptest.ResponseRecorder {
+	var payload bytes.Buffer
+	if body != nil {
+		json.NewEncoder(&payload).Encode(body)
+	}
+	req := httptest.NewRequest(method, "/products/"+product.ID.String(), &payload)
+	req.Header.Set("Authorization", "Bearer "+token)
+	if etag != "" {
+		req.Header.Set(header, etag)
+	}
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w
+}
+
+func decodeProblem(t *testing.T, w *httptest.ResponseRecorder) dto.ProblemDTO {
+	t.Helper()
+	assert.Equal(t, problemContentType, w.Header().Get("Content-Type"))
+	var problem dto.ProblemDTO
+	assert.Nil(t, json.NewDecoder(w.Body).Decode(&problem))
+	return problem
+}
+
+func problemFields(problem dto.ProblemDTO) []string {
+	var fields []string
+	for _, f := range problem.Errors {
+		fields = append(fields, f.Field)
+	}
+	return fields
+}
+
+func TestPatchProductContentTypes(t *testing.T) {
+	tests := []struct {
+		name        string
+		contentType string
+		patch       string
+		status      int
+		want        string
+	}{
+		{"merge patch", mergePatchContentType, `{"name":"Merged"}`, http.StatusOK, "Merged"},
+		{"merge patch with charset", mergePatchContentType + "; charset=utf-8", `{"name":"Charset"}`, http.StatusOK, "Charset"},
+		{"plain json is a merge patch", "application/json", `{"name":"Plain"}`, http.StatusOK, "Plain"},
+		{"no content type is a merge patch", "", `{"name":"Bare"}`, http.StatusOK, "Bare"},
+		{"json patch", jsonPatchContentType, `[{"op":"replace","path":"/name","value":"Replaced"}]`, http.StatusOK, "Replaced"},
+		{"unsupported", "text/plain", `{"name":"Text"}`, http.StatusUnsupportedMediaType, ""},
+		{"unparsable content type", "application/", `{"name":"Broken"}`, http.StatusUnsupportedMediaType, ""},
+		{"json patch that is not a list", jsonPatchContentType, `{"name":"Wrong"}`, http.StatusBadRequest, ""},
+		{"malformed merge patch", mergePatchContentType, `{"name":`, http.StatusBadRequest, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, productDB, product, token := newProductTestRouter(t)
+
+			w := patchProduct(r, product, token, tt.contentType, tt.patch)
+
+			assert.Equal(t, tt.status, w.Code, w.Body.String())
+			stored, err := productDB.FindById(product.ID.String())
+			assert.Nil(t, err)
+			if tt.status != http.StatusOK {
+				assert.Equal(t, tt.status, decodeProblem(t, w).Status)
+				assert.Equal(t, "Product 1", stored.Name)
+				assert.Equal(t, product.Version, stored.Version)
+				return
+			}
+			var patched entity.Product
+			assert.Nil(t, json.NewDecoder(w.Body).Decode(&patched))
+			assert.Equal(t, tt.want, patched.Name)
+			assert.Equal(t, product.Price, patched.Price)
+			assert.Equal(t, tt.want, stored.Name)
+			assert.Equal(t, product.Price, stored.Price)
+			assert.Equal(t, product.Version+1, stored.Version)
+		})
+	}
+}
+
+func TestPatchProductRejectsReadOnlyFields(t *testing.T) {
+	tests := []struct {
+		name        string
+		contentType string
+		patch       string
+		fields      []string
+	}{
+		{"id", mergePatchContentType, `{"id":"` + pkg.NewID().String() + `"}`, []string{"id"}},
+		{"version", mergePatchContentType, `{"version":42}`, []string{"version"}},
+		{"created_by", mergePatchContentType, `{"created_by":"` + pkg.NewID().String() + `"}`, []string{"created_by"}},
+		{"created_by removed", mergePatchContentType, `{"created_by":null}`, []string{"created_by"}},
+		{"updated_by", jsonPatchContentType, `[{"op":"replace","path":"/updated_by","value":"` + pkg.NewID().String() + `"}]`, []string{"updated_by"}},
+		{"created_at", jsonPatchContentType, `[{"op":"replace","path":"/created_at","value":"2000-01-01T00:00:00Z"}]`, []string{"created_at"}},
+		{"deleted_at", mergePatchContentType, `{"deleted_at":"2000-01-01T00:00:00Z"}`, []string{"deleted_at"}},
+		{
+			"several fields along with an allowed one", mergePatchContentType,
+			`{"name":"Renamed","id":"` + pkg.NewID().String() + `","version":42,"created_by":"` + pkg.NewID().String() + `"}`,
+			[]string{"id", "version", "created_by"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, productDB, product, token := newProductTestRouter(t)
+
+			w := patchProduct(r, product, token, tt.contentType, tt.patch)
+
+			assert.Equal(t, http.StatusBadRequest, w.Code, w.Body.String())
+			problem := decodeProblem(t, w)
+			assert.Equal(t, validationProblemType, problem.Type)
+			assert.Equal(t, tt.fields, problemFields(problem))
+			for _, f := range problem.Errors {
+				assert.Equal(t, f.Field+" is read-only", f.Message)
+			}
+			stored, err := productDB.FindById(product.ID.String())
+			assert.Nil(t, err)
+			assert.Equal(t, "Product 1", stored.Name)
+			assert.Equal(t, product.Version, stored.Version)
+			assert.Equal(t, product.CreatedBy, stored.CreatedBy)
+		})
+	}
+}
+
+func TestPatchProductTestOperation(t *testing.T) {
+	r, productDB, product, token := newProductTestRouter(t)
+
+	w := patchProduct(r, product, token, jsonPatchContentType,
+		`[{"op":"test","path":"/name","value":"Someone else's change"},{"op":"replace","path":"/name","value":"Renamed"}]`)
+	assert.Equal(t, http.StatusConflict, w.Code, w.Body.String())
+	assert.Equal(t, http.StatusConflict, decodeProblem(t, w).Status)
+	stored, err := productDB.FindById(product.ID.String())
+	assert.Nil(t, err)
+	assert.Equal(t, "Product 1", stored.Name)
+
+	w = patchProduct(r, product, token, jsonPatchContentType,
+		`[{"op":"test","path":"/name","value":"Product 1"},{"op":"replace","path":"/name","value":"Renamed"}]`)
+	assert.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	stored, err = productDB.FindById(product.ID.String())
+	assert.Nil(t, err)
+	assert.Equal(t, "Renamed", stored.Name)
+}
+
+func TestPatchProductValidatesResult(t *testing.T) {
+	tests := []struct {
+		name        string
+		contentType string
+		patch       string
+		fields      []string
+	}{
+		{"empty name", mergePatchContentType, `{"name":""}`, []string{"name"}},
+		{"name too long", mergePatchContentType, `{"name":"` + strings.Repeat("a", 256) + `"}`, []string{"name"}},
+		{"price removed", mergePatchContentType, `{"price":null}`, []string{"price"}},
+		{"negative price", mergePatchContentType, `{"price":{"amount":"-1.00","currency":"USD"}}`, []string{"price"}},
+		{"name removed", jsonPatchContentType, `[{"op":"remove","path":"/name"}]`, []string{"name"}},
+		{"zero price", jsonPatchContentType, `[{"op":"replace","path":"/price/amount","value":"0"}]`, []string{"price"}},
+		{"both", mergePatchContentType, `{"name":"","price":null}`, []string{"name", "price"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, productDB, product, token := newProductTestRouter(t)
+
+			w := patchProduct(r, product, token, tt.contentType, tt.patch)
+
+			assert.Equal(t, http.StatusBadRequest, w.Code, w.Body.String())
+			problem := decodeProblem(t, w)
+			assert.Equal(t, validationProblemType, problem.Type)
+			assert.Equal(t, tt.fields, problemFields(problem))
+			stored, err := productDB.FindById(product.ID.String())
+			assert.Nil(t, err)
+			assert.Equal(t, "Product 1", stored.Name)
+			assert.Equal(t, product.Price, stored.Price)
+			assert.Equal(t, product.Version, stored.Version)
+		})
+	}
+}
+
+func TestFindProductETag(t *testing.T) {
+	r, productDB, product, token := newProductTestRouter(t)
+
+	w := serveConditional(r, http.MethodGet, product, token, "", "", nil)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, `"1"`, w.Header().Get("ETag"))
+	var found entity.Product
+	assert.Nil(t, json.NewDecoder(w.Body).Decode(&found))
+	assert.Equal(t, int64(1), found.Version)
+
+	tests := []struct {
+		ifNoneMatch string
+		status      int
+	}{
+		{`"1"`, http.StatusNotModified},
+		{`W/"1"`, http.StatusNotModified},
+		{`"0", "1"`, http.StatusNotModified},
+		{`*`, http.StatusNotModified},
+		{`"2"`, http.StatusOK},
+		{`"1"x`, http.StatusOK},
+	}
+	for _, tt := range tests {
+		w := serveConditional(r, http.MethodGet, product, token, "If-None-Match", tt.ifNoneMatch, nil)
+		assert.Equal(t, tt.status, w.Code, tt.ifNoneMatch)
+		assert.Equal(t, `"1"`, w.Header().Get("ETag"), tt.ifNoneMatch)
+		if tt.status == http.StatusNotModified {
+			assert.Empty(t, w.Body.String(), tt.ifNoneMatch)
+		}
+	}
+
+	assert.Nil(t, productDB.Update(&entity.Product{ID: product.ID, Name: "Changed", Price: product.Price}))
+	w = serveConditional(r, http.MethodGet, product, token, "If-None-Match", `"1"`, nil)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, `"2"`, w.Header().Get("ETag"))
+}
+
+func TestChangeProductIfMatch(t *testing.T) {
+	changes := []struct {
+		method string
+		body   interface{}
+	}{
+		{http.MethodPut, dto.ProductDTO{Name: "Renamed", Price: pkg.NewMoney(2000, "USD")}},
+		{http.MethodPatch, map[string]string{"name": "Renamed"}},
+		{http.MethodDelete, nil},
+	}
+	// the product is at version 2 when the request is sent
+	tests := []struct {
+		name    string
+		ifMatch string
+		status  int
+	}{
+		{"current version", `"2"`, 0},
+		{"one of the listed versions", `"1", "2"`, 0},
+		{"any version", `*`, 0},
+		// If-Match is optional: without it the change applies to whatever
+		// version is stored
+		{"no precondition", "", 0},
+		{"stale version", `"1"`, http.StatusPreconditionFailed},
+		{"weak tag", `W/"2"`, http.StatusPreconditionFailed},
+	}
+	for _, change := range changes {
+		for _, tt := range tests {
+			t.Run(change.method+" "+tt.name, func(t *testing.T) {
+				r, productDB, product, token := newProductTestRouter(t)
+				// a concurrent change another client did not see
+				assert.Nil(t, productDB.Update(&entity.Product{ID: product.ID, Name: "Changed", Price: product.Price}))
+
+				w := serveConditional(r, change.method, product, token, "If-Match", tt.ifMatch, change.body)
+
+				if tt.status != 0 {
+					assert.Equal(t, tt.status, w.Code, w.Body.String())
+					assert.Equal(t, tt.status, decodeProblem(t, w).Status)
+					stored, err := productDB.FindById(product.ID.String())
+					assert.Nil(t, err)
+					assert.Equal(t, "Changed", stored.Name)
+					assert.Equal(t, int64(2), stored.Version)
+					return
+				}
+				assert.Equal(t, http.StatusOK, w.Code, w.Body.String())
+				stored, err := productDB.FindById(product.ID.String())
+				if change.method == http.MethodDelete {
+					assert.ErrorIs(t, err, gorm.ErrRecordNotFound)
+					return
+				}
+				assert.Nil(t, err)
+				assert.Equal(t, "Renamed", stored.Name)
+				assert.Equal(t, int64(3), stored.Version)
+				assert.Equal(t, `"3"`, w.Header().Get("ETag"))
+			})
+		}
+	}
+}
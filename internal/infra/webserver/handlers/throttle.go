@@ -0,0 +1,59 @@
+package handlers
+
+import (
+	"log"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/brenoproti/go-api/internal/entity"
+	"github.com/brenoproti/go-api/internal/infra/database"
+	pkg "github.com/brenoproti/go-api/pkg/entity"
+)
+
+// reserveAttempt records an attempt with the email from the client before it
+// is carried out, and tells how long it has to wait because of the recent
+// attempts it is throttled on. Reserving first keeps concurrent attempts from
+// all being carried out, see entity.LoginPending.
+func reserveAttempt(attempts database.LoginAttemptInterface, throttle entity.LoginThrottle, r *http.Request, email string, userID *pkg.ID, result entity.LoginResult) (*entity.LoginAttempt, time.Duration, error) {
+	attempt, err := entity.NewLoginAttempt(email, clientIP(r), r.UserAgent(), userID, result)
+	if err != nil {
+		return nil, 0, err
+	}
+	now := time.Now()
+	byEmail, byIP, err := attempts.Reserve(attempt, throttle.Since(now))
+	if err != nil {
+		return nil, 0, err
+	}
+	return attempt, throttle.RetryAfter(byEmail, byIP, now), nil
+}
+
+// resolveAttempt records how a reserved attempt went.
+func resolveAttempt(attempts database.LoginAttemptInterface, attempt *entity.LoginAttempt, userID *pkg.ID, result entity.LoginResult) error {
+	attempt.UserID = userID
+	attempt.Result = result
+	return attempts.Resolve(attempt)
+}
+
+// writeThrottled records that a reserved attempt was throttled and responds
+// with the error and how long to wait.
+func writeThrottled(w http.ResponseWriter, r *http.Request, attempts database.LoginAttemptInterface, attempt *entity.LoginAttempt, wait time.Duration, err error) {
+	if err := resolveAttempt(attempts, attempt, attempt.UserID, entity.LoginThrottled); err != nil {
+		writeError(w, r, err)
+		return
+	}
+	w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+	writeError(w, r, err)
+}
+
+// inBackground carries out the rest of a request after it was answered, so
+// that how long it takes does not tell whether an email belongs to a user.
+// Errors can only be logged.
+func inBackground(name string, task func() error) {
+	go func() {
+		if err := task(); err != nil {
+			log.Printf("%s: %v", name, err)
+		}
+	}()
+}
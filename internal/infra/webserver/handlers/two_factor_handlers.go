@@ -0,0 +1,377 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"image/png"
+	"net/http"
+	"time"
+
+	"github.com/brenoproti/go-api/internal/dto"
+	"github.com/brenoproti/go-api/internal/entity"
+	"github.com/brenoproti/go-api/internal/infra/database"
+	"gorm.io/gorm"
+)
+
+// qrCodeSize is the width and height of the QR codes, in pixels.
+const qrCodeSize = 256
+
+type TwoFactorHandler struct {
+	UserDB database.UserInterface
+	TOTPDB database.TOTPInterface
+	// Issuer is the name authenticator apps list the account under.
+	Issuer string
+	// ChallengeExpiresIn is how many seconds users have to type in a code
+	// once they gave their password.
+	ChallengeExpiresIn int
+	// RequiredForAdmins keeps admins who logged in without a second factor
+	// from acting as admins, see role.
+	RequiredForAdmins bool
+}
+
+func NewTwoFactorHandler(userDB database.UserInterface, totpDB database.TOTPInterface, issuer string, challengeExpiresIn int, requiredForAdmins bool) *TwoFactorHandler {
+	return &TwoFactorHandler{
+		UserDB:             userDB,
+		TOTPDB:             totpDB,
+		Issuer:             issuer,
+		ChallengeExpiresIn: challengeExpiresIn,
+		RequiredForAdmins:  requiredForAdmins,
+	}
+}
+
+// Status godoc
+// @Summary Get the two-factor authentication status
+// @Description Tell whether the current user logs in with a second factor, and how many recovery codes they have left
+// @Tags users
+// @Accept  json
+// @Produce  json
+// @Success 200 {object} dto.TwoFactorStatusDTO
+// @Failure 401 {object} dto.ProblemDTO "Unauthorized"
+// @Failure 500 {object} dto.ProblemDTO "Internal server error"
+// @Router /users/me/2fa [get]
+// @Security ApiKeyAuth
+func (h *TwoFactorHandler) Status(w http.ResponseWriter, r *http.Request) {
+	id, err := currentUserID(r)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	var status dto.TwoFactorStatusDTO
+	secret, err := h.TOTPDB.FindByUser(id)
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		writeError(w, r, err)
+		return
+	}
+	if err == nil && secret.IsConfirmed() {
+		status.Enabled = true
+		status.ConfirmedAt = secret.ConfirmedAt
+		status.RecoveryCodesLeft, err = h.TOTPDB.CountRecoveryCodes(id)
+		if err != nil {
+			writeError(w, r, err)
+			return
+		}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(status)
+}
+
+// Enroll godoc
+// @Summary Start setting up an authenticator app
+// @Description Generate a new TOTP secret for the current user, to add to an authenticator app with the otpauth:// URL
+// @Description or its QR code. Logins only ask for codes once the secret is confirmed with one. Enrolling again before
+// @Description that replaces the secret.
+// @Tags users
+// @Accept  json
+// @Produce  json
+// @Success 201 {object} dto.TOTPEnrollmentDTO
+// @Failure 401 {object} dto.ProblemDTO "Unauthorized"
+// @Failure 404 {object} dto.ProblemDTO "Not found"
+// @Failure 409 {object} dto.ProblemDTO "Two-factor authentication already enabled"
+// @Failure 500 {object} dto.ProblemDTO "Internal server error"
+// @Router /users/me/2fa/totp [post]
+// @Security ApiKeyAuth
+func (h *TwoFactorHandler) Enroll(w http.ResponseWriter, r *http.Request) {
+	id, err := currentUserID(r)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	user, err := h.UserDB.FindById(id)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	secret, err := entity.NewTOTPSecret(user.ID)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	if err := h.TOTPDB.Enroll(secret); err != nil {
+		writeError(w, r, err)
+		return
+	}
+	key, err := secret.Key(h.Issuer, user.Email)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(dto.TOTPEnrollmentDTO{
+		Secret:     key.Secret(),
+		OTPAuthURL: key.URL(),
+	})
+}
+
+// QRCode godoc
+// @Summary Get the QR code of the authenticator app secret
+// @Description Get the otpauth:// URL of the secret being set up as a PNG QR code, to scan with an authenticator app.
+// @Description It is only available until the secret is confirmed.
+// @Tags users
+// @Produce  png
+// @Success 200 {file} file "QR code"
+// @Failure 401 {object} dto.ProblemDTO "Unauthorized"
+// @Failure 404 {object} dto.ProblemDTO "Not found"
+// @Failure 409 {object} dto.ProblemDTO "Two-factor authentication already enabled"
+// @Failure 500 {object} dto.ProblemDTO "Internal server error"
+// @Router /users/me/2fa/totp/qr [get]
+// @Security ApiKeyAuth
+func (h *TwoFactorHandler) QRCode(w http.ResponseWriter, r *http.Request) {
+	id, err := currentUserID(r)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	user, err := h.UserDB.FindById(id)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	secret, err := h.TOTPDB.FindByUser(id)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	// the secret is not shown again once in use
+	if secret.IsConfirmed() {
+		writeError(w, r, database.ErrTwoFactorEnabled)
+		return
+	}
+	key, err := secret.Key(h.Issuer, user.Email)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	image, err := key.Image(qrCodeSize, qrCodeSize)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	w.Header().Set("Content-Type", "image/png")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(http.StatusOK)
+	png.Encode(w, image)
+}
+
+// Confirm godoc
+// @Summary Enable two-factor authentication
+// @Description Confirm the secret being set up with a code from the authenticator app. From then on logins ask for a
+// @Description code, and the user is logged out everywhere else. It takes the password too, so that a stolen access
+// @Description token cannot lock the user out. The response holds recovery codes that can each be used once in place of
+// @Description a code. They are not shown again.
+// @Tags users
+// @Accept  json
+// @Produce  json
+// @Param request body dto.TwoFactorPasswordDTO true "Password and code from the authenticator app"
+// @Success 200 {object} dto.RecoveryCodesDTO
+// @Failure 400 {object} dto.ProblemDTO "Bad request"
+// @Failure 401 {object} dto.ProblemDTO "Unauthorized"
+// @Failure 404 {object} dto.ProblemDTO "Not found"
+// @Failure 409 {object} dto.ProblemDTO "Two-factor authentication already enabled"
+// @Failure 500 {object} dto.ProblemDTO "Internal server error"
+// @Router /users/me/2fa/totp/confirm [post]
+// @Security ApiKeyAuth
+func (h *TwoFactorHandler) Confirm(w http.ResponseWriter, r *http.Request) {
+	var request dto.TwoFactorPasswordDTO
+	user, ok := h.checkPassword(w, r, &request)
+	if !ok {
+		return
+	}
+	id := user.ID.String()
+	secret, err := h.TOTPDB.FindByUser(id)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	if secret.IsConfirmed() {
+		writeError(w, r, database.ErrTwoFactorEnabled)
+		return
+	}
+	step, ok := secret.Check(request.Code, time.Now())
+	if !ok {
+		writeError(w, r, entity.ErrInvalidTOTPCode)
+		return
+	}
+	codes, plain, err := entity.NewRecoveryCodes(secret.UserID)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	if err := h.TOTPDB.Confirm(id, step, codes); err != nil {
+		writeError(w, r, err)
+		return
+	}
+	writeRecoveryCodes(w, plain)
+}
+
+// RegenerateRecoveryCodes godoc
+// @Summary Get new recovery codes
+// @Description Replace the recovery codes of the current user, used or not, with new ones. It takes the password and a
+// @Description code from the authenticator app.
+// @Tags users
+// @Accept  json
+// @Produce  json
+// @Param request body dto.TwoFactorPasswordDTO true "Password and code from the authenticator app"
+// @Success 200 {object} dto.RecoveryCodesDTO
+// @Failure 400 {object} dto.ProblemDTO "Bad request"
+// @Failure 401 {object} dto.ProblemDTO "Unauthorized"
+// @Failure 404 {object} dto.ProblemDTO "Not found"
+// @Failure 500 {object} dto.ProblemDTO "Internal server error"
+// @Router /users/me/2fa/recovery_codes [post]
+// @Security ApiKeyAuth
+func (h *TwoFactorHandler) RegenerateRecoveryCodes(w http.ResponseWriter, r *http.Request) {
+	user, ok := h.reauthenticate(w, r, false)
+	if !ok {
+		return
+	}
+	codes, plain, err := entity.NewRecoveryCodes(user.ID)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	if err := h.TOTPDB.ReplaceRecoveryCodes(user.ID.String(), codes); err != nil {
+		writeError(w, r, err)
+		return
+	}
+	writeRecoveryCodes(w, plain)
+}
+
+// Disable godoc
+// @Summary Disable two-factor authentication
+// @Description Stop asking the current user for codes when logging in. It takes the password and a code from the
+// @Description authenticator app or a recovery code.
+// @Tags users
+// @Accept  json
+// @Produce  json
+// @Param request body dto.TwoFactorPasswordDTO true "Password and code from the authenticator app or recovery code"
+// @Success 204 "Two-factor authentication disabled"
+// @Failure 400 {object} dto.ProblemDTO "Bad request"
+// @Failure 401 {object} dto.ProblemDTO "Unauthorized"
+// @Failure 404 {object} dto.ProblemDTO "Not found"
+// @Failure 500 {object} dto.ProblemDTO "Internal server error"
+// @Router /users/me/2fa/disable [post]
+// @Security ApiKeyAuth
+func (h *TwoFactorHandler) Disable(w http.ResponseWriter, r *http.Request) {
+	user, ok := h.reauthenticate(w, r, true)
+	if !ok {
+		return
+	}
+	if err := h.TOTPDB.Disable(user.ID.String()); err != nil {
+		writeError(w, r, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// reauthenticate checks the password and second factor of the current user
+// before a change to their second factor, answering the request when they
+// are wrong.
+func (h *TwoFactorHandler) reauthenticate(w http.ResponseWriter, r *http.Request, allowRecovery bool) (*entity.User, bool) {
+	var request dto.TwoFactorPasswordDTO
+	user, ok := h.checkPassword(w, r, &request)
+	if !ok {
+		return nil, false
+	}
+	if err := h.verify(user.ID.String(), request.Code, allowRecovery); err != nil {
+		writeError(w, r, err)
+		return nil, false
+	}
+	return user, true
+}
+
+// checkPassword decodes the request and checks the password it holds is the
+// one of the current user, answering the request when it is wrong.
+func (h *TwoFactorHandler) checkPassword(w http.ResponseWriter, r *http.Request, request *dto.TwoFactorPasswordDTO) (*entity.User, bool) {
+	err := decode(r, request)
+	if err != nil {
+		writeError(w, r, err)
+		return nil, false
+	}
+	id, err := currentUserID(r)
+	if err != nil {
+		writeError(w, r, err)
+		return nil, false
+	}
+	user, err := h.UserDB.FindById(id)
+	if err != nil {
+		writeError(w, r, err)
+		return nil, false
+	}
+	if !user.ValidatePassword(request.Password) {
+		writeError(w, r, invalidField("password", errWrongPassword))
+		return nil, false
+	}
+	return user, true
+}
+
+// enabled tells whether the user has to give a second factor to log in.
+func (h *TwoFactorHandler) enabled(userID string) (bool, error) {
+	secret, err := h.TOTPDB.FindByUser(userID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return secret.IsConfirmed(), nil
+}
+
+// verify uses up the code if it is a right one from the authenticator app of
+// the user or, when allowed, one of their recovery codes.
+func (h *TwoFactorHandler) verify(userID, code string, allowRecovery bool) error {
+	secret, err := h.TOTPDB.FindByUser(userID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return entity.ErrInvalidTOTPCode
+	}
+	if err != nil {
+		return err
+	}
+	if !secret.IsConfirmed() {
+		return entity.ErrInvalidTOTPCode
+	}
+	if step, ok := secret.Check(code, time.Now()); ok {
+		return h.TOTPDB.UseCode(userID, step)
+	}
+	if allowRecovery {
+		return h.TOTPDB.UseRecoveryCode(userID, code)
+	}
+	return entity.ErrInvalidTOTPCode
+}
+
+// role is the role the tokens of the user carry. Admins who have to but did
+// not log in with a second factor only get to be editors.
+func (h *TwoFactorHandler) role(user *entity.User, twoFactor bool) entity.Role {
+	if h.RequiredForAdmins && !twoFactor && user.Role == entity.RoleAdmin {
+		return entity.RoleEditor
+	}
+	return user.Role
+}
+
+func writeRecoveryCodes(w http.ResponseWriter, codes []string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(dto.RecoveryCodesDTO{RecoveryCodes: codes})
+}
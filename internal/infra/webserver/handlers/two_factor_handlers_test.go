@@ -0,0 +1,52 @@
+package handlers
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/brenoproti/go-api/internal/entity"
+	"github.com/brenoproti/go-api/internal/infra/database"
+	"github.com/go-chi/chi"
+	"github.com/pquerna/otp/totp"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+func TestConfirmRequiresPassword(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open("file::memory:"), &gorm.Config{})
+	assert.Nil(t, err)
+	db.AutoMigrate(&entity.User{}, &entity.RefreshToken{}, &entity.TOTPSecret{}, &entity.RecoveryCode{})
+	userDB := database.NewUser(db)
+	totpDB := database.NewTOTPDB(db)
+	user, err := entity.NewUser("John Doe", "john@email.com", "Str0ng-Passw0rd")
+	assert.Nil(t, err)
+	assert.Nil(t, userDB.Create(user))
+	secret, err := entity.NewTOTPSecret(user.ID)
+	assert.Nil(t, err)
+	assert.Nil(t, totpDB.Enroll(secret))
+
+	keys := newTestKeys(t)
+	h := &TwoFactorHandler{UserDB: userDB, TOTPDB: totpDB}
+	r := chi.NewRouter()
+	r.Use(Verifier(keys), Authenticator)
+	r.Post("/users/me/2fa/totp/confirm", h.Confirm)
+	token := newTestToken(t, keys, user.ID.String(), entity.RoleViewer)
+	code, err := totp.GenerateCode(secret.Secret, time.Now())
+	assert.Nil(t, err)
+
+	for _, password := range []string{"", "wrong-Passw0rd"} {
+		w := serve(r, http.MethodPost, "/users/me/2fa/totp/confirm", token, map[string]string{"password": password, "code": code})
+		assert.Equal(t, http.StatusBadRequest, w.Code, password)
+	}
+	found, err := totpDB.FindByUser(user.ID.String())
+	assert.Nil(t, err)
+	assert.False(t, found.IsConfirmed())
+
+	w := serve(r, http.MethodPost, "/users/me/2fa/totp/confirm", token, map[string]string{"password": "Str0ng-Passw0rd", "code": code})
+	assert.Equal(t, http.StatusOK, w.Code)
+	found, err = totpDB.FindByUser(user.ID.String())
+	assert.Nil(t, err)
+	assert.True(t, found.IsConfirmed())
+}
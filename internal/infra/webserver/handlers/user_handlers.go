@@ -2,60 +2,85 @@ package handlers
 
 import (
 	"encoding/json"
+	"errors"
+	"io"
+	"log"
 	"net/http"
 	"time"
 
 	"github.com/brenoproti/go-api/internal/dto"
 	"github.com/brenoproti/go-api/internal/entity"
 	"github.com/brenoproti/go-api/internal/infra/database"
+	pkg "github.com/brenoproti/go-api/pkg/entity"
+	"github.com/brenoproti/go-api/pkg/jwtkeys"
+	"github.com/go-chi/chi"
 	"github.com/go-chi/jwtauth"
+	"gorm.io/gorm"
 )
 
 type UserHandler struct {
-	UserDB       database.UserInterface
-	Jwt          *jwtauth.JWTAuth
-	JwtExpiredIn int
+	UserDB            database.UserInterface
+	RefreshTokenDB    database.RefreshTokenInterface
+	RevokedTokenDB    database.RevokedTokenInterface
+	LoginAttemptDB    database.LoginAttemptInterface
+	LoginThrottle     entity.LoginThrottle
+	EmailVerification *EmailVerificationHandler
+	TwoFactor         *TwoFactorHandler
+	Jwt               *jwtkeys.KeySet
+	JwtExpiredIn      int
+	RefreshExpiredIn  int
+	// RequireVerifiedEmail keeps users from getting tokens until they verify
+	// their email.
+	RequireVerifiedEmail bool
 }
 
-func NewUserHandler(userDB database.UserInterface, jwt *jwtauth.JWTAuth, jwtExpiredIn int) *UserHandler {
+func NewUserHandler(userDB database.UserInterface, refreshTokenDB database.RefreshTokenInterface, revokedTokenDB database.RevokedTokenInterface, loginAttemptDB database.LoginAttemptInterface, loginThrottle entity.LoginThrottle, emailVerification *EmailVerificationHandler, twoFactor *TwoFactorHandler, jwt *jwtkeys.KeySet, jwtExpiredIn, refreshExpiredIn int, requireVerifiedEmail bool) *UserHandler {
 	return &UserHandler{
-		UserDB:       userDB,
-		Jwt:          jwt,
-		JwtExpiredIn: jwtExpiredIn,
+		UserDB:               userDB,
+		RefreshTokenDB:       refreshTokenDB,
+		RevokedTokenDB:       revokedTokenDB,
+		LoginAttemptDB:       loginAttemptDB,
+		LoginThrottle:        loginThrottle,
+		EmailVerification:    emailVerification,
+		TwoFactor:            twoFactor,
+		Jwt:                  jwt,
+		JwtExpiredIn:         jwtExpiredIn,
+		RefreshExpiredIn:     refreshExpiredIn,
+		RequireVerifiedEmail: requireVerifiedEmail,
 	}
 }
 
 // Create user godoc
 // @Summary Create a new user
-// @Description Create a new user
+// @Description Create a new user and email them a link to verify their email
 // @Tags users
 // @Accept  json
 // @Produce  json
 // @Param request body dto.UserDTO true "User info"
 // @Success 201 {string} string	"User created"
-// @Failure 400 {string} string "Bad request"
-// @Failure 500 {string} string "Internal server error"
+// @Failure 400 {object} dto.ProblemDTO "Bad request"
+// @Failure 409 {object} dto.ProblemDTO "Email already taken"
+// @Failure 500 {object} dto.ProblemDTO "Internal server error"
 // @Router /users [post]
 func (h *UserHandler) Create(w http.ResponseWriter, r *http.Request) {
 	var user dto.UserDTO
-	err := json.NewDecoder(r.Body).Decode(&user)
+	err := decode(r, &user)
 	if err != nil {
-		w.WriteHeader(http.StatusBadRequest)
-		return
-	}
-	defer r.Body.Close()
-	if user.Name == "" || user.Email == "" || user.Password == "" {
-		w.WriteHeader(http.StatusBadRequest)
+		writeError(w, r, err)
 		return
 	}
 	entity, err := entity.NewUser(user.Name, user.Email, user.Password)
 	if err != nil {
-		w.WriteHeader(http.StatusBadRequest)
+		writeError(w, r, err)
 		return
 	}
 	err = h.UserDB.Create(entity)
 	if err != nil {
-		w.WriteHeader(http.StatusInternalServerError)
+		writeError(w, r, err)
+		return
+	}
+	if err := h.EmailVerification.Send(entity); err != nil {
+		writeError(w, r, err)
 		return
 	}
 	w.Header().Add("id", entity.ID.String())
@@ -64,44 +89,719 @@ func (h *UserHandler) Create(w http.ResponseWriter, r *http.Request) {
 
 // GetJWT godoc
 // @Summary Get JWT token
-// @Description Get JWT token
+// @Description Get an access token along with a refresh token that can be exchanged for new tokens once it expires.
+// @Description Failed attempts with the same email or from the same IP address make the next ones wait longer and longer, until logins are locked out for a while.
+// @Description Users with two-factor authentication get an MFA token instead, to exchange along with a code at /users/generate_token/mfa.
 // @Tags users
 // @Accept  json
 // @Produce  json
 // @Param request body dto.LoginDTO true "User info"
-// @Success 201 {string} access_token "Token created"
-// @Failure 400 {string} string "Bad request"
-// @Failure 500 {string} string "Internal server error"
+// @Success 200 {object} dto.TokenDTO
+// @Success 202 {object} dto.MFAChallengeDTO "A two-factor code is needed"
+// @Failure 400 {object} dto.ProblemDTO "Bad request"
+// @Failure 401 {object} dto.ProblemDTO "Invalid credentials"
+// @Failure 403 {object} dto.ProblemDTO "Email not verified"
+// @Failure 429 {object} dto.ProblemDTO "Too many failed attempts, retry after the number of seconds in the Retry-After header"
+// @Header 429 {integer} Retry-After "Seconds to wait before trying again"
+// @Failure 500 {object} dto.ProblemDTO "Internal server error"
 // @Router /users/generate_token [post]
 func (h *UserHandler) GetJWT(w http.ResponseWriter, r *http.Request) {
 	var user dto.LoginDTO
-	err := json.NewDecoder(r.Body).Decode(&user)
+	err := decode(r, &user)
 	if err != nil {
-		w.WriteHeader(http.StatusBadRequest)
+		writeError(w, r, err)
+		return
+	}
+	attempt, wait, err := h.reserveLogin(r, user.Email, nil)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	if wait > 0 {
+		h.throttleLogin(w, r, attempt, wait)
 		return
 	}
-	defer r.Body.Close()
 	u, err := h.UserDB.FindByEmail(user.Email)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		if err := h.resolveLogin(attempt, nil, entity.LoginFailed); err != nil {
+			writeError(w, r, err)
+			return
+		}
+		writeError(w, r, errInvalidCredentials)
+		return
+	}
 	if err != nil {
-		w.WriteHeader(http.StatusUnauthorized)
+		h.abortLogin(w, r, attempt, err)
 		return
 	}
 	if !u.ValidatePassword(user.Password) {
-		w.WriteHeader(http.StatusUnauthorized)
+		if err := h.resolveLogin(attempt, &u.ID, entity.LoginFailed); err != nil {
+			writeError(w, r, err)
+			return
+		}
+		writeError(w, r, errInvalidCredentials)
+		return
+	}
+	twoFactor, err := h.TwoFactor.enabled(u.ID.String())
+	if err != nil {
+		h.abortLogin(w, r, attempt, err)
 		return
 	}
+	result := entity.LoginSucceeded
+	if twoFactor {
+		result = entity.LoginMFARequired
+	}
+	if err := h.resolveLogin(attempt, &u.ID, result); err != nil {
+		h.abortLogin(w, r, attempt, err)
+		return
+	}
+	if h.RequireVerifiedEmail && !u.IsEmailVerified() {
+		writeError(w, r, errEmailNotVerified)
+		return
+	}
+	if twoFactor {
+		h.writeMFAChallenge(w, r, u)
+		return
+	}
+	h.startSession(w, r, u, false)
+}
+
+// LoginWithMFA godoc
+// @Summary Get JWT token with a two-factor code
+// @Description Exchange the MFA token given for the password along with a code from the authenticator app, or a recovery code, for an access token and a refresh token.
+// @Description Wrong codes count as failed logins.
+// @Tags users
+// @Accept  json
+// @Produce  json
+// @Param request body dto.MFALoginDTO true "MFA token and code"
+// @Success 200 {object} dto.TokenDTO
+// @Failure 400 {object} dto.ProblemDTO "Bad request"
+// @Failure 401 {object} dto.ProblemDTO "Invalid or expired MFA token"
+// @Failure 429 {object} dto.ProblemDTO "Too many failed attempts, retry after the number of seconds in the Retry-After header"
+// @Header 429 {integer} Retry-After "Seconds to wait before trying again"
+// @Failure 500 {object} dto.ProblemDTO "Internal server error"
+// @Router /users/generate_token/mfa [post]
+func (h *UserHandler) LoginWithMFA(w http.ResponseWriter, r *http.Request) {
+	var request dto.MFALoginDTO
+	err := decode(r, &request)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	token, err := h.Jwt.Verify(request.MFAToken)
+	if err != nil || !isMFAToken(token) {
+		writeError(w, r, errInvalidMFAToken)
+		return
+	}
+	id, err := pkg.ParseID(token.JwtID())
+	if err != nil {
+		writeError(w, r, errInvalidMFAToken)
+		return
+	}
+	revoked, err := h.RevokedTokenDB.IsRevoked(id.String())
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	if revoked {
+		writeError(w, r, errInvalidMFAToken)
+		return
+	}
+	u, err := h.UserDB.FindById(token.Subject())
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		writeError(w, r, errInvalidMFAToken)
+		return
+	}
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	attempt, wait, err := h.reserveLogin(r, u.Email, &u.ID)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	if wait > 0 {
+		h.throttleLogin(w, r, attempt, wait)
+		return
+	}
+	err = h.TwoFactor.verify(u.ID.String(), request.Code, true)
+	if errors.Is(err, entity.ErrInvalidTOTPCode) {
+		if err := h.resolveLogin(attempt, &u.ID, entity.LoginFailed); err != nil {
+			writeError(w, r, err)
+			return
+		}
+		writeError(w, r, err)
+		return
+	}
+	if err != nil {
+		h.abortLogin(w, r, attempt, err)
+		return
+	}
+	// each MFA token can only be used once
+	used, err := entity.NewRevokedToken(id, u.ID, token.Expiration())
+	if err != nil {
+		h.abortLogin(w, r, attempt, err)
+		return
+	}
+	if err := h.RevokedTokenDB.Revoke(used); err != nil {
+		h.abortLogin(w, r, attempt, err)
+		return
+	}
+	if err := h.resolveLogin(attempt, &u.ID, entity.LoginSucceeded); err != nil {
+		h.abortLogin(w, r, attempt, err)
+		return
+	}
+	h.startSession(w, r, u, true)
+}
+
+// startSession responds with the tokens of a new login, which starts a new
+// family of refresh tokens.
+func (h *UserHandler) startSession(w http.ResponseWriter, r *http.Request, user *entity.User, twoFactor bool) {
+	refreshToken, plain, err := entity.NewRefreshToken(user.ID, nil, h.refreshTTL())
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	if err := h.RefreshTokenDB.Create(refreshToken); err != nil {
+		writeError(w, r, err)
+		return
+	}
+	h.writeTokens(w, r, user, plain, twoFactor)
+}
 
+// writeMFAChallenge responds with a token that only proves the user gave
+// their password, see LoginWithMFA.
+func (h *UserHandler) writeMFAChallenge(w http.ResponseWriter, r *http.Request, user *entity.User) {
 	_, token, err := h.Jwt.Encode(map[string]interface{}{
-		"sub": u.ID.String(),
-		"exp": time.Now().Add(time.Second * time.Duration(h.JwtExpiredIn)).Unix(),
+		"jti": pkg.NewID().String(),
+		"sub": user.ID.String(),
+		"aud": mfaAudience,
+		"iat": time.Now().Unix(),
+		"exp": time.Now().Add(time.Second * time.Duration(h.TwoFactor.ChallengeExpiresIn)).Unix(),
 	})
 	if err != nil {
-		w.WriteHeader(http.StatusInternalServerError)
+		writeError(w, r, err)
 		return
 	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(dto.MFAChallengeDTO{
+		MFAToken:  token,
+		ExpiresIn: h.TwoFactor.ChallengeExpiresIn,
+	})
+}
+
+// reserveLogin records a pending attempt to log in with the email before the
+// password or code is checked, see reserveAttempt.
+func (h *UserHandler) reserveLogin(r *http.Request, email string, userID *pkg.ID) (*entity.LoginAttempt, time.Duration, error) {
+	return reserveAttempt(h.LoginAttemptDB, h.LoginThrottle, r, email, userID, entity.LoginPending)
+}
+
+func (h *UserHandler) resolveLogin(attempt *entity.LoginAttempt, userID *pkg.ID, result entity.LoginResult) error {
+	return resolveAttempt(h.LoginAttemptDB, attempt, userID, result)
+}
+
+// throttleLogin responds that logging in has to wait.
+func (h *UserHandler) throttleLogin(w http.ResponseWriter, r *http.Request, attempt *entity.LoginAttempt, wait time.Duration) {
+	writeThrottled(w, r, h.LoginAttemptDB, attempt, wait, errTooManyLoginAttempts)
+}
 
+// abortLogin responds with an error of the server that cut a reserved login
+// short, and records it so that the attempt stops counting as a failure.
+func (h *UserHandler) abortLogin(w http.ResponseWriter, r *http.Request, attempt *entity.LoginAttempt, err error) {
+	if err := h.resolveLogin(attempt, attempt.UserID, entity.LoginErrored); err != nil {
+		log.Printf("%s %s: %v", r.Method, r.URL.Path, err)
+	}
+	writeError(w, r, err)
+}
+
+// GetLoginAttempts godoc
+// @Summary List login attempts
+// @Description List the attempts to get a token, or to be emailed a password reset or verification, newest first, for reviewing suspicious activity. Only admins can list them.
+// @Tags users
+// @Accept  json
+// @Produce  json
+// @Param email query string false "Email the attempts were made with"
+// @Param ip query string false "IP address the attempts were made from"
+// @Param user_id query string false "User the email belonged to"
+// @Param result query string false "Result of the attempts" Enums(succeeded, failed, throttled, mfa_required, pending, errored, reset_requested, verify_requested)
+// @Param page query int false "Page number"
+// @Param limit query int false "Limit per page, 20 by default and at most 100"
+// @Success 200 {object} dto.PageDTO{data=[]entity.LoginAttempt}
+// @Failure 400 {object} dto.ProblemDTO "Bad request"
+// @Failure 401 {object} dto.ProblemDTO "Unauthorized"
+// @Failure 403 {object} dto.ProblemDTO "Forbidden"
+// @Failure 500 {object} dto.ProblemDTO "Internal server error"
+// @Router /users/login_attempts [get]
+// @Security ApiKeyAuth
+func (h *UserHandler) GetLoginAttempts(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	filter := database.LoginAttemptFilter{
+		Email:  query.Get("email"),
+		IP:     query.Get("ip"),
+		UserID: query.Get("user_id"),
+		Result: entity.LoginResult(query.Get("result")),
+	}
+	if filter.Result != "" && !filter.Result.IsValid() {
+		writeError(w, r, entity.ErrInvalidLoginResult)
+		return
+	}
+	page, limit := pageParams(r)
+	attempts, err := h.LoginAttemptDB.FindAll(page, limit, filter)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	total, err := h.LoginAttemptDB.Count(filter)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
 	w.Header().Set("Content-Type", "application/json")
-	response := map[string]string{"access_token": token}
-	json.NewEncoder(w).Encode(response)
 	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(newPage(r, attempts, total, page, limit))
+}
+
+// RefreshToken godoc
+// @Summary Refresh JWT token
+// @Description Exchange a refresh token for a new access token and refresh token. Each refresh token can only be used once; reusing one revokes every token issued from the same login.
+// @Tags users
+// @Accept  json
+// @Produce  json
+// @Param request body dto.RefreshTokenDTO true "Refresh token"
+// @Success 200 {object} dto.TokenDTO
+// @Failure 400 {object} dto.ProblemDTO "Bad request"
+// @Failure 401 {object} dto.ProblemDTO "Invalid, expired, revoked or reused refresh token"
+// @Failure 403 {object} dto.ProblemDTO "Email not verified"
+// @Failure 500 {object} dto.ProblemDTO "Internal server error"
+// @Router /users/refresh_token [post]
+func (h *UserHandler) RefreshToken(w http.ResponseWriter, r *http.Request) {
+	var request dto.RefreshTokenDTO
+	err := decode(r, &request)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	refreshToken, plain, err := h.RefreshTokenDB.Rotate(request.RefreshToken, h.refreshTTL())
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	u, err := h.UserDB.FindById(refreshToken.UserID.String())
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		writeError(w, r, entity.ErrInvalidRefreshToken)
+		return
+	}
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	// the email may have changed since the login
+	if h.RequireVerifiedEmail && !u.IsEmailVerified() {
+		writeError(w, r, errEmailNotVerified)
+		return
+	}
+	// enabling two-factor authentication revokes the refresh tokens issued
+	// before, so the login of this one used it if it is enabled
+	twoFactor, err := h.TwoFactor.enabled(u.ID.String())
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	h.writeTokens(w, r, u, plain, twoFactor)
+}
+
+// Logout godoc
+// @Summary Log out
+// @Description Revoke the access token of the request, and the refresh token if one is given, before they expire
+// @Tags users
+// @Accept  json
+// @Produce  json
+// @Param request body dto.LogoutDTO false "Refresh token to revoke"
+// @Success 204 "Logged out"
+// @Failure 400 {object} dto.ProblemDTO "Bad request"
+// @Failure 401 {object} dto.ProblemDTO "Unauthorized"
+// @Failure 500 {object} dto.ProblemDTO "Internal server error"
+// @Router /users/logout [post]
+// @Security ApiKeyAuth
+func (h *UserHandler) Logout(w http.ResponseWriter, r *http.Request) {
+	var request dto.LogoutDTO
+	// the body is optional
+	err := decode(r, &request)
+	if err != nil && !errors.Is(err, io.EOF) {
+		writeError(w, r, err)
+		return
+	}
+	userID, err := currentUser(r)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	if request.RefreshToken != "" {
+		err := h.RefreshTokenDB.Revoke(request.RefreshToken, userID.String())
+		if err != nil {
+			writeError(w, r, invalidField("refresh_token", err))
+			return
+		}
+	}
+	if err := h.revokeAccessToken(r); err != nil {
+		writeError(w, r, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// revokeAccessToken revokes the access token of the request until it
+// expires.
+func (h *UserHandler) revokeAccessToken(r *http.Request) error {
+	token, _, err := jwtauth.FromContext(r.Context())
+	if err != nil {
+		return err
+	}
+	id, err := pkg.ParseID(token.JwtID())
+	if err != nil {
+		return errMissingTokenID
+	}
+	userID, err := pkg.ParseID(token.Subject())
+	if err != nil {
+		return errMissingSubject
+	}
+	revoked, err := entity.NewRevokedToken(id, userID, token.Expiration())
+	if err != nil {
+		return err
+	}
+	return h.RevokedTokenDB.Revoke(revoked)
+}
+
+func (h *UserHandler) refreshTTL() time.Duration {
+	return time.Second * time.Duration(h.RefreshExpiredIn)
+}
+
+// writeTokens responds with a new access token for the user along with the
+// given refresh token. The token carries the user's current role, and tells
+// in its amr claim whether the login took a second factor.
+func (h *UserHandler) writeTokens(w http.ResponseWriter, r *http.Request, user *entity.User, refreshToken string, twoFactor bool) {
+	amr := []string{"pwd"}
+	if twoFactor {
+		amr = append(amr, "otp")
+	}
+	_, token, err := h.Jwt.Encode(map[string]interface{}{
+		"jti":  pkg.NewID().String(),
+		"sub":  user.ID.String(),
+		"role": string(h.TwoFactor.role(user, twoFactor)),
+		"amr":  amr,
+		"iat":  time.Now().Unix(),
+		"exp":  time.Now().Add(time.Second * time.Duration(h.JwtExpiredIn)).Unix(),
+	})
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(dto.TokenDTO{
+		AccessToken:  token,
+		RefreshToken: refreshToken,
+		TokenType:    "Bearer",
+		ExpiresIn:    h.JwtExpiredIn,
+	})
+}
+
+// Me godoc
+// @Summary Get the current user
+// @Description Get the user the token was issued to
+// @Tags users
+// @Accept  json
+// @Produce  json
+// @Success 200 {object} entity.User
+// @Failure 401 {object} dto.ProblemDTO "Unauthorized"
+// @Failure 404 {object} dto.ProblemDTO "Not found"
+// @Failure 500 {object} dto.ProblemDTO "Internal server error"
+// @Router /users/me [get]
+// @Security ApiKeyAuth
+func (h *UserHandler) Me(w http.ResponseWriter, r *http.Request) {
+	id, err := currentUserID(r)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	h.writeUser(w, r, id)
+}
+
+// FindById user godoc
+// @Summary Find a user by ID
+// @Description Find a user by ID. Only admins can find other users.
+// @Tags users
+// @Accept  json
+// @Produce  json
+// @Param id path string true "User ID"
+// @Success 200 {object} entity.User
+// @Failure 401 {object} dto.ProblemDTO "Unauthorized"
+// @Failure 403 {object} dto.ProblemDTO "Forbidden"
+// @Failure 404 {object} dto.ProblemDTO "Not found"
+// @Failure 500 {object} dto.ProblemDTO "Internal server error"
+// @Router /users/{id} [get]
+// @Security ApiKeyAuth
+func (h *UserHandler) FindById(w http.ResponseWriter, r *http.Request) {
+	role, err := currentRole(r)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	if !role.Includes(entity.RoleAdmin) {
+		if _, err := h.ownAccount(r); err != nil {
+			writeError(w, r, err)
+			return
+		}
+	}
+	h.writeUser(w, r, chi.URLParam(r, "id"))
+}
+
+func (h *UserHandler) writeUser(w http.ResponseWriter, r *http.Request, id string) {
+	user, err := h.UserDB.FindById(id)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(user)
+}
+
+// GetUsers godoc
+// @Summary List users
+// @Description List users paginated, sorted by name. Only admins can list users.
+// @Tags users
+// @Accept  json
+// @Produce  json
+// @Param page query int false "Page number"
+// @Param limit query int false "Limit per page, 20 by default and at most 100"
+// @Success 200 {object} dto.PageDTO{data=[]entity.User}
+// @Failure 401 {object} dto.ProblemDTO "Unauthorized"
+// @Failure 403 {object} dto.ProblemDTO "Forbidden"
+// @Failure 500 {object} dto.ProblemDTO "Internal server error"
+// @Router /users [get]
+// @Security ApiKeyAuth
+func (h *UserHandler) GetUsers(w http.ResponseWriter, r *http.Request) {
+	page, limit := pageParams(r)
+	users, err := h.UserDB.FindAll(page, limit)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	total, err := h.UserDB.Count()
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(newPage(r, users, total, page, limit))
+}
+
+// Update user godoc
+// @Summary Update a user
+// @Description Change the name and email of a user. Users can only update their own account. A new email has to be verified again.
+// @Tags users
+// @Accept  json
+// @Produce  json
+// @Param id path string true "User ID"
+// @Param request body dto.UpdateUserDTO true "User info"
+// @Success 200 {object} entity.User
+// @Failure 400 {object} dto.ProblemDTO "Bad request"
+// @Failure 401 {object} dto.ProblemDTO "Unauthorized"
+// @Failure 403 {object} dto.ProblemDTO "Forbidden"
+// @Failure 404 {object} dto.ProblemDTO "Not found"
+// @Failure 409 {object} dto.ProblemDTO "Email already taken"
+// @Failure 500 {object} dto.ProblemDTO "Internal server error"
+// @Router /users/{id} [put]
+// @Security ApiKeyAuth
+func (h *UserHandler) Update(w http.ResponseWriter, r *http.Request) {
+	var user dto.UpdateUserDTO
+	err := decode(r, &user)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	id, err := h.ownAccount(r)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	current, err := h.UserDB.FindById(id.String())
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	updated := &entity.User{ID: id, Name: user.Name, Email: user.Email}
+	err = h.UserDB.Update(updated)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	if updated.Email != current.Email {
+		if err := h.EmailVerification.Send(updated); err != nil {
+			writeError(w, r, err)
+			return
+		}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(updated)
+}
+
+// ChangePassword godoc
+// @Summary Change the password of the current user
+// @Description Replace the password of the user the token was issued to. The current password is required and the new one has to meet the password policy. Every refresh token of the user is revoked, and so is every access token issued before the change, so that all sessions have to log in again.
+// @Tags users
+// @Accept  json
+// @Produce  json
+// @Param request body dto.ChangePasswordDTO true "Current and new password"
+// @Success 204 "Password changed"
+// @Failure 400 {object} dto.ProblemDTO "Bad request"
+// @Failure 401 {object} dto.ProblemDTO "Unauthorized"
+// @Failure 404 {object} dto.ProblemDTO "Not found"
+// @Failure 500 {object} dto.ProblemDTO "Internal server error"
+// @Router /users/me/password [put]
+// @Security ApiKeyAuth
+func (h *UserHandler) ChangePassword(w http.ResponseWriter, r *http.Request) {
+	var request dto.ChangePasswordDTO
+	err := decode(r, &request)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	id, err := currentUserID(r)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	user, err := h.UserDB.FindById(id)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	if !user.ValidatePassword(request.CurrentPassword) {
+		writeError(w, r, errWrongPassword)
+		return
+	}
+	if request.NewPassword == request.CurrentPassword {
+		writeError(w, r, errPasswordUnchanged)
+		return
+	}
+	if err := user.ChangePassword(request.NewPassword); err != nil {
+		writeError(w, r, invalidField("new_password", err))
+		return
+	}
+	if err := h.UserDB.UpdatePassword(user); err != nil {
+		writeError(w, r, err)
+		return
+	}
+	if err := h.revokeAccessToken(r); err != nil {
+		writeError(w, r, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// SetRole godoc
+// @Summary Set the role of a user
+// @Description Grant a user the viewer, editor or admin role. Only admins can set roles, and not their own. The user is logged out everywhere, so that the former role stops applying right away.
+// @Tags users
+// @Accept  json
+// @Produce  json
+// @Param id path string true "User ID"
+// @Param request body dto.RoleDTO true "Role"
+// @Success 200 {object} entity.User
+// @Failure 400 {object} dto.ProblemDTO "Bad request"
+// @Failure 401 {object} dto.ProblemDTO "Unauthorized"
+// @Failure 403 {object} dto.ProblemDTO "Forbidden"
+// @Failure 404 {object} dto.ProblemDTO "Not found"
+// @Failure 500 {object} dto.ProblemDTO "Internal server error"
+// @Router /users/{id}/role [put]
+// @Security ApiKeyAuth
+func (h *UserHandler) SetRole(w http.ResponseWriter, r *http.Request) {
+	var request dto.RoleDTO
+	err := decode(r, &request)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	role, err := entity.ParseRole(request.Role)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	id, err := pkg.ParseID(chi.URLParam(r, "id"))
+	if err != nil {
+		writeError(w, r, entity.ErrInvalidId)
+		return
+	}
+	current, err := currentUserID(r)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	// keeps the last admin from locking everyone out
+	if current == id.String() {
+		writeError(w, r, errOwnRole)
+		return
+	}
+	user, err := h.UserDB.SetRole(id.String(), role)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(user)
+}
+
+// Delete user godoc
+// @Summary Delete a user
+// @Description Delete a user. Users can only delete their own account.
+// @Tags users
+// @Accept  json
+// @Produce  json
+// @Param id path string true "User ID"
+// @Success 200 {string} string "User deleted"
+// @Failure 401 {object} dto.ProblemDTO "Unauthorized"
+// @Failure 403 {object} dto.ProblemDTO "Forbidden"
+// @Failure 404 {object} dto.ProblemDTO "Not found"
+// @Failure 500 {object} dto.ProblemDTO "Internal server error"
+// @Router /users/{id} [delete]
+// @Security ApiKeyAuth
+func (h *UserHandler) Delete(w http.ResponseWriter, r *http.Request) {
+	id, err := h.ownAccount(r)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	err = h.UserDB.Delete(id.String())
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+}
+
+// ownAccount returns the ID of the user in the URL, provided it is the one
+// making the request.
+func (h *UserHandler) ownAccount(r *http.Request) (pkg.ID, error) {
+	id, err := pkg.ParseID(chi.URLParam(r, "id"))
+	if err != nil {
+		return pkg.ID{}, entity.ErrInvalidId
+	}
+	current, err := currentUserID(r)
+	if err != nil {
+		return pkg.ID{}, err
+	}
+	if current != id.String() {
+		return pkg.ID{}, errForbidden
+	}
+	return id, nil
 }
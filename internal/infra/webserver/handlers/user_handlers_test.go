@@ -0,0 +1,58 @@
+package handlers
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/brenoproti/go-api/internal/entity"
+	"github.com/brenoproti/go-api/internal/infra/database"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+func TestGetJWTRecordsFailures(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open("file::memory:"), &gorm.Config{})
+	assert.Nil(t, err)
+	db.AutoMigrate(&entity.User{}, &entity.LoginAttempt{})
+	userDB := database.NewUser(db)
+	user, err := entity.NewUser("John Doe", "john@email.com", "Str0ng-Passw0rd")
+	assert.Nil(t, err)
+	assert.Nil(t, userDB.Create(user))
+	attemptDB := database.NewLoginAttemptDB(db)
+	h := &UserHandler{
+		UserDB:         userDB,
+		LoginAttemptDB: attemptDB,
+		LoginThrottle:  entity.LoginThrottle{MaxEmailFailures: 5, MaxIPFailures: 20, BaseDelay: time.Minute, MaxDelay: time.Hour, Lockout: time.Hour},
+	}
+	count := func(result entity.LoginResult) int64 {
+		total, err := attemptDB.Count(database.LoginAttemptFilter{Result: result})
+		assert.Nil(t, err)
+		return total
+	}
+
+	w := serve(http.HandlerFunc(h.GetJWT), http.MethodPost, "/", "", map[string]string{"email": "jane@email.com", "password": "Str0ng-Passw0rd"})
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+	assert.Equal(t, int64(1), count(entity.LoginFailed))
+	// the failure makes the next attempt wait
+	w = serve(http.HandlerFunc(h.GetJWT), http.MethodPost, "/", "", map[string]string{"email": "jane@email.com", "password": "Str0ng-Passw0rd"})
+	assert.Equal(t, http.StatusTooManyRequests, w.Code)
+	assert.NotEmpty(t, w.Header().Get("Retry-After"))
+	assert.Equal(t, int64(1), count(entity.LoginThrottled))
+
+	// errors looking the user up are not failed logins
+	_, err = attemptDB.Purge(time.Now().Add(time.Second))
+	assert.Nil(t, err)
+	assert.Nil(t, db.Migrator().DropTable(&entity.User{}))
+	w = serve(http.HandlerFunc(h.GetJWT), http.MethodPost, "/", "", map[string]string{"email": "john@email.com", "password": "Str0ng-Passw0rd"})
+	assert.Equal(t, http.StatusInternalServerError, w.Code)
+	assert.Equal(t, int64(0), count(entity.LoginFailed))
+	assert.Equal(t, int64(0), count(entity.LoginPending))
+	assert.Equal(t, int64(1), count(entity.LoginErrored))
+	// so they do not make the next attempt wait
+	assert.Nil(t, db.AutoMigrate(&entity.User{}))
+	w = serve(http.HandlerFunc(h.GetJWT), http.MethodPost, "/", "", map[string]string{"email": "john@email.com", "password": "Str0ng-Passw0rd"})
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+	assert.Equal(t, int64(1), count(entity.LoginFailed))
+}
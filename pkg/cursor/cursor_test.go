@@ -0,0 +1,36 @@
+package cursor
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type position struct {
+	ID string `json:"id"`
+}
+
+func TestEncodeDecode(t *testing.T) {
+	s := NewSigner([]byte("secret"))
+	c, err := s.Encode(position{ID: "42"})
+	assert.Nil(t, err)
+	assert.NotContains(t, c, "42")
+
+	var p position
+	assert.Nil(t, s.Decode(c, &p))
+	assert.Equal(t, "42", p.ID)
+}
+
+func TestDecodeWhenTampered(t *testing.T) {
+	s := NewSigner([]byte("secret"))
+	c, err := s.Encode(position{ID: "42"})
+	assert.Nil(t, err)
+
+	other, err := NewSigner([]byte("other")).Encode(position{ID: "43"})
+	assert.Nil(t, err)
+
+	var p position
+	for _, invalid := range []string{"", "abc", c + "x", "x" + c, other} {
+		assert.Equal(t, ErrInvalidCursor, s.Decode(invalid, &p), invalid)
+	}
+}
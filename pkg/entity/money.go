@@ -0,0 +1,155 @@
+package entity
+
+import (
+	"encoding/json"
+	"errors"
+	"strconv"
+	"strings"
+)
+
+var (
+	ErrInvalidAmount    = errors.New("invalid amount")
+	ErrInvalidCurrency  = errors.New("invalid currency")
+	ErrCurrencyMismatch = errors.New("currency mismatch")
+)
+
+// currencies maps the supported ISO-4217 codes to their number of minor units.
+var currencies = map[string]int{
+	"ARS": 2,
+	"AUD": 2,
+	"BHD": 3,
+	"BRL": 2,
+	"CAD": 2,
+	"CHF": 2,
+	"CLP": 0,
+	"CNY": 2,
+	"EUR": 2,
+	"GBP": 2,
+	"INR": 2,
+	"JPY": 0,
+	"KRW": 0,
+	"KWD": 3,
+	"MXN": 2,
+	"USD": 2,
+}
+
+// Money is an exact amount in the minor units of its currency, e.g. cents for
+// USD. In JSON the amount is a decimal string such as "10.50".
+type Money struct {
+	Amount   int64  `json:"amount" swaggertype:"string" example:"10.50"`
+	Currency string `json:"currency" example:"USD"`
+}
+
+func NewMoney(amount int64, currency string) Money {
+	return Money{Amount: amount, Currency: currency}
+}
+
+// ParseMoney converts a decimal amount like "10.5" into minor units. Amounts
+// with more decimal places than the currency allows are rejected rather than
+// rounded.
+func ParseMoney(amount, currency string) (Money, error) {
+	exponent, ok := currencies[currency]
+	if !ok {
+		return Money{}, ErrInvalidCurrency
+	}
+	s := amount
+	sign := ""
+	if strings.HasPrefix(s, "-") || strings.HasPrefix(s, "+") {
+		sign, s = s[:1], s[1:]
+	}
+	whole, fraction, hasDot := strings.Cut(s, ".")
+	if whole == "" || (hasDot && fraction == "") || len(fraction) > exponent {
+		return Money{}, ErrInvalidAmount
+	}
+	for _, r := range whole + fraction {
+		if r < '0' || r > '9' {
+			return Money{}, ErrInvalidAmount
+		}
+	}
+	fraction += strings.Repeat("0", exponent-len(fraction))
+	minor, err := strconv.ParseInt(sign+whole+fraction, 10, 64)
+	if err != nil {
+		return Money{}, ErrInvalidAmount
+	}
+	return Money{Amount: minor, Currency: currency}, nil
+}
+
+func (m Money) Validate() error {
+	if _, ok := currencies[m.Currency]; !ok {
+		return ErrInvalidCurrency
+	}
+	return nil
+}
+
+func (m Money) IsZero() bool {
+	return m.Amount == 0
+}
+
+func (m Money) IsNegative() bool {
+	return m.Amount < 0
+}
+
+func (m Money) Add(other Money) (Money, error) {
+	if m.Currency != other.Currency {
+		return Money{}, ErrCurrencyMismatch
+	}
+	return Money{Amount: m.Amount + other.Amount, Currency: m.Currency}, nil
+}
+
+func (m Money) Mul(quantity int64) Money {
+	return Money{Amount: m.Amount * quantity, Currency: m.Currency}
+}
+
+// String formats the amount as a decimal number with as many decimal places
+// as the currency has minor units.
+func (m Money) String() string {
+	exponent := currencies[m.Currency]
+	abs := uint64(m.Amount)
+	sign := ""
+	if m.Amount < 0 {
+		abs = -abs
+		sign = "-"
+	}
+	digits := strconv.FormatUint(abs, 10)
+	if exponent == 0 {
+		return sign + digits
+	}
+	if len(digits) <= exponent {
+		digits = strings.Repeat("0", exponent-len(digits)+1) + digits
+	}
+	return sign + digits[:len(digits)-exponent] + "." + digits[len(digits)-exponent:]
+}
+
+type moneyJSON struct {
+	Amount   json.RawMessage `json:"amount"`
+	Currency string          `json:"currency"`
+}
+
+func (m Money) MarshalJSON() ([]byte, error) {
+	amount, err := json.Marshal(m.String())
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(moneyJSON{Amount: amount, Currency: m.Currency})
+}
+
+// UnmarshalJSON accepts the amount either as a string or as a plain JSON
+// number, without ever going through a float.
+func (m *Money) UnmarshalJSON(data []byte) error {
+	var v moneyJSON
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+	amount := string(v.Amount)
+	if strings.HasPrefix(amount, `"`) {
+		if err := json.Unmarshal(v.Amount, &amount); err != nil {
+			return err
+		}
+	}
+	money, err := ParseMoney(amount, v.Currency)
+	if err != nil {
+		return err
+	}
+	*m = money
+	return nil
+}
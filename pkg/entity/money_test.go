@@ -0,0 +1,69 @@
+package entity
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseMoney(t *testing.T) {
+	m, err := ParseMoney("10.5", "USD")
+	assert.Nil(t, err)
+	assert.Equal(t, int64(1050), m.Amount)
+	assert.Equal(t, "USD", m.Currency)
+
+	m, err = ParseMoney("-0.07", "BRL")
+	assert.Nil(t, err)
+	assert.Equal(t, int64(-7), m.Amount)
+
+	m, err = ParseMoney("1500", "JPY")
+	assert.Nil(t, err)
+	assert.Equal(t, int64(1500), m.Amount)
+
+	m, err = ParseMoney("1.234", "BHD")
+	assert.Nil(t, err)
+	assert.Equal(t, int64(1234), m.Amount)
+}
+
+func TestParseMoneyWhenInvalid(t *testing.T) {
+	for _, amount := range []string{"", ".5", "1.", "1.234", "1,50", "1e3", "abc", "99999999999999999999"} {
+		_, err := ParseMoney(amount, "USD")
+		assert.Equal(t, ErrInvalidAmount, err, amount)
+	}
+	_, err := ParseMoney("10", "XXX")
+	assert.Equal(t, ErrInvalidCurrency, err)
+}
+
+func TestMoneyString(t *testing.T) {
+	assert.Equal(t, "10.50", NewMoney(1050, "USD").String())
+	assert.Equal(t, "0.07", NewMoney(7, "USD").String())
+	assert.Equal(t, "-0.07", NewMoney(-7, "USD").String())
+	assert.Equal(t, "1500", NewMoney(1500, "JPY").String())
+	assert.Equal(t, "1.234", NewMoney(1234, "BHD").String())
+}
+
+func TestMoneyArithmetic(t *testing.T) {
+	total, err := NewMoney(10, "USD").Add(NewMoney(20, "USD"))
+	assert.Nil(t, err)
+	assert.Equal(t, NewMoney(30, "USD"), total)
+	assert.Equal(t, NewMoney(30, "USD"), NewMoney(10, "USD").Mul(3))
+
+	_, err = NewMoney(10, "USD").Add(NewMoney(10, "EUR"))
+	assert.Equal(t, ErrCurrencyMismatch, err)
+}
+
+func TestMoneyJSON(t *testing.T) {
+	data, err := json.Marshal(NewMoney(1050, "USD"))
+	assert.Nil(t, err)
+	assert.JSONEq(t, `{"amount":"10.50","currency":"USD"}`, string(data))
+
+	var m Money
+	assert.Nil(t, json.Unmarshal([]byte(`{"amount":"10.50","currency":"USD"}`), &m))
+	assert.Equal(t, NewMoney(1050, "USD"), m)
+
+	assert.Nil(t, json.Unmarshal([]byte(`{"amount":0.1,"currency":"USD"}`), &m))
+	assert.Equal(t, NewMoney(10, "USD"), m)
+
+	assert.Equal(t, ErrInvalidCurrency, json.Unmarshal([]byte(`{"amount":"10.50"}`), &m))
+}
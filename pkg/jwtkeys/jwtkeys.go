@@ -0,0 +1,439 @@
+// Package jwtkeys signs and verifies JWTs with a set of keys told apart by
+// their kid, so that keys can be rotated and published as a JWK set.
+package jwtkeys
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/lestrrat-go/jwx/jwa"
+	"github.com/lestrrat-go/jwx/jwk"
+	"github.com/lestrrat-go/jwx/jws"
+	"github.com/lestrrat-go/jwx/jwt"
+)
+
+var (
+	ErrUnsupportedAlgorithm = errors.New("unsupported JWT algorithm")
+	ErrSecretIsRequired     = errors.New("JWT secret is required")
+	ErrDirIsRequired        = errors.New("JWT keys directory is required")
+	ErrRotationTooShort     = errors.New("JWT key rotation must be longer than the lead time")
+	ErrUnsupportedKey       = errors.New("unsupported JWT key")
+	ErrNoSigningKey         = errors.New("no active JWT signing key")
+	ErrInvalidToken         = errors.New("token is invalid")
+	ErrExpired              = errors.New("token is expired")
+)
+
+// minRSABits is the smallest RSA modulus accepted for signing tokens.
+const minRSABits = 2048
+
+// Config selects how tokens are signed. HS256, HS384 and HS512 sign them with
+// Secret, which every service verifying them must then know. RS256, ES256 and
+// EdDSA sign them with the private keys stored in Dir as PEM files, whose
+// public halves anyone can verify them with.
+type Config struct {
+	Algorithm string
+	Secret    string
+	Dir       string
+	// Rotation is how often a new key of Algorithm is generated in Dir, zero
+	// to only use the keys put there by hand.
+	Rotation time.Duration
+	// Lead is how long generated keys are published before they sign tokens,
+	// so that the verifiers caching the JWK set learn about them first.
+	Lead time.Duration
+	// Retention is how long a key keeps verifying tokens once a newer key
+	// replaced it, so at least as long as tokens are valid. Only keys
+	// replaced while Rotation is set are deleted.
+	Retention time.Duration
+}
+
+// key is a key tokens are signed and verified with. The tokens it signs
+// carry its ID as their kid header.
+type key struct {
+	ID        string
+	Algorithm jwa.SignatureAlgorithm
+	// ActiveSince is when the key starts signing tokens. Until then, it is
+	// only published.
+	ActiveSince time.Time
+	private     interface{}
+	public      interface{}
+	path        string
+}
+
+// KeySet holds the keys tokens are signed and verified with. The keys of Dir
+// are named after their kid, and become active at the modification time of
+// their file, so that a key dated in the future is published ahead of its
+// use. The most recently activated key signs the new tokens, while the
+// others keep verifying the tokens they signed.
+type KeySet struct {
+	Config
+
+	mu sync.RWMutex
+	// keys is sorted by activation time.
+	keys []*key
+}
+
+// New loads the keys tokens are signed with, generating the first one if
+// Rotation is set and Dir has none yet.
+func New(c Config) (*KeySet, error) {
+	alg := jwa.SignatureAlgorithm(c.Algorithm)
+	switch {
+	case isSecret(alg):
+		if c.Secret == "" {
+			return nil, ErrSecretIsRequired
+		}
+		secret := []byte(c.Secret)
+		return &KeySet{
+			Config: c,
+			keys:   []*key{{Algorithm: alg, private: secret, public: secret}},
+		}, nil
+	case alg == jwa.RS256 || alg == jwa.ES256 || alg == jwa.EdDSA:
+		if c.Dir == "" {
+			return nil, ErrDirIsRequired
+		}
+		if c.Rotation > 0 && c.Rotation <= c.Lead {
+			return nil, ErrRotationTooShort
+		}
+		s := &KeySet{Config: c}
+		now := time.Now()
+		if err := s.Rotate(now); err != nil {
+			return nil, err
+		}
+		if _, err := s.signingKey(now); err != nil {
+			return nil, err
+		}
+		return s, nil
+	case alg == "":
+		return nil, fmt.Errorf("%w: algorithm is required", ErrUnsupportedAlgorithm)
+	default:
+		return nil, fmt.Errorf("%w: %q", ErrUnsupportedAlgorithm, c.Algorithm)
+	}
+}
+
+// Rotate reloads the keys of Dir, to pick up the keys added or removed by
+// hand or by other instances sharing it. When Rotation is set, it then
+// generates a new key if the newest one is due for replacement, and deletes
+// the keys replaced for longer than Retention. It does nothing for secrets.
+func (s *KeySet) Rotate(now time.Time) error {
+	if isSecret(jwa.SignatureAlgorithm(s.Algorithm)) {
+		return nil
+	}
+	keys, err := loadKeys(s.Dir)
+	if err != nil {
+		return err
+	}
+	if s.Rotation > 0 {
+		if len(keys) == 0 {
+			k, err := generateKey(s.Dir, jwa.SignatureAlgorithm(s.Algorithm), now)
+			if err != nil {
+				return err
+			}
+			keys = append(keys, k)
+		} else if newest := keys[len(keys)-1]; !now.Before(newest.ActiveSince.Add(s.Rotation - s.Lead)) {
+			k, err := generateKey(s.Dir, jwa.SignatureAlgorithm(s.Algorithm), now.Add(s.Lead))
+			if err != nil {
+				return err
+			}
+			keys = append(keys, k)
+		}
+		if keys, err = pruneKeys(keys, now.Add(-s.Retention)); err != nil {
+			return err
+		}
+	}
+	s.mu.Lock()
+	s.keys = keys
+	s.mu.Unlock()
+	return nil
+}
+
+// Encode signs a token with the given claims using the active key, the same
+// way jwtauth.JWTAuth.Encode does.
+func (s *KeySet) Encode(claims map[string]interface{}) (jwt.Token, string, error) {
+	k, err := s.signingKey(time.Now())
+	if err != nil {
+		return nil, "", err
+	}
+	token := jwt.New()
+	for name, value := range claims {
+		if err := token.Set(name, value); err != nil {
+			return nil, "", err
+		}
+	}
+	headers := jws.NewHeaders()
+	if k.ID != "" {
+		if err := headers.Set(jws.KeyIDKey, k.ID); err != nil {
+			return nil, "", err
+		}
+	}
+	signed, err := jwt.Sign(token, k.Algorithm, k.private, jwt.WithHeaders(headers))
+	if err != nil {
+		return nil, "", err
+	}
+	return token, string(signed), nil
+}
+
+// Verify checks the signature of a token with the key named by its kid, and
+// only with the algorithm of that key, then its time claims.
+func (s *KeySet) Verify(tokenString string) (jwt.Token, error) {
+	msg, err := jws.ParseString(tokenString)
+	if err != nil || len(msg.Signatures()) != 1 {
+		return nil, ErrInvalidToken
+	}
+	headers := msg.Signatures()[0].ProtectedHeaders()
+	k, ok := s.lookup(headers.KeyID())
+	if !ok || headers.Algorithm() != k.Algorithm {
+		return nil, ErrInvalidToken
+	}
+	token, err := jwt.ParseString(tokenString, jwt.WithVerify(k.Algorithm, k.public))
+	if err != nil {
+		return nil, ErrInvalidToken
+	}
+	if exp := token.Expiration(); !exp.IsZero() && !time.Now().Before(exp) {
+		return nil, ErrExpired
+	}
+	if err := jwt.Validate(token); err != nil {
+		return nil, ErrInvalidToken
+	}
+	return token, nil
+}
+
+// PublicKeys returns the JWK set other services verify tokens with. It lists
+// the public half of every key, those not active yet included, and is empty
+// when tokens are signed with a secret.
+func (s *KeySet) PublicKeys() (jwk.Set, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	set := jwk.NewSet()
+	for _, k := range s.keys {
+		if _, secret := k.public.([]byte); secret {
+			continue
+		}
+		public, err := jwk.New(k.public)
+		if err != nil {
+			return nil, err
+		}
+		for name, value := range map[string]interface{}{
+			jwk.KeyIDKey:     k.ID,
+			jwk.AlgorithmKey: k.Algorithm.String(),
+			jwk.KeyUsageKey:  "sig",
+		} {
+			if err := public.Set(name, value); err != nil {
+				return nil, err
+			}
+		}
+		set.Add(public)
+	}
+	return set, nil
+}
+
+// isSecret tells whether alg signs tokens with a shared secret.
+func isSecret(alg jwa.SignatureAlgorithm) bool {
+	return alg == jwa.HS256 || alg == jwa.HS384 || alg == jwa.HS512
+}
+
+// signingKey returns the most recently activated key.
+func (s *KeySet) signingKey(now time.Time) (*key, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	for i := len(s.keys) - 1; i >= 0; i-- {
+		if !s.keys[i].ActiveSince.After(now) {
+			return s.keys[i], nil
+		}
+	}
+	return nil, ErrNoSigningKey
+}
+
+func (s *KeySet) lookup(id string) (*key, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	for _, k := range s.keys {
+		if k.ID == id {
+			return k, true
+		}
+	}
+	return nil, false
+}
+
+// loadKeys reads the *.pem files of dir, a missing dir having no keys.
+func loadKeys(dir string) ([]*key, error) {
+	entries, err := os.ReadDir(dir)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var keys []*key
+	for _, entry := range entries {
+		if entry.IsDir() || filepath.Ext(entry.Name()) != ".pem" {
+			continue
+		}
+		path := filepath.Join(dir, entry.Name())
+		info, err := entry.Info()
+		if err != nil {
+			return nil, err
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		k, err := parseKey(strings.TrimSuffix(entry.Name(), ".pem"), data)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		k.ActiveSince = info.ModTime()
+		k.path = path
+		keys = append(keys, k)
+	}
+	sort.SliceStable(keys, func(i, j int) bool {
+		if keys[i].ActiveSince.Equal(keys[j].ActiveSince) {
+			return keys[i].ID < keys[j].ID
+		}
+		return keys[i].ActiveSince.Before(keys[j].ActiveSince)
+	})
+	return keys, nil
+}
+
+// parseKey reads a PKCS #8, PKCS #1 or SEC 1 private key, whose type tells
+// the algorithm it signs with.
+func parseKey(id string, data []byte) (*key, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("%w: no PEM block found", ErrUnsupportedKey)
+	}
+	var private interface{}
+	var err error
+	switch block.Type {
+	case "PRIVATE KEY":
+		private, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	case "RSA PRIVATE KEY":
+		private, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "EC PRIVATE KEY":
+		private, err = x509.ParseECPrivateKey(block.Bytes)
+	default:
+		return nil, fmt.Errorf("%w: PEM block %q is not a private key", ErrUnsupportedKey, block.Type)
+	}
+	if err != nil {
+		return nil, err
+	}
+	alg, err := algorithmOf(private)
+	if err != nil {
+		return nil, err
+	}
+	return &key{
+		ID:        id,
+		Algorithm: alg,
+		private:   private,
+		public:    private.(crypto.Signer).Public(),
+	}, nil
+}
+
+func algorithmOf(private interface{}) (jwa.SignatureAlgorithm, error) {
+	switch k := private.(type) {
+	case *rsa.PrivateKey:
+		if k.N.BitLen() < minRSABits {
+			return "", fmt.Errorf("%w: RSA keys need at least %d bits", ErrUnsupportedKey, minRSABits)
+		}
+		return jwa.RS256, nil
+	case *ecdsa.PrivateKey:
+		if k.Curve != elliptic.P256() {
+			return "", fmt.Errorf("%w: ECDSA keys must use the P-256 curve", ErrUnsupportedKey)
+		}
+		return jwa.ES256, nil
+	case ed25519.PrivateKey:
+		return jwa.EdDSA, nil
+	default:
+		return "", fmt.Errorf("%w: %T", ErrUnsupportedKey, private)
+	}
+}
+
+// generateKey writes a new key to dir, named after its JWK thumbprint so
+// that instances sharing dir cannot overwrite each other's keys.
+func generateKey(dir string, alg jwa.SignatureAlgorithm, activeSince time.Time) (*key, error) {
+	var private crypto.Signer
+	var err error
+	switch alg {
+	case jwa.RS256:
+		private, err = rsa.GenerateKey(rand.Reader, minRSABits)
+	case jwa.ES256:
+		private, err = ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	case jwa.EdDSA:
+		_, private, err = ed25519.GenerateKey(rand.Reader)
+	default:
+		return nil, fmt.Errorf("%w: %q", ErrUnsupportedAlgorithm, alg)
+	}
+	if err != nil {
+		return nil, err
+	}
+	public, err := jwk.New(private.Public())
+	if err != nil {
+		return nil, err
+	}
+	thumbprint, err := public.Thumbprint(crypto.SHA256)
+	if err != nil {
+		return nil, err
+	}
+	der, err := x509.MarshalPKCS8PrivateKey(private)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, err
+	}
+	// the key is written aside then renamed, so that other instances never
+	// load it half written or with the wrong activation time
+	file, err := os.CreateTemp(dir, ".key-*.tmp")
+	if err != nil {
+		return nil, err
+	}
+	defer os.Remove(file.Name())
+	err = pem.Encode(file, &pem.Block{Type: "PRIVATE KEY", Bytes: der})
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := os.Chtimes(file.Name(), activeSince, activeSince); err != nil {
+		return nil, err
+	}
+	id := base64.RawURLEncoding.EncodeToString(thumbprint)
+	path := filepath.Join(dir, id+".pem")
+	if err := os.Rename(file.Name(), path); err != nil {
+		return nil, err
+	}
+	return &key{
+		ID:          id,
+		Algorithm:   alg,
+		ActiveSince: activeSince,
+		private:     private,
+		public:      private.Public(),
+		path:        path,
+	}, nil
+}
+
+// pruneKeys deletes the keys replaced by a key activated before cutoff.
+func pruneKeys(keys []*key, cutoff time.Time) ([]*key, error) {
+	n := 0
+	for n+1 < len(keys) && !keys[n+1].ActiveSince.After(cutoff) {
+		if err := os.Remove(keys[n].path); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return nil, err
+		}
+		n++
+	}
+	return keys[n:], nil
+}
@@ -0,0 +1,286 @@
+package jwtkeys
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/lestrrat-go/jwx/jwa"
+	"github.com/lestrrat-go/jwx/jws"
+	"github.com/lestrrat-go/jwx/jwt"
+	"github.com/stretchr/testify/assert"
+)
+
+func claims(expiresIn time.Duration) map[string]interface{} {
+	return map[string]interface{}{
+		"sub": "42",
+		"exp": time.Now().Add(expiresIn).Unix(),
+	}
+}
+
+func writeKey(t *testing.T, dir, name, blockType string, der []byte, activeSince time.Time) {
+	path := filepath.Join(dir, name)
+	assert.Nil(t, os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: blockType, Bytes: der}), 0600))
+	assert.Nil(t, os.Chtimes(path, activeSince, activeSince))
+}
+
+func TestNewWithSecret(t *testing.T) {
+	dir := t.TempDir()
+	keys, err := New(Config{Algorithm: "HS256", Secret: "secret", Dir: dir, Rotation: time.Hour})
+	assert.Nil(t, err)
+	// the keys settings only apply to the other algorithms
+	assert.Nil(t, keys.Rotate(time.Now().Add(2*time.Hour)))
+	files, err := os.ReadDir(dir)
+	assert.Nil(t, err)
+	assert.Len(t, files, 0)
+
+	_, signed, err := keys.Encode(claims(time.Minute))
+	assert.Nil(t, err)
+	msg, err := jws.ParseString(signed)
+	assert.Nil(t, err)
+	assert.Equal(t, "", msg.Signatures()[0].ProtectedHeaders().KeyID())
+
+	token, err := keys.Verify(signed)
+	assert.Nil(t, err)
+	assert.Equal(t, "42", token.Subject())
+
+	// tokens signed before keys were introduced still verify
+	legacy, err := jwt.Sign(jwt.New(), jwa.HS256, []byte("secret"))
+	assert.Nil(t, err)
+	_, err = keys.Verify(string(legacy))
+	assert.Nil(t, err)
+
+	set, err := keys.PublicKeys()
+	assert.Nil(t, err)
+	assert.Equal(t, 0, set.Len())
+}
+
+func TestNewWhenInvalid(t *testing.T) {
+	for _, c := range []struct {
+		config Config
+		err    error
+	}{
+		{Config{Algorithm: ""}, ErrUnsupportedAlgorithm},
+		{Config{Algorithm: "none"}, ErrUnsupportedAlgorithm},
+		{Config{Algorithm: "PS256", Dir: t.TempDir()}, ErrUnsupportedAlgorithm},
+		{Config{Algorithm: "HS256"}, ErrSecretIsRequired},
+		{Config{Algorithm: "RS256"}, ErrDirIsRequired},
+		{Config{Algorithm: "EdDSA", Dir: t.TempDir(), Rotation: time.Minute, Lead: time.Minute}, ErrRotationTooShort},
+		{Config{Algorithm: "EdDSA", Dir: t.TempDir()}, ErrNoSigningKey},
+	} {
+		_, err := New(c.config)
+		assert.ErrorIs(t, err, c.err, c.config.Algorithm)
+	}
+}
+
+func TestNewGeneratesKeys(t *testing.T) {
+	for _, alg := range []string{"RS256", "ES256", "EdDSA"} {
+		dir := filepath.Join(t.TempDir(), "keys")
+		keys, err := New(Config{Algorithm: alg, Dir: dir, Rotation: time.Hour, Lead: time.Minute, Retention: time.Minute})
+		assert.Nil(t, err, alg)
+
+		files, err := os.ReadDir(dir)
+		assert.Nil(t, err)
+		assert.Len(t, files, 1)
+
+		_, signed, err := keys.Encode(claims(time.Minute))
+		assert.Nil(t, err)
+		headers := must(jws.ParseString(signed)).Signatures()[0].ProtectedHeaders()
+		assert.Equal(t, alg, headers.Algorithm().String())
+		assert.Equal(t, files[0].Name(), headers.KeyID()+".pem")
+
+		_, err = keys.Verify(signed)
+		assert.Nil(t, err, alg)
+
+		// other services verify the token with the published keys alone
+		set, err := keys.PublicKeys()
+		assert.Nil(t, err)
+		assert.Equal(t, 1, set.Len())
+		published, err := json.Marshal(set)
+		assert.Nil(t, err)
+		assert.NotContains(t, string(published), `"d"`)
+		token, err := jwt.ParseString(signed, jwt.WithKeySet(set))
+		assert.Nil(t, err, alg)
+		assert.Equal(t, "42", token.Subject())
+	}
+}
+
+func must(msg *jws.Message, err error) *jws.Message {
+	if err != nil {
+		panic(err)
+	}
+	return msg
+}
+
+func TestNewLoadsKeys(t *testing.T) {
+	dir := t.TempDir()
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	assert.Nil(t, err)
+	writeKey(t, dir, "rsa.pem", "RSA PRIVATE KEY", x509.MarshalPKCS1PrivateKey(rsaKey), time.Now().Add(-time.Hour))
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.Nil(t, err)
+	der, err := x509.MarshalECPrivateKey(ecKey)
+	assert.Nil(t, err)
+	writeKey(t, dir, "ec.pem", "EC PRIVATE KEY", der, time.Now().Add(-time.Minute))
+	assert.Nil(t, os.WriteFile(filepath.Join(dir, "README"), []byte("not a key"), 0600))
+
+	// the newest key signs whatever the configured algorithm, which only
+	// matters for the keys generated
+	keys, err := New(Config{Algorithm: "RS256", Dir: dir})
+	assert.Nil(t, err)
+	_, signed, err := keys.Encode(claims(time.Minute))
+	assert.Nil(t, err)
+	headers := must(jws.ParseString(signed)).Signatures()[0].ProtectedHeaders()
+	assert.Equal(t, "ec", headers.KeyID())
+	assert.Equal(t, jwa.ES256, headers.Algorithm())
+
+	rsaSigned, err := jwt.Sign(jwt.New(), jwa.RS256, rsaKey, jwt.WithHeaders(kid("rsa")))
+	assert.Nil(t, err)
+	_, err = keys.Verify(string(rsaSigned))
+	assert.Nil(t, err)
+
+	set, err := keys.PublicKeys()
+	assert.Nil(t, err)
+	assert.Equal(t, 2, set.Len())
+}
+
+func kid(id string) jws.Headers {
+	headers := jws.NewHeaders()
+	headers.Set(jws.KeyIDKey, id)
+	return headers
+}
+
+func TestNewWhenKeyIsUnsupported(t *testing.T) {
+	weak, err := rsa.GenerateKey(rand.Reader, 1024)
+	assert.Nil(t, err)
+	p384, err := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	assert.Nil(t, err)
+	p384DER, err := x509.MarshalECPrivateKey(p384)
+	assert.Nil(t, err)
+	for name, block := range map[string]pem.Block{
+		"weak.pem":   {Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(weak)},
+		"p384.pem":   {Type: "EC PRIVATE KEY", Bytes: p384DER},
+		"public.pem": {Type: "PUBLIC KEY", Bytes: []byte("key")},
+	} {
+		dir := t.TempDir()
+		writeKey(t, dir, name, block.Type, block.Bytes, time.Now())
+		_, err := New(Config{Algorithm: "RS256", Dir: dir})
+		assert.ErrorIs(t, err, ErrUnsupportedKey, name)
+	}
+}
+
+func TestVerifyWhenInvalid(t *testing.T) {
+	dir := t.TempDir()
+	keys, err := New(Config{Algorithm: "ES256", Dir: dir, Rotation: time.Hour})
+	assert.Nil(t, err)
+	other, err := New(Config{Algorithm: "ES256", Dir: t.TempDir(), Rotation: time.Hour})
+	assert.Nil(t, err)
+	signing, err := keys.signingKey(time.Now())
+	assert.Nil(t, err)
+
+	_, signed, err := keys.Encode(claims(time.Minute))
+	assert.Nil(t, err)
+	_, foreign, err := other.Encode(claims(time.Minute))
+	assert.Nil(t, err)
+	// the public key must not be usable as an HMAC secret
+	public, err := x509.MarshalPKIXPublicKey(signing.public)
+	assert.Nil(t, err)
+	confused, err := jwt.Sign(jwt.New(), jwa.HS256, public, jwt.WithHeaders(kid(signing.ID)))
+	assert.Nil(t, err)
+	withoutKid, err := jwt.Sign(jwt.New(), jwa.HS256, []byte("secret"))
+	assert.Nil(t, err)
+
+	for _, invalid := range []string{"", "abc", signed + "x", foreign, string(confused), string(withoutKid)} {
+		_, err := keys.Verify(invalid)
+		assert.Equal(t, ErrInvalidToken, err, invalid)
+	}
+
+	_, expired, err := keys.Encode(claims(-time.Minute))
+	assert.Nil(t, err)
+	_, err = keys.Verify(expired)
+	assert.Equal(t, ErrExpired, err)
+}
+
+func TestRotate(t *testing.T) {
+	dir := t.TempDir()
+	c := Config{Algorithm: "EdDSA", Dir: dir, Rotation: time.Hour, Lead: 5 * time.Minute, Retention: 10 * time.Minute}
+	keys, err := New(c)
+	assert.Nil(t, err)
+	_, signed, err := keys.Encode(claims(time.Hour))
+	assert.Nil(t, err)
+	first, err := keys.signingKey(time.Now())
+	assert.Nil(t, err)
+	start := first.ActiveSince
+
+	// not due yet
+	assert.Nil(t, keys.Rotate(start.Add(50*time.Minute)))
+	assert.Len(t, keys.keys, 1)
+
+	// the next key is published ahead of signing
+	assert.Nil(t, keys.Rotate(start.Add(56*time.Minute)))
+	assert.Len(t, keys.keys, 2)
+	second := keys.keys[1]
+	assert.Equal(t, start.Add(61*time.Minute).Unix(), second.ActiveSince.Unix())
+	set, err := keys.PublicKeys()
+	assert.Nil(t, err)
+	assert.Equal(t, 2, set.Len())
+	current, err := keys.signingKey(start.Add(56 * time.Minute))
+	assert.Nil(t, err)
+	assert.Equal(t, first.ID, current.ID)
+	current, err = keys.signingKey(start.Add(62 * time.Minute))
+	assert.Nil(t, err)
+	assert.Equal(t, second.ID, current.ID)
+
+	// other instances sharing the directory pick the new key up
+	shared, err := New(c)
+	assert.Nil(t, err)
+	assert.Len(t, shared.keys, 2)
+
+	// the replaced key verifies tokens until the retention period is over
+	assert.Nil(t, keys.Rotate(start.Add(70*time.Minute)))
+	assert.Len(t, keys.keys, 2)
+	_, err = keys.Verify(signed)
+	assert.Nil(t, err)
+
+	assert.Nil(t, keys.Rotate(start.Add(72*time.Minute)))
+	assert.Len(t, keys.keys, 1)
+	assert.Equal(t, second.ID, keys.keys[0].ID)
+	_, err = keys.Verify(signed)
+	assert.Equal(t, ErrInvalidToken, err)
+	files, err := os.ReadDir(dir)
+	assert.Nil(t, err)
+	assert.Len(t, files, 1)
+}
+
+func TestRotateWithoutRotationKeepsKeys(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"old.pem", "new.pem"} {
+		private, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		assert.Nil(t, err)
+		der, err := x509.MarshalPKCS8PrivateKey(private)
+		assert.Nil(t, err)
+		activeSince := time.Now().Add(-48 * time.Hour)
+		if name == "new.pem" {
+			activeSince = time.Now().Add(-24 * time.Hour)
+		}
+		writeKey(t, dir, name, "PRIVATE KEY", der, activeSince)
+	}
+	keys, err := New(Config{Algorithm: "ES256", Dir: dir, Retention: time.Minute})
+	assert.Nil(t, err)
+	assert.Nil(t, keys.Rotate(time.Now()))
+	assert.Len(t, keys.keys, 2)
+
+	// keys removed by hand are forgotten
+	assert.Nil(t, os.Remove(filepath.Join(dir, "old.pem")))
+	assert.Nil(t, keys.Rotate(time.Now()))
+	assert.Len(t, keys.keys, 1)
+	assert.Equal(t, "new", keys.keys[0].ID)
+}